@@ -2,18 +2,27 @@ package main
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	switchback "github.com/bbengfort/switchback/pkg"
 	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/bbengfort/switchback/pkg/compress"
 	"github.com/bbengfort/switchback/pkg/config"
 	"github.com/joho/godotenv"
 	"github.com/urfave/cli/v2"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
@@ -35,34 +44,73 @@ func main() {
 				Usage:    "serves the switchback server",
 				Category: "server",
 				Action:   serve,
-				Flags:    []cli.Flag{},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "a yaml or toml config file, reloaded on SIGHUP (env vars take precedence)",
+						EnvVars: []string{"SWITCHBACK_CONFIG"},
+					},
+					&cli.StringFlag{
+						Name:    "tls-cert",
+						Usage:   "the server certificate to serve tls with",
+						EnvVars: []string{"SWITCHBACK_TLS_CERT_FILE"},
+					},
+					&cli.StringFlag{
+						Name:    "tls-key",
+						Usage:   "the private key of the server certificate",
+						EnvVars: []string{"SWITCHBACK_TLS_KEY_FILE"},
+					},
+					&cli.StringFlag{
+						Name:    "ca",
+						Usage:   "the ca certificates to verify clients with (enables mutual tls)",
+						EnvVars: []string{"SWITCHBACK_TLS_CLIENT_CA"},
+					},
+				},
 			},
 			{
 				Name:     "status",
 				Usage:    "get the status of a running switchback server",
 				Category: "client",
 				Action:   status,
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:    "endpoint",
-						Aliases: []string{"e"},
-						Usage:   "the endpoint to connect to the switchback server on",
-						Value:   "localhost:7773",
+				Flags: clientFlags(
+					&cli.BoolFlag{
+						Name:    "json",
+						Aliases: []string{"j"},
+						Usage:   "print the status as json rather than a table",
 					},
-				},
+					&cli.DurationFlag{
+						Name:    "watch",
+						Aliases: []string{"w"},
+						Usage:   "refresh the status at the specified interval (e.g. 2s)",
+					},
+				),
+			},
+			{
+				Name:      "maintenance",
+				Usage:     "turn maintenance mode on or off on a running switchback server",
+				ArgsUsage: "on|off",
+				Category:  "admin",
+				Action:    maintenance,
+				Flags: clientFlags(
+					&cli.BoolFlag{
+						Name:    "drain",
+						Aliases: []string{"d"},
+						Usage:   "close open publishers and flush subscribers when entering maintenance",
+					},
+					&cli.DurationFlag{
+						Name:  "deadline",
+						Usage: "the time allowed to drain before remaining streams are closed",
+						Value: 30 * time.Second,
+					},
+				),
 			},
 			{
 				Name:     "sub",
 				Usage:    "print events from the stream as they come in",
 				Category: "client",
 				Action:   subscribe,
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:    "endpoint",
-						Aliases: []string{"e"},
-						Usage:   "the endpoint to connect to the switchback server on",
-						Value:   "localhost:7773",
-					},
+				Flags: clientFlags(
 					&cli.StringFlag{
 						Name:    "topic",
 						Aliases: []string{"t"},
@@ -74,6 +122,81 @@ func main() {
 						Aliases: []string{"g"},
 						Usage:   "the group the client is a part of",
 					},
+				),
+			},
+			{
+				Name:      "topics",
+				Usage:     "list the topics on the switchback server",
+				ArgsUsage: "[pattern]",
+				Category:  "client",
+				Action:    topics,
+				Flags:     clientFlags(),
+			},
+			{
+				Name:     "schema",
+				Usage:    "manage the schemas that topics are bound to",
+				Category: "client",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "register",
+						Usage:     "register a new version of the schema for a topic",
+						ArgsUsage: "path",
+						Action:    schemaRegister,
+						Flags: clientFlags(
+							&cli.StringFlag{
+								Name:     "topic",
+								Aliases:  []string{"t"},
+								Usage:    "the topic to bind the schema to",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "type",
+								Usage: "the type of schema, either protobuf (a descriptor set) or json",
+								Value: "json",
+							},
+							&cli.StringFlag{
+								Name:    "message",
+								Aliases: []string{"m"},
+								Usage:   "the fully qualified message name for protobuf schemas",
+							},
+							&cli.StringFlag{
+								Name:    "compatibility",
+								Aliases: []string{"c"},
+								Usage:   "the compatibility required of the next version: none, backward, forward, or full",
+								Value:   "backward",
+							},
+						),
+					},
+					{
+						Name:   "get",
+						Usage:  "get the schema bound to a topic",
+						Action: schemaGet,
+						Flags: clientFlags(
+							&cli.StringFlag{
+								Name:     "topic",
+								Aliases:  []string{"t"},
+								Usage:    "the topic to get the schema for",
+								Required: true,
+							},
+							&cli.UintFlag{
+								Name:    "version",
+								Aliases: []string{"v"},
+								Usage:   "the version of the schema to get (latest by default)",
+							},
+						),
+					},
+					{
+						Name:   "list",
+						Usage:  "list the latest schemas of all topics or the versions of a topic's schema",
+						Action: schemaList,
+						Flags: clientFlags(
+							&cli.StringFlag{
+								Name:    "topic",
+								Aliases: []string{"t"},
+								Usage:   "list the versions of the schema for the topic",
+							},
+						),
+					},
 				},
 			},
 			{
@@ -81,19 +204,43 @@ func main() {
 				Usage:    "randomly generate events in the specified topic and publish them",
 				Category: "simulator",
 				Action:   simulator,
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:    "endpoint",
-						Aliases: []string{"e"},
-						Usage:   "the endpoint to connect to the switchback server on",
-						Value:   "localhost:7773",
-					},
+				Flags: clientFlags(
 					&cli.StringFlag{
 						Name:    "topic",
 						Aliases: []string{"t"},
 						Usage:   "the topic to generate events on",
 						Value:   "default",
 					},
+					&cli.StringFlag{
+						Name:    "producer",
+						Aliases: []string{"p"},
+						Usage:   "publish as an idempotent producer with the specified id",
+					},
+				),
+			},
+			{
+				Name:     "webhook",
+				Usage:    "run a local webhook receiver that prints the events pushed to it",
+				Category: "simulator",
+				Action:   webhook,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "addr",
+						Aliases: []string{"a"},
+						Usage:   "the address to listen for webhook requests on",
+						Value:   ":8080",
+					},
+					&cli.StringFlag{
+						Name:    "secret",
+						Aliases: []string{"s"},
+						Usage:   "the secret to verify the signature of webhook payloads with",
+						EnvVars: []string{"SWITCHBACK_WEBHOOK_SECRET"},
+					},
+					&cli.IntFlag{
+						Name:  "status",
+						Usage: "the status code to respond with, e.g. 503 to test retries",
+						Value: http.StatusNoContent,
+					},
 				},
 			},
 		},
@@ -104,12 +251,71 @@ func main() {
 	}
 }
 
+// clientFlags returns the flags used to connect to a switchback server along with any
+// additional flags specified by the command.
+func clientFlags(flags ...cli.Flag) []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:    "endpoint",
+			Aliases: []string{"e"},
+			Usage:   "the endpoint to connect to the switchback server on",
+			Value:   "localhost:7773",
+		},
+		&cli.StringFlag{
+			Name:  "tls-cert",
+			Usage: "the client certificate to present for mutual tls",
+		},
+		&cli.StringFlag{
+			Name:  "tls-key",
+			Usage: "the private key of the client certificate",
+		},
+		&cli.StringFlag{
+			Name:  "ca",
+			Usage: "the ca certificates to verify the server with (enables tls)",
+		},
+		&cli.StringFlag{
+			Name:    "token",
+			Usage:   "an api key or jwt to authenticate with (requires tls)",
+			EnvVars: []string{"SWITCHBACK_TOKEN"},
+		},
+		&cli.BoolFlag{
+			Name:  "insecure-token",
+			Usage: "send the token even if the connection does not use tls",
+		},
+		&cli.StringFlag{
+			Name:    "compression",
+			Aliases: []string{"z"},
+			Usage:   "compress requests and responses with gzip, zstd, or snappy",
+			EnvVars: []string{"SWITCHBACK_COMPRESSION"},
+		},
+	}, flags...)
+}
+
 func serve(c *cli.Context) (err error) {
 	var conf config.Config
-	if conf, err = config.New(); err != nil {
+	if conf, err = config.Load(c.String("config")); err != nil {
 		return cli.Exit(err, 1)
 	}
 
+	// Flags only override the TLS settings they specify, the rest are kept from the config
+	if c.IsSet("tls-cert") {
+		conf.TLS.CertFile = c.String("tls-cert")
+	}
+
+	if c.IsSet("tls-key") {
+		conf.TLS.KeyFile = c.String("tls-key")
+	}
+
+	if c.IsSet("ca") {
+		conf.TLS.ClientCA = c.String("ca")
+	}
+
+	if c.IsSet("tls-cert") || c.IsSet("tls-key") || c.IsSet("ca") {
+		if err = conf.Validate(); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
 	var srv *switchback.Server
 	if srv, err = switchback.New(conf); err != nil {
 		return cli.Exit(err, 1)
@@ -123,26 +329,126 @@ func serve(c *cli.Context) (err error) {
 
 func status(c *cli.Context) (err error) {
 	var cc *grpc.ClientConn
-	if cc, err = grpc.Dial(c.String("endpoint"), grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+	if cc, err = dial(c); err != nil {
 		return cli.Exit(err, 1)
 	}
 
 	defer cc.Close()
 	client := api.NewSwitchbackClient(cc)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		rep, err := client.Status(ctx, &api.HealthCheck{})
+		cancel()
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+
+		if c.Bool("json") {
+			if err = printJSON(rep); err != nil {
+				return err
+			}
+		} else {
+			if c.Duration("watch") > 0 {
+				// Clear the terminal so the table is redrawn in place
+				fmt.Print("\033[H\033[2J")
+			}
+			printStatus(rep)
+		}
+
+		if c.Duration("watch") <= 0 {
+			return nil
+		}
+		time.Sleep(c.Duration("watch"))
+	}
+}
+
+// printStatus renders the service state as a table.
+func printStatus(rep *api.ServiceState) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Status:\t%s\n", rep.Status)
+	fmt.Fprintf(w, "Version:\t%s\n", rep.Version)
+	fmt.Fprintf(w, "Uptime:\t%s\n", rep.Uptime)
+	fmt.Fprintf(w, "Topics:\t%d\n", rep.Topics)
+	fmt.Fprintf(w, "Groups:\t%d\n", rep.Groups)
+	fmt.Fprintf(w, "Publishers:\t%d\n", rep.Publishers)
+	fmt.Fprintf(w, "Subscribers:\t%d\n", rep.Subscribers)
+	fmt.Fprintf(w, "Published:\t%d events (%0.2f/s)\n", rep.EventsPublished, rep.PublishRate)
+	fmt.Fprintf(w, "Delivered:\t%d events (%0.2f/s)\n", rep.EventsDelivered, rep.DeliveryRate)
+	fmt.Fprintf(w, "Storage:\t%d bytes\n", rep.StorageBytes)
+	if len(rep.Members) > 0 {
+		fmt.Fprintf(w, "Term:\t%d\n", rep.Term)
+	}
+	w.Flush()
+
+	if len(rep.Components) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "COMPONENT\tSTATUS\tMESSAGE")
+		for _, component := range rep.Components {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", component.Name, component.Status, component.Message)
+		}
+		w.Flush()
+	}
+
+	if len(rep.Members) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NODE\tADDRESS\tENDPOINT\tROLE")
+		for _, member := range rep.Members {
+			role := "follower"
+			switch {
+			case member.Leader:
+				role = "leader"
+			case !member.Voter:
+				role = "nonvoter"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", member.Id, member.Address, member.Endpoint, role)
+		}
+		w.Flush()
+	}
+}
+
+func maintenance(c *cli.Context) (err error) {
+	req := &api.MaintenanceRequest{
+		Drain:    c.Bool("drain"),
+		Deadline: c.Duration("deadline").String(),
+	}
+
+	switch strings.ToLower(c.Args().First()) {
+	case "on":
+		req.Enabled = true
+	case "off":
+		if req.Drain {
+			return cli.Exit("cannot drain when turning maintenance mode off", 1)
+		}
+	default:
+		return cli.Exit("specify either on or off", 1)
+	}
+
+	var cc *grpc.ClientConn
+	if cc, err = dial(c); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	defer cc.Close()
+	client := api.NewSwitchbackClient(cc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Duration("deadline")+20*time.Second)
 	defer cancel()
 
 	var rep *api.ServiceState
-	if rep, err = client.Status(ctx, &api.HealthCheck{}); err != nil {
+	if rep, err = client.Maintenance(ctx, req); err != nil {
 		return cli.Exit(err, 1)
 	}
-	return printJSON(rep)
+
+	printStatus(rep)
+	return nil
 }
 
 func subscribe(c *cli.Context) (err error) {
 	var cc *grpc.ClientConn
-	if cc, err = grpc.Dial(c.String("endpoint"), grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+	if cc, err = dial(c); err != nil {
 		return cli.Exit(err, 1)
 	}
 
@@ -171,9 +477,119 @@ func subscribe(c *cli.Context) (err error) {
 	}
 }
 
+func schemaRegister(c *cli.Context) (err error) {
+	if c.NArg() != 1 {
+		return cli.Exit("specify the path to the schema definition", 1)
+	}
+
+	in := &api.Schema{
+		Topic:   c.String("topic"),
+		Message: c.String("message"),
+	}
+
+	switch strings.ToLower(c.String("type")) {
+	case "protobuf", "proto":
+		in.Type = api.SchemaType_PROTOBUF
+	case "json", "jsonschema":
+		in.Type = api.SchemaType_JSON_SCHEMA
+	default:
+		return cli.Exit(fmt.Errorf("unknown schema type %q", c.String("type")), 1)
+	}
+
+	compat, ok := api.Compatibility_value[strings.ToUpper(c.String("compatibility"))]
+	if !ok {
+		return cli.Exit(fmt.Errorf("unknown compatibility %q", c.String("compatibility")), 1)
+	}
+	in.Compatibility = api.Compatibility(compat)
+
+	if in.Definition, err = os.ReadFile(c.Args().First()); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	var cc *grpc.ClientConn
+	if cc, err = dial(c); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	defer cc.Close()
+	client := api.NewSwitchbackClient(cc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var rep *api.Schema
+	if rep, err = client.RegisterSchema(ctx, in); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
+func schemaGet(c *cli.Context) (err error) {
+	var cc *grpc.ClientConn
+	if cc, err = dial(c); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	defer cc.Close()
+	client := api.NewSwitchbackClient(cc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var rep *api.Schema
+	if rep, err = client.GetSchema(ctx, &api.SchemaQuery{Topic: c.String("topic"), Version: uint32(c.Uint("version"))}); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
+func schemaList(c *cli.Context) (err error) {
+	var cc *grpc.ClientConn
+	if cc, err = dial(c); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	defer cc.Close()
+	client := api.NewSwitchbackClient(cc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var rep *api.SchemaList
+	if rep, err = client.ListSchemas(ctx, &api.SchemaQuery{Topic: c.String("topic")}); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return printJSON(rep)
+}
+
+func topics(c *cli.Context) (err error) {
+	var cc *grpc.ClientConn
+	if cc, err = dial(c); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	defer cc.Close()
+	client := api.NewSwitchbackClient(cc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var rep *api.TopicList
+	if rep, err = client.ListTopics(ctx, &api.TopicQuery{Pattern: c.Args().First()}); err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TOPIC\tOFFSET\tGROUPS")
+	for _, topic := range rep.Topics {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", topic.Name, topic.Offset, topic.Groups)
+	}
+	return w.Flush()
+}
+
 func simulator(c *cli.Context) (err error) {
 	var cc *grpc.ClientConn
-	if cc, err = grpc.Dial(c.String("endpoint"), grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+	if cc, err = dial(c); err != nil {
 		return cli.Exit(err, 1)
 	}
 
@@ -185,19 +601,109 @@ func simulator(c *cli.Context) (err error) {
 		return cli.Exit(err, 1)
 	}
 
-	topic := c.String("topic")
+	topic, producer := c.String("topic"), c.String("producer")
 	ticker := time.NewTicker(2500 * time.Millisecond)
 
+	var sequence uint64
 	for {
 		ts := <-ticker.C
 		event := &api.Event{Topic: topic, Data: []byte(ts.Format(time.RFC1123Z))}
+		if producer != "" {
+			sequence++
+			event.Producer, event.Sequence = producer, sequence
+		}
 		if err = stream.Send(event); err != nil {
+			if err == io.EOF {
+				// The server closed the stream (e.g. to drain), get the final reply
+				var rep *api.ClosePublish
+				if rep, err = stream.CloseAndRecv(); err != nil {
+					return cli.Exit(err, 1)
+				}
+				return printJSON(rep)
+			}
 			return cli.Exit(err, 1)
 		}
 	}
+}
+
+// webhook runs a local receiver for webhook deliveries that verifies their signatures,
+// prints the events, and responds with the configured status code.
+func webhook(c *cli.Context) (err error) {
+	secret, code := c.String("secret"), c.Int("status")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if secret != "" {
+			signature := "sha256=" + switchback.Sign(secret, r.Header.Get(switchback.WebhookTimestamp), body)
+			if !hmac.Equal([]byte(signature), []byte(r.Header.Get(switchback.WebhookSignature))) {
+				fmt.Fprintf(os.Stderr, "rejected event with invalid signature from %s\n", r.RemoteAddr)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "topic %s offset %s attempt %s: responding %d\n", r.Header.Get(switchback.WebhookTopic), r.Header.Get(switchback.WebhookOffset), r.Header.Get(switchback.WebhookAttempt), code)
+		event := &api.Event{}
+		if err = protojson.Unmarshal(body, event); err == nil {
+			printJSON(event)
+		}
+		w.WriteHeader(code)
+	}
+
+	fmt.Fprintf(os.Stderr, "listening for webhooks on %s\n", c.String("addr"))
+	if err = http.ListenAndServe(c.String("addr"), http.HandlerFunc(handler)); err != nil {
+		return cli.Exit(err, 1)
+	}
 	return nil
 }
 
+// dial connects to the switchback server at the endpoint, using TLS if the ca or a
+// client certificate is specified and insecure credentials otherwise.
+func dial(c *cli.Context) (_ *grpc.ClientConn, err error) {
+	opts := make([]grpc.DialOption, 0, 3)
+	if token := c.String("token"); token != "" {
+		if c.Bool("insecure-token") {
+			opts = append(opts, grpc.WithPerRPCCredentials(auth.InsecureToken(token)))
+		} else {
+			opts = append(opts, grpc.WithPerRPCCredentials(auth.Token(token)))
+		}
+	}
+
+	if codec := c.String("compression"); codec != compress.None {
+		if err = compress.Validate(codec); err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(codec)))
+	}
+
+	if c.String("ca") == "" && c.String("tls-cert") == "" && c.String("tls-key") == "" {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		return grpc.Dial(c.String("endpoint"), opts...)
+	}
+
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if ca := c.String("ca"); ca != "" {
+		if conf.RootCAs, err = switchback.LoadCertPool(ca); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.String("tls-cert") != "" || c.String("tls-key") != "" {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(c.String("tls-cert"), c.String("tls-key")); err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(conf)))
+	return grpc.Dial(c.String("endpoint"), opts...)
+}
+
 func printJSON(msg interface{}) (err error) {
 	var data []byte
 	switch m := msg.(type) {
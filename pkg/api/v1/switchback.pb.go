@@ -20,13 +20,175 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type TransactionState int32
+
+const (
+	TransactionState_UNKNOWN_TRANSACTION TransactionState = 0
+	TransactionState_OPEN                TransactionState = 1
+	TransactionState_COMMITTED           TransactionState = 2
+	TransactionState_ABORTED             TransactionState = 3
+)
+
+// Enum value maps for TransactionState.
+var (
+	TransactionState_name = map[int32]string{
+		0: "UNKNOWN_TRANSACTION",
+		1: "OPEN",
+		2: "COMMITTED",
+		3: "ABORTED",
+	}
+	TransactionState_value = map[string]int32{
+		"UNKNOWN_TRANSACTION": 0,
+		"OPEN":                1,
+		"COMMITTED":           2,
+		"ABORTED":             3,
+	}
+)
+
+func (x TransactionState) Enum() *TransactionState {
+	p := new(TransactionState)
+	*p = x
+	return p
+}
+
+func (x TransactionState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TransactionState) Descriptor() protoreflect.EnumDescriptor {
+	return file_switchback_v1_switchback_proto_enumTypes[0].Descriptor()
+}
+
+func (TransactionState) Type() protoreflect.EnumType {
+	return &file_switchback_v1_switchback_proto_enumTypes[0]
+}
+
+func (x TransactionState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TransactionState.Descriptor instead.
+func (TransactionState) EnumDescriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{0}
+}
+
+type SchemaType int32
+
+const (
+	SchemaType_UNKNOWN_SCHEMA SchemaType = 0
+	SchemaType_PROTOBUF       SchemaType = 1
+	SchemaType_JSON_SCHEMA    SchemaType = 2
+)
+
+// Enum value maps for SchemaType.
+var (
+	SchemaType_name = map[int32]string{
+		0: "UNKNOWN_SCHEMA",
+		1: "PROTOBUF",
+		2: "JSON_SCHEMA",
+	}
+	SchemaType_value = map[string]int32{
+		"UNKNOWN_SCHEMA": 0,
+		"PROTOBUF":       1,
+		"JSON_SCHEMA":    2,
+	}
+)
+
+func (x SchemaType) Enum() *SchemaType {
+	p := new(SchemaType)
+	*p = x
+	return p
+}
+
+func (x SchemaType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SchemaType) Descriptor() protoreflect.EnumDescriptor {
+	return file_switchback_v1_switchback_proto_enumTypes[1].Descriptor()
+}
+
+func (SchemaType) Type() protoreflect.EnumType {
+	return &file_switchback_v1_switchback_proto_enumTypes[1]
+}
+
+func (x SchemaType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SchemaType.Descriptor instead.
+func (SchemaType) EnumDescriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{1}
+}
+
+type Compatibility int32
+
+const (
+	Compatibility_NONE     Compatibility = 0 // any schema change is allowed
+	Compatibility_BACKWARD Compatibility = 1 // consumers using the new schema can read events written with the previous schema
+	Compatibility_FORWARD  Compatibility = 2 // consumers using the previous schema can read events written with the new schema
+	Compatibility_FULL     Compatibility = 3 // both backward and forward compatible
+)
+
+// Enum value maps for Compatibility.
+var (
+	Compatibility_name = map[int32]string{
+		0: "NONE",
+		1: "BACKWARD",
+		2: "FORWARD",
+		3: "FULL",
+	}
+	Compatibility_value = map[string]int32{
+		"NONE":     0,
+		"BACKWARD": 1,
+		"FORWARD":  2,
+		"FULL":     3,
+	}
+)
+
+func (x Compatibility) Enum() *Compatibility {
+	p := new(Compatibility)
+	*p = x
+	return p
+}
+
+func (x Compatibility) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Compatibility) Descriptor() protoreflect.EnumDescriptor {
+	return file_switchback_v1_switchback_proto_enumTypes[2].Descriptor()
+}
+
+func (Compatibility) Type() protoreflect.EnumType {
+	return &file_switchback_v1_switchback_proto_enumTypes[2]
+}
+
+func (x Compatibility) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Compatibility.Descriptor instead.
+func (Compatibility) EnumDescriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{2}
+}
+
 type Event struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Topic string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
-	Data  []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Topic      string            `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Data       []byte            `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Attributes map[string]string `protobuf:"bytes,3,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // optional publisher headers, e.g. for trace propagation
+	// Idempotent producers set a stable id and number their events with increasing
+	// sequence numbers starting at one; events the producer retries are deduplicated.
+	Producer string `protobuf:"bytes,4,opt,name=producer,proto3" json:"producer,omitempty"`
+	Sequence uint64 `protobuf:"varint,5,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// The id of an open transaction the event is published in, if any.
+	Transaction string `protobuf:"bytes,6,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	// Events with the same key are delivered in order to the same consumer in each group.
+	Key string `protobuf:"bytes,7,opt,name=key,proto3" json:"key,omitempty"`
 	// Should not be set by publisher and only read by consumers.
 	Meta *Metadata `protobuf:"bytes,16,opt,name=meta,proto3" json:"meta,omitempty"`
 }
@@ -77,6 +239,41 @@ func (x *Event) GetData() []byte {
 	return nil
 }
 
+func (x *Event) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *Event) GetProducer() string {
+	if x != nil {
+		return x.Producer
+	}
+	return ""
+}
+
+func (x *Event) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Event) GetTransaction() string {
+	if x != nil {
+		return x.Transaction
+	}
+	return ""
+}
+
+func (x *Event) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
 func (x *Event) GetMeta() *Metadata {
 	if x != nil {
 		return x.Meta
@@ -147,17 +344,16 @@ func (x *Metadata) GetSource() string {
 	return ""
 }
 
-type Subscription struct {
+type EventBatch struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Topic string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
-	Group string `protobuf:"bytes,2,opt,name=group,proto3" json:"group,omitempty"`
+	Events []*Event `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
 }
 
-func (x *Subscription) Reset() {
-	*x = Subscription{}
+func (x *EventBatch) Reset() {
+	*x = EventBatch{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_switchback_v1_switchback_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -165,13 +361,13 @@ func (x *Subscription) Reset() {
 	}
 }
 
-func (x *Subscription) String() string {
+func (x *EventBatch) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Subscription) ProtoMessage() {}
+func (*EventBatch) ProtoMessage() {}
 
-func (x *Subscription) ProtoReflect() protoreflect.Message {
+func (x *EventBatch) ProtoReflect() protoreflect.Message {
 	mi := &file_switchback_v1_switchback_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -183,37 +379,34 @@ func (x *Subscription) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Subscription.ProtoReflect.Descriptor instead.
-func (*Subscription) Descriptor() ([]byte, []int) {
+// Deprecated: Use EventBatch.ProtoReflect.Descriptor instead.
+func (*EventBatch) Descriptor() ([]byte, []int) {
 	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *Subscription) GetTopic() string {
-	if x != nil {
-		return x.Topic
-	}
-	return ""
-}
-
-func (x *Subscription) GetGroup() string {
+func (x *EventBatch) GetEvents() []*Event {
 	if x != nil {
-		return x.Group
+		return x.Events
 	}
-	return ""
+	return nil
 }
 
-type ClosePublish struct {
+type Subscription struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Events      uint64 `protobuf:"varint,1,opt,name=events,proto3" json:"events,omitempty"`
-	TopicOffset uint64 `protobuf:"varint,2,opt,name=topic_offset,json=topicOffset,proto3" json:"topic_offset,omitempty"`
-	Consumers   uint64 `protobuf:"varint,3,opt,name=consumers,proto3" json:"consumers,omitempty"`
+	Topic string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"` // the event topic stream to subscribe to
+	Group string `protobuf:"bytes,2,opt,name=group,proto3" json:"group,omitempty"` // consumer groups are guaranteed one message per consumer (random group created if not specified)
+	// Batched subscriptions only: the maximum number of events per batch (the server
+	// maximum if zero) and a duration (e.g. 50ms) to wait for a batch to fill before
+	// sending it. Without a linger, the events that are queued are sent immediately.
+	MaxBatchSize uint32 `protobuf:"varint,3,opt,name=max_batch_size,json=maxBatchSize,proto3" json:"max_batch_size,omitempty"`
+	Linger       string `protobuf:"bytes,4,opt,name=linger,proto3" json:"linger,omitempty"`
 }
 
-func (x *ClosePublish) Reset() {
-	*x = ClosePublish{}
+func (x *Subscription) Reset() {
+	*x = Subscription{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_switchback_v1_switchback_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -221,13 +414,13 @@ func (x *ClosePublish) Reset() {
 	}
 }
 
-func (x *ClosePublish) String() string {
+func (x *Subscription) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClosePublish) ProtoMessage() {}
+func (*Subscription) ProtoMessage() {}
 
-func (x *ClosePublish) ProtoReflect() protoreflect.Message {
+func (x *Subscription) ProtoReflect() protoreflect.Message {
 	mi := &file_switchback_v1_switchback_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -239,40 +432,49 @@ func (x *ClosePublish) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClosePublish.ProtoReflect.Descriptor instead.
-func (*ClosePublish) Descriptor() ([]byte, []int) {
+// Deprecated: Use Subscription.ProtoReflect.Descriptor instead.
+func (*Subscription) Descriptor() ([]byte, []int) {
 	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *ClosePublish) GetEvents() uint64 {
+func (x *Subscription) GetTopic() string {
 	if x != nil {
-		return x.Events
+		return x.Topic
 	}
-	return 0
+	return ""
 }
 
-func (x *ClosePublish) GetTopicOffset() uint64 {
+func (x *Subscription) GetGroup() string {
 	if x != nil {
-		return x.TopicOffset
+		return x.Group
 	}
-	return 0
+	return ""
 }
 
-func (x *ClosePublish) GetConsumers() uint64 {
+func (x *Subscription) GetMaxBatchSize() uint32 {
 	if x != nil {
-		return x.Consumers
+		return x.MaxBatchSize
 	}
 	return 0
 }
 
-type HealthCheck struct {
+func (x *Subscription) GetLinger() string {
+	if x != nil {
+		return x.Linger
+	}
+	return ""
+}
+
+type TopicQuery struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
+
+	Pattern string `protobuf:"bytes,1,opt,name=pattern,proto3" json:"pattern,omitempty"` // a glob pattern (e.g. orders.*) to filter topics by, all topics if empty
 }
 
-func (x *HealthCheck) Reset() {
-	*x = HealthCheck{}
+func (x *TopicQuery) Reset() {
+	*x = TopicQuery{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_switchback_v1_switchback_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -280,13 +482,13 @@ func (x *HealthCheck) Reset() {
 	}
 }
 
-func (x *HealthCheck) String() string {
+func (x *TopicQuery) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*HealthCheck) ProtoMessage() {}
+func (*TopicQuery) ProtoMessage() {}
 
-func (x *HealthCheck) ProtoReflect() protoreflect.Message {
+func (x *TopicQuery) ProtoReflect() protoreflect.Message {
 	mi := &file_switchback_v1_switchback_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -298,23 +500,28 @@ func (x *HealthCheck) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use HealthCheck.ProtoReflect.Descriptor instead.
-func (*HealthCheck) Descriptor() ([]byte, []int) {
+// Deprecated: Use TopicQuery.ProtoReflect.Descriptor instead.
+func (*TopicQuery) Descriptor() ([]byte, []int) {
 	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{4}
 }
 
-type ServiceState struct {
+func (x *TopicQuery) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+type TopicList struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Status  string `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
-	Uptime  string `protobuf:"bytes,2,opt,name=uptime,proto3" json:"uptime,omitempty"`
-	Version string `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	Topics []*Topic `protobuf:"bytes,1,rep,name=topics,proto3" json:"topics,omitempty"`
 }
 
-func (x *ServiceState) Reset() {
-	*x = ServiceState{}
+func (x *TopicList) Reset() {
+	*x = TopicList{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_switchback_v1_switchback_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -322,13 +529,13 @@ func (x *ServiceState) Reset() {
 	}
 }
 
-func (x *ServiceState) String() string {
+func (x *TopicList) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServiceState) ProtoMessage() {}
+func (*TopicList) ProtoMessage() {}
 
-func (x *ServiceState) ProtoReflect() protoreflect.Message {
+func (x *TopicList) ProtoReflect() protoreflect.Message {
 	mi := &file_switchback_v1_switchback_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -340,129 +547,1210 @@ func (x *ServiceState) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServiceState.ProtoReflect.Descriptor instead.
-func (*ServiceState) Descriptor() ([]byte, []int) {
+// Deprecated: Use TopicList.ProtoReflect.Descriptor instead.
+func (*TopicList) Descriptor() ([]byte, []int) {
 	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *ServiceState) GetStatus() string {
+func (x *TopicList) GetTopics() []*Topic {
 	if x != nil {
-		return x.Status
+		return x.Topics
 	}
-	return ""
+	return nil
 }
 
-func (x *ServiceState) GetUptime() string {
+type Topic struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name   string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Offset uint64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"` // the offset of the last event published to the topic
+	Groups uint32 `protobuf:"varint,3,opt,name=groups,proto3" json:"groups,omitempty"` // the number of consumer groups subscribed to the topic
+}
+
+func (x *Topic) Reset() {
+	*x = Topic{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Topic) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Topic) ProtoMessage() {}
+
+func (x *Topic) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Topic.ProtoReflect.Descriptor instead.
+func (*Topic) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Topic) GetName() string {
 	if x != nil {
-		return x.Uptime
+		return x.Name
 	}
 	return ""
 }
 
-func (x *ServiceState) GetVersion() string {
+func (x *Topic) GetOffset() uint64 {
 	if x != nil {
-		return x.Version
+		return x.Offset
 	}
-	return ""
+	return 0
 }
 
-var File_switchback_v1_switchback_proto protoreflect.FileDescriptor
+func (x *Topic) GetGroups() uint32 {
+	if x != nil {
+		return x.Groups
+	}
+	return 0
+}
 
-var file_switchback_v1_switchback_proto_rawDesc = []byte{
-	0x0a, 0x1e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2f, 0x76, 0x31, 0x2f,
-	0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x12, 0x0d, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x22,
-	0x5e, 0x0a, 0x05, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69,
-	0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x12,
-	0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61,
-	0x74, 0x61, 0x12, 0x2b, 0x0a, 0x04, 0x6d, 0x65, 0x74, 0x61, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x17, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31,
-	0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x04, 0x6d, 0x65, 0x74, 0x61, 0x22,
-	0x50, 0x0a, 0x08, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x16, 0x0a, 0x06, 0x6f,
-	0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6f, 0x66, 0x66,
-	0x73, 0x65, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x6f, 0x75,
-	0x72, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63,
-	0x65, 0x22, 0x3a, 0x0a, 0x0c, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f,
-	0x6e, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x22, 0x67, 0x0a,
-	0x0c, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x12, 0x16, 0x0a,
-	0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x65,
-	0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x5f, 0x6f,
-	0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x74, 0x6f, 0x70,
-	0x69, 0x63, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x6f, 0x6e, 0x73,
-	0x75, 0x6d, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x63, 0x6f, 0x6e,
-	0x73, 0x75, 0x6d, 0x65, 0x72, 0x73, 0x22, 0x0d, 0x0a, 0x0b, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68,
-	0x43, 0x68, 0x65, 0x63, 0x6b, 0x22, 0x58, 0x0a, 0x0c, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
-	0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x16, 0x0a,
-	0x06, 0x75, 0x70, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x75,
-	0x70, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
-	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x32,
-	0xd7, 0x01, 0x0a, 0x0a, 0x53, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x12, 0x40,
-	0x0a, 0x07, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x12, 0x14, 0x2e, 0x73, 0x77, 0x69, 0x74,
-	0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x1a,
-	0x1b, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e,
-	0x43, 0x6c, 0x6f, 0x73, 0x65, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x22, 0x00, 0x28, 0x01,
-	0x12, 0x42, 0x0a, 0x09, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x12, 0x1b, 0x2e,
-	0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x75,
-	0x62, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x1a, 0x14, 0x2e, 0x73, 0x77, 0x69,
-	0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74,
-	0x22, 0x00, 0x30, 0x01, 0x12, 0x43, 0x0a, 0x06, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1a,
-	0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x48,
-	0x65, 0x61, 0x6c, 0x74, 0x68, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x1a, 0x1b, 0x2e, 0x73, 0x77, 0x69,
-	0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69,
-	0x63, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x22, 0x00, 0x42, 0x30, 0x5a, 0x2e, 0x67, 0x69, 0x74,
-	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x62, 0x62, 0x65, 0x6e, 0x67, 0x66, 0x6f, 0x72,
-	0x74, 0x2f, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2f, 0x70, 0x6b, 0x67,
-	0x2f, 0x61, 0x70, 0x69, 0x2f, 0x76, 0x31, 0x3b, 0x61, 0x70, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x33,
+type TransactionRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Timeout string `protobuf:"bytes,1,opt,name=timeout,proto3" json:"timeout,omitempty"` // a duration (e.g. 30s) after which the open transaction is aborted
 }
 
-var (
-	file_switchback_v1_switchback_proto_rawDescOnce sync.Once
-	file_switchback_v1_switchback_proto_rawDescData = file_switchback_v1_switchback_proto_rawDesc
-)
+func (x *TransactionRequest) Reset() {
+	*x = TransactionRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
 
-func file_switchback_v1_switchback_proto_rawDescGZIP() []byte {
-	file_switchback_v1_switchback_proto_rawDescOnce.Do(func() {
-		file_switchback_v1_switchback_proto_rawDescData = protoimpl.X.CompressGZIP(file_switchback_v1_switchback_proto_rawDescData)
-	})
-	return file_switchback_v1_switchback_proto_rawDescData
+func (x *TransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var file_switchback_v1_switchback_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
-var file_switchback_v1_switchback_proto_goTypes = []interface{}{
-	(*Event)(nil),        // 0: switchback.v1.Event
-	(*Metadata)(nil),     // 1: switchback.v1.Metadata
-	(*Subscription)(nil), // 2: switchback.v1.Subscription
-	(*ClosePublish)(nil), // 3: switchback.v1.ClosePublish
-	(*HealthCheck)(nil),  // 4: switchback.v1.HealthCheck
-	(*ServiceState)(nil), // 5: switchback.v1.ServiceState
+func (*TransactionRequest) ProtoMessage() {}
+
+func (x *TransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
-var file_switchback_v1_switchback_proto_depIdxs = []int32{
-	1, // 0: switchback.v1.Event.meta:type_name -> switchback.v1.Metadata
-	0, // 1: switchback.v1.Switchback.Publish:input_type -> switchback.v1.Event
-	2, // 2: switchback.v1.Switchback.Subscribe:input_type -> switchback.v1.Subscription
-	4, // 3: switchback.v1.Switchback.Status:input_type -> switchback.v1.HealthCheck
-	3, // 4: switchback.v1.Switchback.Publish:output_type -> switchback.v1.ClosePublish
-	0, // 5: switchback.v1.Switchback.Subscribe:output_type -> switchback.v1.Event
-	5, // 6: switchback.v1.Switchback.Status:output_type -> switchback.v1.ServiceState
-	4, // [4:7] is the sub-list for method output_type
-	1, // [1:4] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+
+// Deprecated: Use TransactionRequest.ProtoReflect.Descriptor instead.
+func (*TransactionRequest) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{7}
 }
 
-func init() { file_switchback_v1_switchback_proto_init() }
-func file_switchback_v1_switchback_proto_init() {
-	if File_switchback_v1_switchback_proto != nil {
-		return
+func (x *TransactionRequest) GetTimeout() string {
+	if x != nil {
+		return x.Timeout
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_switchback_v1_switchback_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+	return ""
+}
+
+type Transaction struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id      string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	State   TransactionState  `protobuf:"varint,2,opt,name=state,proto3,enum=switchback.v1.TransactionState" json:"state,omitempty"`
+	Events  uint64            `protobuf:"varint,3,opt,name=events,proto3" json:"events,omitempty"`                                                                                           // the number of events published in the transaction
+	Offsets map[string]uint64 `protobuf:"bytes,4,rep,name=offsets,proto3" json:"offsets,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"` // the offset of the last event in each topic once committed
+	Expires string            `protobuf:"bytes,5,opt,name=expires,proto3" json:"expires,omitempty"`                                                                                          // when the open transaction will be aborted (RFC3339)
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Transaction) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Transaction) GetState() TransactionState {
+	if x != nil {
+		return x.State
+	}
+	return TransactionState_UNKNOWN_TRANSACTION
+}
+
+func (x *Transaction) GetEvents() uint64 {
+	if x != nil {
+		return x.Events
+	}
+	return 0
+}
+
+func (x *Transaction) GetOffsets() map[string]uint64 {
+	if x != nil {
+		return x.Offsets
+	}
+	return nil
+}
+
+func (x *Transaction) GetExpires() string {
+	if x != nil {
+		return x.Expires
+	}
+	return ""
+}
+
+type ClosePublish struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Events      uint64 `protobuf:"varint,1,opt,name=events,proto3" json:"events,omitempty"`
+	TopicOffset uint64 `protobuf:"varint,2,opt,name=topic_offset,json=topicOffset,proto3" json:"topic_offset,omitempty"` // for a duplicate, the offset originally assigned to the event
+	Consumers   uint64 `protobuf:"varint,3,opt,name=consumers,proto3" json:"consumers,omitempty"`
+	Duplicates  uint64 `protobuf:"varint,4,opt,name=duplicates,proto3" json:"duplicates,omitempty"` // the number of events dropped as duplicates of previous events
+}
+
+func (x *ClosePublish) Reset() {
+	*x = ClosePublish{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClosePublish) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClosePublish) ProtoMessage() {}
+
+func (x *ClosePublish) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClosePublish.ProtoReflect.Descriptor instead.
+func (*ClosePublish) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ClosePublish) GetEvents() uint64 {
+	if x != nil {
+		return x.Events
+	}
+	return 0
+}
+
+func (x *ClosePublish) GetTopicOffset() uint64 {
+	if x != nil {
+		return x.TopicOffset
+	}
+	return 0
+}
+
+func (x *ClosePublish) GetConsumers() uint64 {
+	if x != nil {
+		return x.Consumers
+	}
+	return 0
+}
+
+func (x *ClosePublish) GetDuplicates() uint64 {
+	if x != nil {
+		return x.Duplicates
+	}
+	return 0
+}
+
+type HealthCheck struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *HealthCheck) Reset() {
+	*x = HealthCheck{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HealthCheck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthCheck) ProtoMessage() {}
+
+func (x *HealthCheck) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthCheck.ProtoReflect.Descriptor instead.
+func (*HealthCheck) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{10}
+}
+
+type MaintenanceRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Enabled  bool   `protobuf:"varint,1,opt,name=enabled,proto3" json:"enabled,omitempty"`  // enter maintenance mode if true, otherwise resume normal operation
+	Drain    bool   `protobuf:"varint,2,opt,name=drain,proto3" json:"drain,omitempty"`      // close publish streams and flush subscribe streams when entering maintenance
+	Deadline string `protobuf:"bytes,3,opt,name=deadline,proto3" json:"deadline,omitempty"` // a duration (e.g. 30s) after which streams still draining are closed
+}
+
+func (x *MaintenanceRequest) Reset() {
+	*x = MaintenanceRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MaintenanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MaintenanceRequest) ProtoMessage() {}
+
+func (x *MaintenanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MaintenanceRequest.ProtoReflect.Descriptor instead.
+func (*MaintenanceRequest) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *MaintenanceRequest) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *MaintenanceRequest) GetDrain() bool {
+	if x != nil {
+		return x.Drain
+	}
+	return false
+}
+
+func (x *MaintenanceRequest) GetDeadline() string {
+	if x != nil {
+		return x.Deadline
+	}
+	return ""
+}
+
+type ServiceState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status          string             `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Uptime          string             `protobuf:"bytes,2,opt,name=uptime,proto3" json:"uptime,omitempty"`
+	Version         string             `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	Topics          uint32             `protobuf:"varint,4,opt,name=topics,proto3" json:"topics,omitempty"`
+	Groups          uint32             `protobuf:"varint,5,opt,name=groups,proto3" json:"groups,omitempty"`
+	Publishers      uint32             `protobuf:"varint,6,opt,name=publishers,proto3" json:"publishers,omitempty"`   // number of connected publish streams
+	Subscribers     uint32             `protobuf:"varint,7,opt,name=subscribers,proto3" json:"subscribers,omitempty"` // number of connected subscribe streams
+	EventsPublished uint64             `protobuf:"varint,8,opt,name=events_published,json=eventsPublished,proto3" json:"events_published,omitempty"`
+	EventsDelivered uint64             `protobuf:"varint,9,opt,name=events_delivered,json=eventsDelivered,proto3" json:"events_delivered,omitempty"`
+	PublishRate     float64            `protobuf:"fixed64,10,opt,name=publish_rate,json=publishRate,proto3" json:"publish_rate,omitempty"`    // events per second, exponentially weighted over one minute
+	DeliveryRate    float64            `protobuf:"fixed64,11,opt,name=delivery_rate,json=deliveryRate,proto3" json:"delivery_rate,omitempty"` // events per second, exponentially weighted over one minute
+	StorageBytes    uint64             `protobuf:"varint,12,opt,name=storage_bytes,json=storageBytes,proto3" json:"storage_bytes,omitempty"`
+	Components      []*ComponentHealth `protobuf:"bytes,13,rep,name=components,proto3" json:"components,omitempty"`
+	Members         []*ClusterMember   `protobuf:"bytes,14,rep,name=members,proto3" json:"members,omitempty"` // the nodes in the cluster, if clustering is enabled
+	Term            uint64             `protobuf:"varint,15,opt,name=term,proto3" json:"term,omitempty"`      // the current leadership term and epoch of new events
+}
+
+func (x *ServiceState) Reset() {
+	*x = ServiceState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServiceState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceState) ProtoMessage() {}
+
+func (x *ServiceState) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceState.ProtoReflect.Descriptor instead.
+func (*ServiceState) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ServiceState) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ServiceState) GetUptime() string {
+	if x != nil {
+		return x.Uptime
+	}
+	return ""
+}
+
+func (x *ServiceState) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *ServiceState) GetTopics() uint32 {
+	if x != nil {
+		return x.Topics
+	}
+	return 0
+}
+
+func (x *ServiceState) GetGroups() uint32 {
+	if x != nil {
+		return x.Groups
+	}
+	return 0
+}
+
+func (x *ServiceState) GetPublishers() uint32 {
+	if x != nil {
+		return x.Publishers
+	}
+	return 0
+}
+
+func (x *ServiceState) GetSubscribers() uint32 {
+	if x != nil {
+		return x.Subscribers
+	}
+	return 0
+}
+
+func (x *ServiceState) GetEventsPublished() uint64 {
+	if x != nil {
+		return x.EventsPublished
+	}
+	return 0
+}
+
+func (x *ServiceState) GetEventsDelivered() uint64 {
+	if x != nil {
+		return x.EventsDelivered
+	}
+	return 0
+}
+
+func (x *ServiceState) GetPublishRate() float64 {
+	if x != nil {
+		return x.PublishRate
+	}
+	return 0
+}
+
+func (x *ServiceState) GetDeliveryRate() float64 {
+	if x != nil {
+		return x.DeliveryRate
+	}
+	return 0
+}
+
+func (x *ServiceState) GetStorageBytes() uint64 {
+	if x != nil {
+		return x.StorageBytes
+	}
+	return 0
+}
+
+func (x *ServiceState) GetComponents() []*ComponentHealth {
+	if x != nil {
+		return x.Components
+	}
+	return nil
+}
+
+func (x *ServiceState) GetMembers() []*ClusterMember {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+func (x *ServiceState) GetTerm() uint64 {
+	if x != nil {
+		return x.Term
+	}
+	return 0
+}
+
+type ClusterMember struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Address  string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`   // the raft address used by the cluster peers
+	Endpoint string `protobuf:"bytes,3,opt,name=endpoint,proto3" json:"endpoint,omitempty"` // the advertised gRPC endpoint that clients connect to
+	Leader   bool   `protobuf:"varint,4,opt,name=leader,proto3" json:"leader,omitempty"`
+	Voter    bool   `protobuf:"varint,5,opt,name=voter,proto3" json:"voter,omitempty"`
+}
+
+func (x *ClusterMember) Reset() {
+	*x = ClusterMember{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClusterMember) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterMember) ProtoMessage() {}
+
+func (x *ClusterMember) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterMember.ProtoReflect.Descriptor instead.
+func (*ClusterMember) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ClusterMember) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ClusterMember) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *ClusterMember) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *ClusterMember) GetLeader() bool {
+	if x != nil {
+		return x.Leader
+	}
+	return false
+}
+
+func (x *ClusterMember) GetVoter() bool {
+	if x != nil {
+		return x.Voter
+	}
+	return false
+}
+
+type ComponentHealth struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name    string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Status  string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // one of ok, degraded, unavailable, or disabled
+	Message string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *ComponentHealth) Reset() {
+	*x = ComponentHealth{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ComponentHealth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ComponentHealth) ProtoMessage() {}
+
+func (x *ComponentHealth) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ComponentHealth.ProtoReflect.Descriptor instead.
+func (*ComponentHealth) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ComponentHealth) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ComponentHealth) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ComponentHealth) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type Schema struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Topic         string        `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Type          SchemaType    `protobuf:"varint,2,opt,name=type,proto3,enum=switchback.v1.SchemaType" json:"type,omitempty"`
+	Definition    []byte        `protobuf:"bytes,3,opt,name=definition,proto3" json:"definition,omitempty"` // a serialized FileDescriptorSet for protobuf or a JSON Schema document
+	Message       string        `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`       // the fully qualified protobuf message name of events on the topic
+	Compatibility Compatibility `protobuf:"varint,5,opt,name=compatibility,proto3,enum=switchback.v1.Compatibility" json:"compatibility,omitempty"`
+	// Assigned by the server when the schema is registered.
+	Version uint32 `protobuf:"varint,14,opt,name=version,proto3" json:"version,omitempty"`
+	Created string `protobuf:"bytes,15,opt,name=created,proto3" json:"created,omitempty"`
+}
+
+func (x *Schema) Reset() {
+	*x = Schema{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Schema) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Schema) ProtoMessage() {}
+
+func (x *Schema) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Schema.ProtoReflect.Descriptor instead.
+func (*Schema) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Schema) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *Schema) GetType() SchemaType {
+	if x != nil {
+		return x.Type
+	}
+	return SchemaType_UNKNOWN_SCHEMA
+}
+
+func (x *Schema) GetDefinition() []byte {
+	if x != nil {
+		return x.Definition
+	}
+	return nil
+}
+
+func (x *Schema) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Schema) GetCompatibility() Compatibility {
+	if x != nil {
+		return x.Compatibility
+	}
+	return Compatibility_NONE
+}
+
+func (x *Schema) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Schema) GetCreated() string {
+	if x != nil {
+		return x.Created
+	}
+	return ""
+}
+
+type SchemaQuery struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Topic   string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`      // the topic to get the schema for or to list the versions of (all topics if empty)
+	Version uint32 `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"` // a specific version of the schema, the latest if zero
+}
+
+func (x *SchemaQuery) Reset() {
+	*x = SchemaQuery{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SchemaQuery) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SchemaQuery) ProtoMessage() {}
+
+func (x *SchemaQuery) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SchemaQuery.ProtoReflect.Descriptor instead.
+func (*SchemaQuery) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *SchemaQuery) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *SchemaQuery) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type SchemaList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Schemas []*Schema `protobuf:"bytes,1,rep,name=schemas,proto3" json:"schemas,omitempty"`
+}
+
+func (x *SchemaList) Reset() {
+	*x = SchemaList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_switchback_v1_switchback_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SchemaList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SchemaList) ProtoMessage() {}
+
+func (x *SchemaList) ProtoReflect() protoreflect.Message {
+	mi := &file_switchback_v1_switchback_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SchemaList.ProtoReflect.Descriptor instead.
+func (*SchemaList) Descriptor() ([]byte, []int) {
+	return file_switchback_v1_switchback_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *SchemaList) GetSchemas() []*Schema {
+	if x != nil {
+		return x.Schemas
+	}
+	return nil
+}
+
+var File_switchback_v1_switchback_proto protoreflect.FileDescriptor
+
+var file_switchback_v1_switchback_proto_rawDesc = []byte{
+	0x0a, 0x1e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2f, 0x76, 0x31, 0x2f,
+	0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x12, 0x0d, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x22,
+	0xcf, 0x02, 0x0a, 0x05, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70,
+	0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12,
+	0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x12, 0x44, 0x0a, 0x0a, 0x61, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65,
+	0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68,
+	0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x41, 0x74,
+	0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0a, 0x61,
+	0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f,
+	0x64, 0x75, 0x63, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x72, 0x6f,
+	0x64, 0x75, 0x63, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63,
+	0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63,
+	0x65, 0x12, 0x20, 0x0a, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x2b, 0x0a, 0x04, 0x6d, 0x65, 0x74, 0x61, 0x18, 0x10, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b,
+	0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x04, 0x6d, 0x65,
+	0x74, 0x61, 0x1a, 0x3d, 0x0a, 0x0f, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x22, 0x50, 0x0a, 0x08, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x16, 0x0a,
+	0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6f,
+	0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x6f, 0x75,
+	0x72, 0x63, 0x65, 0x22, 0x3a, 0x0a, 0x0a, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x42, 0x61, 0x74, 0x63,
+	0x68, 0x12, 0x2c, 0x0a, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76,
+	0x31, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x22,
+	0x78, 0x0a, 0x0c, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x24, 0x0a, 0x0e, 0x6d,
+	0x61, 0x78, 0x5f, 0x62, 0x61, 0x74, 0x63, 0x68, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x0c, 0x6d, 0x61, 0x78, 0x42, 0x61, 0x74, 0x63, 0x68, 0x53, 0x69, 0x7a,
+	0x65, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x6c, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x22, 0x26, 0x0a, 0x0a, 0x54, 0x6f, 0x70,
+	0x69, 0x63, 0x51, 0x75, 0x65, 0x72, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x74, 0x74, 0x65,
+	0x72, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x70, 0x61, 0x74, 0x74, 0x65, 0x72,
+	0x6e, 0x22, 0x39, 0x0a, 0x09, 0x54, 0x6f, 0x70, 0x69, 0x63, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x2c,
+	0x0a, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14,
+	0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x54,
+	0x6f, 0x70, 0x69, 0x63, 0x52, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x22, 0x4b, 0x0a, 0x05,
+	0x54, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66,
+	0x73, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65,
+	0x74, 0x12, 0x16, 0x0a, 0x06, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x06, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x73, 0x22, 0x2e, 0x0a, 0x12, 0x54, 0x72, 0x61,
+	0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x18, 0x0a, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x22, 0x85, 0x02, 0x0a, 0x0b, 0x54, 0x72,
+	0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x35, 0x0a, 0x05, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1f, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63,
+	0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65,
+	0x12, 0x16, 0x0a, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x41, 0x0a, 0x07, 0x6f, 0x66, 0x66, 0x73,
+	0x65, 0x74, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x73, 0x77, 0x69, 0x74,
+	0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x52, 0x07, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x65,
+	0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x65, 0x78,
+	0x70, 0x69, 0x72, 0x65, 0x73, 0x1a, 0x3a, 0x0a, 0x0c, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x22, 0x87, 0x01, 0x0a, 0x0c, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x50, 0x75, 0x62, 0x6c, 0x69,
+	0x73, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x74, 0x6f,
+	0x70, 0x69, 0x63, 0x5f, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x0b, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x1c, 0x0a,
+	0x09, 0x63, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x09, 0x63, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x65, 0x72, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x64,
+	0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x0a, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x22, 0x0d, 0x0a, 0x0b, 0x48,
+	0x65, 0x61, 0x6c, 0x74, 0x68, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x22, 0x60, 0x0a, 0x12, 0x4d, 0x61,
+	0x69, 0x6e, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x18, 0x0a, 0x07, 0x65, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x07, 0x65, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x72,
+	0x61, 0x69, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x64, 0x72, 0x61, 0x69, 0x6e,
+	0x12, 0x1a, 0x0a, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x64, 0x65, 0x61, 0x64, 0x6c, 0x69, 0x6e, 0x65, 0x22, 0x99, 0x04, 0x0a,
+	0x0c, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x75, 0x70, 0x74, 0x69, 0x6d, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x75, 0x70, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x18, 0x0a,
+	0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63,
+	0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x12,
+	0x16, 0x0a, 0x06, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x06, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x75, 0x62, 0x6c, 0x69,
+	0x73, 0x68, 0x65, 0x72, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x70, 0x75, 0x62,
+	0x6c, 0x69, 0x73, 0x68, 0x65, 0x72, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x73, 0x75, 0x62, 0x73, 0x63,
+	0x72, 0x69, 0x62, 0x65, 0x72, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x73, 0x75,
+	0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x72, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x65, 0x76, 0x65,
+	0x6e, 0x74, 0x73, 0x5f, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x65, 0x64, 0x18, 0x08, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x0f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x50, 0x75, 0x62, 0x6c, 0x69,
+	0x73, 0x68, 0x65, 0x64, 0x12, 0x29, 0x0a, 0x10, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x5f, 0x64,
+	0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f,
+	0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x44, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x65, 0x64, 0x12,
+	0x21, 0x0a, 0x0c, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x5f, 0x72, 0x61, 0x74, 0x65, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x52, 0x61,
+	0x74, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x64, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x79, 0x5f, 0x72,
+	0x61, 0x74, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0c, 0x64, 0x65, 0x6c, 0x69, 0x76,
+	0x65, 0x72, 0x79, 0x52, 0x61, 0x74, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x74, 0x6f, 0x72, 0x61,
+	0x67, 0x65, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c,
+	0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x3e, 0x0a, 0x0a,
+	0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x6e, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x0d, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x1e, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31,
+	0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x6f, 0x6e, 0x65, 0x6e, 0x74, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68,
+	0x52, 0x0a, 0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x6e, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x36, 0x0a, 0x07,
+	0x6d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18, 0x0e, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e,
+	0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6c,
+	0x75, 0x73, 0x74, 0x65, 0x72, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x52, 0x07, 0x6d, 0x65, 0x6d,
+	0x62, 0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x18, 0x0f, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x04, 0x74, 0x65, 0x72, 0x6d, 0x22, 0x83, 0x01, 0x0a, 0x0d, 0x43, 0x6c, 0x75,
+	0x73, 0x74, 0x65, 0x72, 0x4d, 0x65, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64,
+	0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64,
+	0x72, 0x65, 0x73, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74,
+	0x12, 0x16, 0x0a, 0x06, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x06, 0x6c, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x6f, 0x74, 0x65,
+	0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x76, 0x6f, 0x74, 0x65, 0x72, 0x22, 0x57,
+	0x0a, 0x0f, 0x43, 0x6f, 0x6d, 0x70, 0x6f, 0x6e, 0x65, 0x6e, 0x74, 0x48, 0x65, 0x61, 0x6c, 0x74,
+	0x68, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a,
+	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0xff, 0x01, 0x0a, 0x06, 0x53, 0x63, 0x68, 0x65,
+	0x6d, 0x61, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x2d, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62,
+	0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x54, 0x79, 0x70,
+	0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x64, 0x65, 0x66, 0x69, 0x6e,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x64, 0x65, 0x66,
+	0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x12, 0x42, 0x0a, 0x0d, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x69, 0x62, 0x69, 0x6c, 0x69,
+	0x74, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1c, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63,
+	0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x69,
+	0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x52, 0x0d, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x69, 0x62,
+	0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x0e, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x18, 0x0a, 0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x22, 0x3d, 0x0a, 0x0b, 0x53, 0x63, 0x68,
+	0x65, 0x6d, 0x61, 0x51, 0x75, 0x65, 0x72, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69,
+	0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x18,
+	0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x3d, 0x0a, 0x0a, 0x53, 0x63, 0x68, 0x65,
+	0x6d, 0x61, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x2f, 0x0a, 0x07, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68,
+	0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x52, 0x07,
+	0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x73, 0x2a, 0x51, 0x0a, 0x10, 0x54, 0x72, 0x61, 0x6e, 0x73,
+	0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x17, 0x0a, 0x13, 0x55,
+	0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x54, 0x52, 0x41, 0x4e, 0x53, 0x41, 0x43, 0x54, 0x49,
+	0x4f, 0x4e, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x4f, 0x50, 0x45, 0x4e, 0x10, 0x01, 0x12, 0x0d,
+	0x0a, 0x09, 0x43, 0x4f, 0x4d, 0x4d, 0x49, 0x54, 0x54, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0b, 0x0a,
+	0x07, 0x41, 0x42, 0x4f, 0x52, 0x54, 0x45, 0x44, 0x10, 0x03, 0x2a, 0x3f, 0x0a, 0x0a, 0x53, 0x63,
+	0x68, 0x65, 0x6d, 0x61, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x0e, 0x55, 0x4e, 0x4b, 0x4e,
+	0x4f, 0x57, 0x4e, 0x5f, 0x53, 0x43, 0x48, 0x45, 0x4d, 0x41, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08,
+	0x50, 0x52, 0x4f, 0x54, 0x4f, 0x42, 0x55, 0x46, 0x10, 0x01, 0x12, 0x0f, 0x0a, 0x0b, 0x4a, 0x53,
+	0x4f, 0x4e, 0x5f, 0x53, 0x43, 0x48, 0x45, 0x4d, 0x41, 0x10, 0x02, 0x2a, 0x3e, 0x0a, 0x0d, 0x43,
+	0x6f, 0x6d, 0x70, 0x61, 0x74, 0x69, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x08, 0x0a, 0x04,
+	0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x42, 0x41, 0x43, 0x4b, 0x57, 0x41,
+	0x52, 0x44, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x46, 0x4f, 0x52, 0x57, 0x41, 0x52, 0x44, 0x10,
+	0x02, 0x12, 0x08, 0x0a, 0x04, 0x46, 0x55, 0x4c, 0x4c, 0x10, 0x03, 0x32, 0xc5, 0x07, 0x0a, 0x0a,
+	0x53, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x12, 0x40, 0x0a, 0x07, 0x50, 0x75,
+	0x62, 0x6c, 0x69, 0x73, 0x68, 0x12, 0x14, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61,
+	0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x1a, 0x1b, 0x2e, 0x73, 0x77,
+	0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6c, 0x6f, 0x73,
+	0x65, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x22, 0x00, 0x28, 0x01, 0x12, 0x42, 0x0a, 0x09,
+	0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x12, 0x1b, 0x2e, 0x73, 0x77, 0x69, 0x74,
+	0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72,
+	0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x1a, 0x14, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62,
+	0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x22, 0x00, 0x30, 0x01,
+	0x12, 0x4a, 0x0a, 0x0c, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x42, 0x61, 0x74, 0x63, 0x68,
+	0x12, 0x19, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31,
+	0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x1a, 0x1b, 0x2e, 0x73, 0x77,
+	0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6c, 0x6f, 0x73,
+	0x65, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x22, 0x00, 0x28, 0x01, 0x12, 0x4c, 0x0a, 0x0e,
+	0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x42, 0x61, 0x74, 0x63, 0x68, 0x12, 0x1b,
+	0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53,
+	0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x1a, 0x19, 0x2e, 0x73, 0x77,
+	0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x22, 0x00, 0x30, 0x01, 0x12, 0x43, 0x0a, 0x06, 0x53, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63,
+	0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x43, 0x68, 0x65, 0x63, 0x6b,
+	0x1a, 0x1b, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31,
+	0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x22, 0x00, 0x12,
+	0x53, 0x0a, 0x10, 0x42, 0x65, 0x67, 0x69, 0x6e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x21, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b,
+	0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62,
+	0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x22, 0x00, 0x12, 0x4d, 0x0a, 0x11, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x54, 0x72,
+	0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x2e, 0x73, 0x77, 0x69, 0x74,
+	0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x1a, 0x1a, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61,
+	0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x22, 0x00, 0x12, 0x4c, 0x0a, 0x10, 0x41, 0x62, 0x6f, 0x72, 0x74, 0x54, 0x72, 0x61, 0x6e,
+	0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68,
+	0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x1a, 0x1a, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b,
+	0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x22,
+	0x00, 0x12, 0x43, 0x0a, 0x0a, 0x4c, 0x69, 0x73, 0x74, 0x54, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x12,
+	0x19, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e,
+	0x54, 0x6f, 0x70, 0x69, 0x63, 0x51, 0x75, 0x65, 0x72, 0x79, 0x1a, 0x18, 0x2e, 0x73, 0x77, 0x69,
+	0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x6f, 0x70, 0x69, 0x63,
+	0x4c, 0x69, 0x73, 0x74, 0x22, 0x00, 0x12, 0x4f, 0x0a, 0x0b, 0x4d, 0x61, 0x69, 0x6e, 0x74, 0x65,
+	0x6e, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x21, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61,
+	0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x61, 0x69, 0x6e, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x63,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63,
+	0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x22, 0x00, 0x12, 0x40, 0x0a, 0x0e, 0x52, 0x65, 0x67, 0x69, 0x73,
+	0x74, 0x65, 0x72, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x15, 0x2e, 0x73, 0x77, 0x69, 0x74,
+	0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61,
+	0x1a, 0x15, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31,
+	0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x22, 0x00, 0x12, 0x40, 0x0a, 0x09, 0x47, 0x65, 0x74,
+	0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x12, 0x1a, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62,
+	0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x51, 0x75, 0x65,
+	0x72, 0x79, 0x1a, 0x15, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e,
+	0x76, 0x31, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x22, 0x00, 0x12, 0x46, 0x0a, 0x0b, 0x4c,
+	0x69, 0x73, 0x74, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x73, 0x12, 0x1a, 0x2e, 0x73, 0x77, 0x69,
+	0x74, 0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d,
+	0x61, 0x51, 0x75, 0x65, 0x72, 0x79, 0x1a, 0x19, 0x2e, 0x73, 0x77, 0x69, 0x74, 0x63, 0x68, 0x62,
+	0x61, 0x63, 0x6b, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x4c, 0x69, 0x73,
+	0x74, 0x22, 0x00, 0x42, 0x30, 0x5a, 0x2e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2f, 0x62, 0x62, 0x65, 0x6e, 0x67, 0x66, 0x6f, 0x72, 0x74, 0x2f, 0x73, 0x77, 0x69, 0x74,
+	0x63, 0x68, 0x62, 0x61, 0x63, 0x6b, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x76,
+	0x31, 0x3b, 0x61, 0x70, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_switchback_v1_switchback_proto_rawDescOnce sync.Once
+	file_switchback_v1_switchback_proto_rawDescData = file_switchback_v1_switchback_proto_rawDesc
+)
+
+func file_switchback_v1_switchback_proto_rawDescGZIP() []byte {
+	file_switchback_v1_switchback_proto_rawDescOnce.Do(func() {
+		file_switchback_v1_switchback_proto_rawDescData = protoimpl.X.CompressGZIP(file_switchback_v1_switchback_proto_rawDescData)
+	})
+	return file_switchback_v1_switchback_proto_rawDescData
+}
+
+var file_switchback_v1_switchback_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_switchback_v1_switchback_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_switchback_v1_switchback_proto_goTypes = []interface{}{
+	(TransactionState)(0),      // 0: switchback.v1.TransactionState
+	(SchemaType)(0),            // 1: switchback.v1.SchemaType
+	(Compatibility)(0),         // 2: switchback.v1.Compatibility
+	(*Event)(nil),              // 3: switchback.v1.Event
+	(*Metadata)(nil),           // 4: switchback.v1.Metadata
+	(*EventBatch)(nil),         // 5: switchback.v1.EventBatch
+	(*Subscription)(nil),       // 6: switchback.v1.Subscription
+	(*TopicQuery)(nil),         // 7: switchback.v1.TopicQuery
+	(*TopicList)(nil),          // 8: switchback.v1.TopicList
+	(*Topic)(nil),              // 9: switchback.v1.Topic
+	(*TransactionRequest)(nil), // 10: switchback.v1.TransactionRequest
+	(*Transaction)(nil),        // 11: switchback.v1.Transaction
+	(*ClosePublish)(nil),       // 12: switchback.v1.ClosePublish
+	(*HealthCheck)(nil),        // 13: switchback.v1.HealthCheck
+	(*MaintenanceRequest)(nil), // 14: switchback.v1.MaintenanceRequest
+	(*ServiceState)(nil),       // 15: switchback.v1.ServiceState
+	(*ClusterMember)(nil),      // 16: switchback.v1.ClusterMember
+	(*ComponentHealth)(nil),    // 17: switchback.v1.ComponentHealth
+	(*Schema)(nil),             // 18: switchback.v1.Schema
+	(*SchemaQuery)(nil),        // 19: switchback.v1.SchemaQuery
+	(*SchemaList)(nil),         // 20: switchback.v1.SchemaList
+	nil,                        // 21: switchback.v1.Event.AttributesEntry
+	nil,                        // 22: switchback.v1.Transaction.OffsetsEntry
+}
+var file_switchback_v1_switchback_proto_depIdxs = []int32{
+	21, // 0: switchback.v1.Event.attributes:type_name -> switchback.v1.Event.AttributesEntry
+	4,  // 1: switchback.v1.Event.meta:type_name -> switchback.v1.Metadata
+	3,  // 2: switchback.v1.EventBatch.events:type_name -> switchback.v1.Event
+	9,  // 3: switchback.v1.TopicList.topics:type_name -> switchback.v1.Topic
+	0,  // 4: switchback.v1.Transaction.state:type_name -> switchback.v1.TransactionState
+	22, // 5: switchback.v1.Transaction.offsets:type_name -> switchback.v1.Transaction.OffsetsEntry
+	17, // 6: switchback.v1.ServiceState.components:type_name -> switchback.v1.ComponentHealth
+	16, // 7: switchback.v1.ServiceState.members:type_name -> switchback.v1.ClusterMember
+	1,  // 8: switchback.v1.Schema.type:type_name -> switchback.v1.SchemaType
+	2,  // 9: switchback.v1.Schema.compatibility:type_name -> switchback.v1.Compatibility
+	18, // 10: switchback.v1.SchemaList.schemas:type_name -> switchback.v1.Schema
+	3,  // 11: switchback.v1.Switchback.Publish:input_type -> switchback.v1.Event
+	6,  // 12: switchback.v1.Switchback.Subscribe:input_type -> switchback.v1.Subscription
+	5,  // 13: switchback.v1.Switchback.PublishBatch:input_type -> switchback.v1.EventBatch
+	6,  // 14: switchback.v1.Switchback.SubscribeBatch:input_type -> switchback.v1.Subscription
+	13, // 15: switchback.v1.Switchback.Status:input_type -> switchback.v1.HealthCheck
+	10, // 16: switchback.v1.Switchback.BeginTransaction:input_type -> switchback.v1.TransactionRequest
+	11, // 17: switchback.v1.Switchback.CommitTransaction:input_type -> switchback.v1.Transaction
+	11, // 18: switchback.v1.Switchback.AbortTransaction:input_type -> switchback.v1.Transaction
+	7,  // 19: switchback.v1.Switchback.ListTopics:input_type -> switchback.v1.TopicQuery
+	14, // 20: switchback.v1.Switchback.Maintenance:input_type -> switchback.v1.MaintenanceRequest
+	18, // 21: switchback.v1.Switchback.RegisterSchema:input_type -> switchback.v1.Schema
+	19, // 22: switchback.v1.Switchback.GetSchema:input_type -> switchback.v1.SchemaQuery
+	19, // 23: switchback.v1.Switchback.ListSchemas:input_type -> switchback.v1.SchemaQuery
+	12, // 24: switchback.v1.Switchback.Publish:output_type -> switchback.v1.ClosePublish
+	3,  // 25: switchback.v1.Switchback.Subscribe:output_type -> switchback.v1.Event
+	12, // 26: switchback.v1.Switchback.PublishBatch:output_type -> switchback.v1.ClosePublish
+	5,  // 27: switchback.v1.Switchback.SubscribeBatch:output_type -> switchback.v1.EventBatch
+	15, // 28: switchback.v1.Switchback.Status:output_type -> switchback.v1.ServiceState
+	11, // 29: switchback.v1.Switchback.BeginTransaction:output_type -> switchback.v1.Transaction
+	11, // 30: switchback.v1.Switchback.CommitTransaction:output_type -> switchback.v1.Transaction
+	11, // 31: switchback.v1.Switchback.AbortTransaction:output_type -> switchback.v1.Transaction
+	8,  // 32: switchback.v1.Switchback.ListTopics:output_type -> switchback.v1.TopicList
+	15, // 33: switchback.v1.Switchback.Maintenance:output_type -> switchback.v1.ServiceState
+	18, // 34: switchback.v1.Switchback.RegisterSchema:output_type -> switchback.v1.Schema
+	18, // 35: switchback.v1.Switchback.GetSchema:output_type -> switchback.v1.Schema
+	20, // 36: switchback.v1.Switchback.ListSchemas:output_type -> switchback.v1.SchemaList
+	24, // [24:37] is the sub-list for method output_type
+	11, // [11:24] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_switchback_v1_switchback_proto_init() }
+func file_switchback_v1_switchback_proto_init() {
+	if File_switchback_v1_switchback_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_switchback_v1_switchback_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Event); i {
 			case 0:
 				return &v.state
@@ -487,7 +1775,7 @@ func file_switchback_v1_switchback_proto_init() {
 			}
 		}
 		file_switchback_v1_switchback_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Subscription); i {
+			switch v := v.(*EventBatch); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -499,7 +1787,7 @@ func file_switchback_v1_switchback_proto_init() {
 			}
 		}
 		file_switchback_v1_switchback_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ClosePublish); i {
+			switch v := v.(*Subscription); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -511,7 +1799,7 @@ func file_switchback_v1_switchback_proto_init() {
 			}
 		}
 		file_switchback_v1_switchback_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*HealthCheck); i {
+			switch v := v.(*TopicQuery); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -523,6 +1811,90 @@ func file_switchback_v1_switchback_proto_init() {
 			}
 		}
 		file_switchback_v1_switchback_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TopicList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Topic); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TransactionRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Transaction); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClosePublish); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HealthCheck); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MaintenanceRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ServiceState); i {
 			case 0:
 				return &v.state
@@ -534,19 +1906,80 @@ func file_switchback_v1_switchback_proto_init() {
 				return nil
 			}
 		}
+		file_switchback_v1_switchback_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClusterMember); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ComponentHealth); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Schema); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SchemaQuery); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_switchback_v1_switchback_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SchemaList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_switchback_v1_switchback_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   6,
+			NumEnums:      3,
+			NumMessages:   20,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_switchback_v1_switchback_proto_goTypes,
 		DependencyIndexes: file_switchback_v1_switchback_proto_depIdxs,
+		EnumInfos:         file_switchback_v1_switchback_proto_enumTypes,
 		MessageInfos:      file_switchback_v1_switchback_proto_msgTypes,
 	}.Build()
 	File_switchback_v1_switchback_proto = out.File
@@ -20,7 +20,24 @@ const _ = grpc.SupportPackageIsVersion7
 type SwitchbackClient interface {
 	Publish(ctx context.Context, opts ...grpc.CallOption) (Switchback_PublishClient, error)
 	Subscribe(ctx context.Context, in *Subscription, opts ...grpc.CallOption) (Switchback_SubscribeClient, error)
+	// Publish and subscribe with many events per message; subscriptions are batched by
+	// the server according to the max batch size and linger of the subscription.
+	PublishBatch(ctx context.Context, opts ...grpc.CallOption) (Switchback_PublishBatchClient, error)
+	SubscribeBatch(ctx context.Context, in *Subscription, opts ...grpc.CallOption) (Switchback_SubscribeBatchClient, error)
 	Status(ctx context.Context, in *HealthCheck, opts ...grpc.CallOption) (*ServiceState, error)
+	// Transactions: events published with the id of an open transaction are held by the
+	// server and delivered to consumers atomically when the transaction is committed.
+	BeginTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*Transaction, error)
+	CommitTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error)
+	AbortTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error)
+	// List the topics on the server that the caller has access to.
+	ListTopics(ctx context.Context, in *TopicQuery, opts ...grpc.CallOption) (*TopicList, error)
+	// Admin: enter or exit maintenance mode at runtime, optionally draining open streams.
+	Maintenance(ctx context.Context, in *MaintenanceRequest, opts ...grpc.CallOption) (*ServiceState, error)
+	// Schema registry: bind topics to a schema that published events are validated against.
+	RegisterSchema(ctx context.Context, in *Schema, opts ...grpc.CallOption) (*Schema, error)
+	GetSchema(ctx context.Context, in *SchemaQuery, opts ...grpc.CallOption) (*Schema, error)
+	ListSchemas(ctx context.Context, in *SchemaQuery, opts ...grpc.CallOption) (*SchemaList, error)
 }
 
 type switchbackClient struct {
@@ -97,6 +114,72 @@ func (x *switchbackSubscribeClient) Recv() (*Event, error) {
 	return m, nil
 }
 
+func (c *switchbackClient) PublishBatch(ctx context.Context, opts ...grpc.CallOption) (Switchback_PublishBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Switchback_ServiceDesc.Streams[2], "/switchback.v1.Switchback/PublishBatch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &switchbackPublishBatchClient{stream}
+	return x, nil
+}
+
+type Switchback_PublishBatchClient interface {
+	Send(*EventBatch) error
+	CloseAndRecv() (*ClosePublish, error)
+	grpc.ClientStream
+}
+
+type switchbackPublishBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *switchbackPublishBatchClient) Send(m *EventBatch) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *switchbackPublishBatchClient) CloseAndRecv() (*ClosePublish, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ClosePublish)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *switchbackClient) SubscribeBatch(ctx context.Context, in *Subscription, opts ...grpc.CallOption) (Switchback_SubscribeBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Switchback_ServiceDesc.Streams[3], "/switchback.v1.Switchback/SubscribeBatch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &switchbackSubscribeBatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Switchback_SubscribeBatchClient interface {
+	Recv() (*EventBatch, error)
+	grpc.ClientStream
+}
+
+type switchbackSubscribeBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *switchbackSubscribeBatchClient) Recv() (*EventBatch, error) {
+	m := new(EventBatch)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *switchbackClient) Status(ctx context.Context, in *HealthCheck, opts ...grpc.CallOption) (*ServiceState, error) {
 	out := new(ServiceState)
 	err := c.cc.Invoke(ctx, "/switchback.v1.Switchback/Status", in, out, opts...)
@@ -106,13 +189,102 @@ func (c *switchbackClient) Status(ctx context.Context, in *HealthCheck, opts ...
 	return out, nil
 }
 
+func (c *switchbackClient) BeginTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, "/switchback.v1.Switchback/BeginTransaction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *switchbackClient) CommitTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, "/switchback.v1.Switchback/CommitTransaction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *switchbackClient) AbortTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, "/switchback.v1.Switchback/AbortTransaction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *switchbackClient) ListTopics(ctx context.Context, in *TopicQuery, opts ...grpc.CallOption) (*TopicList, error) {
+	out := new(TopicList)
+	err := c.cc.Invoke(ctx, "/switchback.v1.Switchback/ListTopics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *switchbackClient) Maintenance(ctx context.Context, in *MaintenanceRequest, opts ...grpc.CallOption) (*ServiceState, error) {
+	out := new(ServiceState)
+	err := c.cc.Invoke(ctx, "/switchback.v1.Switchback/Maintenance", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *switchbackClient) RegisterSchema(ctx context.Context, in *Schema, opts ...grpc.CallOption) (*Schema, error) {
+	out := new(Schema)
+	err := c.cc.Invoke(ctx, "/switchback.v1.Switchback/RegisterSchema", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *switchbackClient) GetSchema(ctx context.Context, in *SchemaQuery, opts ...grpc.CallOption) (*Schema, error) {
+	out := new(Schema)
+	err := c.cc.Invoke(ctx, "/switchback.v1.Switchback/GetSchema", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *switchbackClient) ListSchemas(ctx context.Context, in *SchemaQuery, opts ...grpc.CallOption) (*SchemaList, error) {
+	out := new(SchemaList)
+	err := c.cc.Invoke(ctx, "/switchback.v1.Switchback/ListSchemas", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SwitchbackServer is the server API for Switchback service.
 // All implementations must embed UnimplementedSwitchbackServer
 // for forward compatibility
 type SwitchbackServer interface {
 	Publish(Switchback_PublishServer) error
 	Subscribe(*Subscription, Switchback_SubscribeServer) error
+	// Publish and subscribe with many events per message; subscriptions are batched by
+	// the server according to the max batch size and linger of the subscription.
+	PublishBatch(Switchback_PublishBatchServer) error
+	SubscribeBatch(*Subscription, Switchback_SubscribeBatchServer) error
 	Status(context.Context, *HealthCheck) (*ServiceState, error)
+	// Transactions: events published with the id of an open transaction are held by the
+	// server and delivered to consumers atomically when the transaction is committed.
+	BeginTransaction(context.Context, *TransactionRequest) (*Transaction, error)
+	CommitTransaction(context.Context, *Transaction) (*Transaction, error)
+	AbortTransaction(context.Context, *Transaction) (*Transaction, error)
+	// List the topics on the server that the caller has access to.
+	ListTopics(context.Context, *TopicQuery) (*TopicList, error)
+	// Admin: enter or exit maintenance mode at runtime, optionally draining open streams.
+	Maintenance(context.Context, *MaintenanceRequest) (*ServiceState, error)
+	// Schema registry: bind topics to a schema that published events are validated against.
+	RegisterSchema(context.Context, *Schema) (*Schema, error)
+	GetSchema(context.Context, *SchemaQuery) (*Schema, error)
+	ListSchemas(context.Context, *SchemaQuery) (*SchemaList, error)
 	mustEmbedUnimplementedSwitchbackServer()
 }
 
@@ -126,9 +298,39 @@ func (UnimplementedSwitchbackServer) Publish(Switchback_PublishServer) error {
 func (UnimplementedSwitchbackServer) Subscribe(*Subscription, Switchback_SubscribeServer) error {
 	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
 }
+func (UnimplementedSwitchbackServer) PublishBatch(Switchback_PublishBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method PublishBatch not implemented")
+}
+func (UnimplementedSwitchbackServer) SubscribeBatch(*Subscription, Switchback_SubscribeBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeBatch not implemented")
+}
 func (UnimplementedSwitchbackServer) Status(context.Context, *HealthCheck) (*ServiceState, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
 }
+func (UnimplementedSwitchbackServer) BeginTransaction(context.Context, *TransactionRequest) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BeginTransaction not implemented")
+}
+func (UnimplementedSwitchbackServer) CommitTransaction(context.Context, *Transaction) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitTransaction not implemented")
+}
+func (UnimplementedSwitchbackServer) AbortTransaction(context.Context, *Transaction) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AbortTransaction not implemented")
+}
+func (UnimplementedSwitchbackServer) ListTopics(context.Context, *TopicQuery) (*TopicList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTopics not implemented")
+}
+func (UnimplementedSwitchbackServer) Maintenance(context.Context, *MaintenanceRequest) (*ServiceState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Maintenance not implemented")
+}
+func (UnimplementedSwitchbackServer) RegisterSchema(context.Context, *Schema) (*Schema, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterSchema not implemented")
+}
+func (UnimplementedSwitchbackServer) GetSchema(context.Context, *SchemaQuery) (*Schema, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSchema not implemented")
+}
+func (UnimplementedSwitchbackServer) ListSchemas(context.Context, *SchemaQuery) (*SchemaList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSchemas not implemented")
+}
 func (UnimplementedSwitchbackServer) mustEmbedUnimplementedSwitchbackServer() {}
 
 // UnsafeSwitchbackServer may be embedded to opt out of forward compatibility for this service.
@@ -189,6 +391,53 @@ func (x *switchbackSubscribeServer) Send(m *Event) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Switchback_PublishBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SwitchbackServer).PublishBatch(&switchbackPublishBatchServer{stream})
+}
+
+type Switchback_PublishBatchServer interface {
+	SendAndClose(*ClosePublish) error
+	Recv() (*EventBatch, error)
+	grpc.ServerStream
+}
+
+type switchbackPublishBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *switchbackPublishBatchServer) SendAndClose(m *ClosePublish) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *switchbackPublishBatchServer) Recv() (*EventBatch, error) {
+	m := new(EventBatch)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Switchback_SubscribeBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Subscription)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SwitchbackServer).SubscribeBatch(m, &switchbackSubscribeBatchServer{stream})
+}
+
+type Switchback_SubscribeBatchServer interface {
+	Send(*EventBatch) error
+	grpc.ServerStream
+}
+
+type switchbackSubscribeBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *switchbackSubscribeBatchServer) Send(m *EventBatch) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Switchback_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(HealthCheck)
 	if err := dec(in); err != nil {
@@ -207,6 +456,150 @@ func _Switchback_Status_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Switchback_BeginTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SwitchbackServer).BeginTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/switchback.v1.Switchback/BeginTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SwitchbackServer).BeginTransaction(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Switchback_CommitTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SwitchbackServer).CommitTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/switchback.v1.Switchback/CommitTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SwitchbackServer).CommitTransaction(ctx, req.(*Transaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Switchback_AbortTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SwitchbackServer).AbortTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/switchback.v1.Switchback/AbortTransaction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SwitchbackServer).AbortTransaction(ctx, req.(*Transaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Switchback_ListTopics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopicQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SwitchbackServer).ListTopics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/switchback.v1.Switchback/ListTopics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SwitchbackServer).ListTopics(ctx, req.(*TopicQuery))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Switchback_Maintenance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MaintenanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SwitchbackServer).Maintenance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/switchback.v1.Switchback/Maintenance",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SwitchbackServer).Maintenance(ctx, req.(*MaintenanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Switchback_RegisterSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Schema)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SwitchbackServer).RegisterSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/switchback.v1.Switchback/RegisterSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SwitchbackServer).RegisterSchema(ctx, req.(*Schema))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Switchback_GetSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SchemaQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SwitchbackServer).GetSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/switchback.v1.Switchback/GetSchema",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SwitchbackServer).GetSchema(ctx, req.(*SchemaQuery))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Switchback_ListSchemas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SchemaQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SwitchbackServer).ListSchemas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/switchback.v1.Switchback/ListSchemas",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SwitchbackServer).ListSchemas(ctx, req.(*SchemaQuery))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Switchback_ServiceDesc is the grpc.ServiceDesc for Switchback service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -218,6 +611,38 @@ var Switchback_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Status",
 			Handler:    _Switchback_Status_Handler,
 		},
+		{
+			MethodName: "BeginTransaction",
+			Handler:    _Switchback_BeginTransaction_Handler,
+		},
+		{
+			MethodName: "CommitTransaction",
+			Handler:    _Switchback_CommitTransaction_Handler,
+		},
+		{
+			MethodName: "AbortTransaction",
+			Handler:    _Switchback_AbortTransaction_Handler,
+		},
+		{
+			MethodName: "ListTopics",
+			Handler:    _Switchback_ListTopics_Handler,
+		},
+		{
+			MethodName: "Maintenance",
+			Handler:    _Switchback_Maintenance_Handler,
+		},
+		{
+			MethodName: "RegisterSchema",
+			Handler:    _Switchback_RegisterSchema_Handler,
+		},
+		{
+			MethodName: "GetSchema",
+			Handler:    _Switchback_GetSchema_Handler,
+		},
+		{
+			MethodName: "ListSchemas",
+			Handler:    _Switchback_ListSchemas_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -230,6 +655,16 @@ var Switchback_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Switchback_Subscribe_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "PublishBatch",
+			Handler:       _Switchback_PublishBatch_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SubscribeBatch",
+			Handler:       _Switchback_SubscribeBatch_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "switchback/v1/switchback.proto",
 }
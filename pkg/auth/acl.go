@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Permission is an action that a principal may be granted on a topic.
+type Permission uint8
+
+const (
+	Publish Permission = 1 << iota
+	Subscribe
+	Admin
+)
+
+func (p Permission) String() string {
+	names := make([]string, 0, 3)
+	if p&Publish != 0 {
+		names = append(names, "publish")
+	}
+	if p&Subscribe != 0 {
+		names = append(names, "subscribe")
+	}
+	if p&Admin != 0 {
+		names = append(names, "admin")
+	}
+	return strings.Join(names, "|")
+}
+
+// UnmarshalText allows permissions to be specified by name in JSON, YAML, and TOML.
+func (p *Permission) UnmarshalText(text []byte) error {
+	name := string(text)
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "publish":
+		*p = Publish
+	case "subscribe":
+		*p = Subscribe
+	case "admin":
+		*p = Admin
+	default:
+		return fmt.Errorf("unknown permission %q", name)
+	}
+	return nil
+}
+
+// Rule grants permissions to a principal on topics and consumer groups matching the
+// specified glob patterns (e.g. "orders.*"). A principal of "*" matches any
+// authenticated principal and empty topics or groups match any topic or group.
+type Rule struct {
+	Principal   string       `json:"principal" yaml:"principal" toml:"principal"`
+	Permissions []Permission `json:"permissions" yaml:"permissions" toml:"permissions"`
+	Topics      []string     `json:"topics" yaml:"topics" toml:"topics"`
+	Groups      []string     `json:"groups" yaml:"groups" toml:"groups"`
+}
+
+// ACL is a list of rules; a request is allowed if any rule grants the permission.
+type ACL []Rule
+
+// LoadACL reads a JSON list of rules from the specified path.
+func LoadACL(path string) (acl ACL, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, fmt.Errorf("could not read acl file: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &acl); err != nil {
+		return nil, fmt.Errorf("could not parse acl file: %w", err)
+	}
+
+	if err = acl.Validate(); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// Validate ensures that all of the patterns in the ACL are well formed.
+func (a ACL) Validate() (err error) {
+	for i, rule := range a {
+		if rule.Principal == "" {
+			return fmt.Errorf("acl rule %d has no principal", i)
+		}
+
+		for _, pattern := range append(append([]string{}, rule.Topics...), rule.Groups...) {
+			if _, err = path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("acl rule %d has invalid pattern %q: %w", i, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Allowed returns true if the principal has the permission on the topic and group.
+// Group is only checked for subscriptions and may be empty for other permissions.
+func (a ACL) Allowed(principal *Principal, perm Permission, topic, group string) bool {
+	for _, rule := range a {
+		if rule.Principal != "*" && (principal == nil || rule.Principal != principal.Name) {
+			continue
+		}
+
+		if !rule.grants(perm) {
+			continue
+		}
+
+		if perm == Admin {
+			return true
+		}
+
+		if !matchAny(rule.Topics, topic) {
+			continue
+		}
+
+		if perm == Subscribe && !matchAny(rule.Groups, group) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Visible returns true if the principal is granted any permission on the topic, e.g. so
+// that the topic can be listed regardless of the groups the principal may subscribe as.
+func (a ACL) Visible(principal *Principal, topic string) bool {
+	for _, rule := range a {
+		if rule.Principal != "*" && (principal == nil || rule.Principal != principal.Name) {
+			continue
+		}
+
+		if len(rule.Permissions) > 0 && matchAny(rule.Topics, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) grants(perm Permission) bool {
+	for _, p := range r.Permissions {
+		if p&perm != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+)
+
+// APIKeys authenticates requests with static bearer tokens mapped to principal names.
+type APIKeys map[string]string
+
+func (k APIKeys) Authenticate(ctx context.Context) (_ *Principal, err error) {
+	var token string
+	if token, err = BearerToken(ctx); err != nil {
+		return nil, err
+	}
+
+	for key, name := range k {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+			return &Principal{Name: name, Method: "apikey"}, nil
+		}
+	}
+	return nil, ErrNoCredentials
+}
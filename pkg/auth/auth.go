@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	ErrUnauthenticated = errors.New("could not authenticate request")
+	ErrNoCredentials   = errors.New("no credentials in request")
+)
+
+// Principal is the authenticated identity of the client making a request.
+type Principal struct {
+	Name   string
+	Method string
+}
+
+// Authenticator identifies the principal making a request from the request context,
+// e.g. from the gRPC metadata or the peer's TLS certificate. Authenticators should
+// return ErrNoCredentials if the request doesn't contain the credentials they verify
+// so that the next authenticator in a Chain can be tried.
+type Authenticator interface {
+	Authenticate(ctx context.Context) (*Principal, error)
+}
+
+// Chain tries each authenticator in order, returning the first principal identified.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context) (principal *Principal, err error) {
+	for _, authenticator := range c {
+		if principal, err = authenticator.Authenticate(ctx); err != nil {
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			return nil, err
+		}
+		return principal, nil
+	}
+	return nil, ErrNoCredentials
+}
+
+type principalKey struct{}
+
+// NewContext returns a new context that carries the authenticated principal.
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the authenticated principal stored in the context, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// BearerToken extracts the token from the authorization header of the request.
+func BearerToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", ErrNoCredentials
+	}
+
+	for _, value := range md.Get("authorization") {
+		if len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+			return strings.TrimSpace(value[7:]), nil
+		}
+	}
+	return "", ErrNoCredentials
+}
+
+// Token implements credentials.PerRPCCredentials to send a bearer token (an API key or
+// a JWT) with every request a client makes. The token is only sent on connections
+// secured with TLS; use InsecureToken to send it over a plaintext connection.
+type Token string
+
+func (t Token) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t Token) RequireTransportSecurity() bool {
+	return true
+}
+
+// InsecureToken sends a bearer token with every request even if the connection is not
+// secured with TLS, e.g. to a server on localhost during development.
+type InsecureToken string
+
+func (t InsecureToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return Token(t).GetRequestMetadata(ctx, uri...)
+}
+
+func (t InsecureToken) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,263 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc/metadata"
+)
+
+func bearer(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestACLAllowed(t *testing.T) {
+	acl := auth.ACL{
+		{Principal: "producer", Permissions: []auth.Permission{auth.Publish}, Topics: []string{"orders.*"}},
+		{Principal: "worker", Permissions: []auth.Permission{auth.Subscribe}, Topics: []string{"orders.*"}, Groups: []string{"workers", "batch.?"}},
+		{Principal: "*", Permissions: []auth.Permission{auth.Subscribe}, Topics: []string{"public"}},
+		{Principal: "operator", Permissions: []auth.Permission{auth.Admin}, Topics: []string{"none"}},
+	}
+
+	if err := acl.Validate(); err != nil {
+		t.Fatalf("expected acl to be valid: %s", err)
+	}
+
+	tests := []struct {
+		principal string
+		perm      auth.Permission
+		topic     string
+		group     string
+		allowed   bool
+	}{
+		{"producer", auth.Publish, "orders.created", "", true},
+		{"producer", auth.Publish, "orders", "", false},
+		{"producer", auth.Publish, "orders.created.eu", "", true}, // wildcards match dots
+		{"producer", auth.Subscribe, "orders.created", "workers", false},
+		{"worker", auth.Subscribe, "orders.created", "workers", true},
+		{"worker", auth.Subscribe, "orders.created", "batch.1", true},
+		{"worker", auth.Subscribe, "orders.created", "batch.10", false},
+		{"worker", auth.Subscribe, "orders.created", "", false},
+		{"worker", auth.Publish, "orders.created", "", false},
+		{"anyone", auth.Subscribe, "public", "anything", true},
+		{"anyone", auth.Subscribe, "orders.created", "workers", false},
+		{"", auth.Subscribe, "public", "anything", true},
+		{"operator", auth.Admin, "", "", true},
+		{"producer", auth.Admin, "", "", false},
+	}
+
+	for _, tc := range tests {
+		var principal *auth.Principal
+		if tc.principal != "" {
+			principal = &auth.Principal{Name: tc.principal}
+		}
+
+		if allowed := acl.Allowed(principal, tc.perm, tc.topic, tc.group); allowed != tc.allowed {
+			t.Errorf("expected %q %s on %q as %q to be allowed=%t", tc.principal, tc.perm, tc.topic, tc.group, tc.allowed)
+		}
+	}
+
+	if !acl.Visible(&auth.Principal{Name: "worker"}, "orders.created") || acl.Visible(&auth.Principal{Name: "worker"}, "payments") {
+		t.Error("expected topics to be visible only if the principal has a permission on them")
+	}
+}
+
+func TestACLValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		acl  auth.ACL
+	}{
+		{"no principal", auth.ACL{{Permissions: []auth.Permission{auth.Publish}}}},
+		{"bad topic", auth.ACL{{Principal: "*", Topics: []string{"orders.["}}}},
+		{"bad group", auth.ACL{{Principal: "*", Groups: []string{"[a-"}}}},
+	}
+
+	for _, tc := range tests {
+		if err := tc.acl.Validate(); err == nil {
+			t.Errorf("expected acl with %s to be invalid", tc.name)
+		}
+	}
+
+	var perm auth.Permission
+	if err := perm.UnmarshalText([]byte(" Subscribe ")); err != nil || perm != auth.Subscribe {
+		t.Errorf("expected subscribe permission, got %s (%v)", perm, err)
+	}
+
+	if err := perm.UnmarshalText([]byte("delete")); err == nil {
+		t.Error("expected unknown permission to be rejected")
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	keys := auth.APIKeys{"s3cr3t": "producer", "0th3r": "worker"}
+	tests := []struct {
+		ctx       context.Context
+		principal string
+		err       error
+	}{
+		{bearer("s3cr3t"), "producer", nil},
+		{bearer("0th3r"), "worker", nil},
+		{bearer("s3cr3"), "", auth.ErrNoCredentials},
+		{bearer(""), "", auth.ErrNoCredentials},
+		{context.Background(), "", auth.ErrNoCredentials},
+		{metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Basic s3cr3t")), "", auth.ErrNoCredentials},
+	}
+
+	for i, tc := range tests {
+		principal, err := keys.Authenticate(tc.ctx)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("test %d: expected error %v, got %v", i, tc.err, err)
+			continue
+		}
+
+		if tc.err == nil && (principal.Name != tc.principal || principal.Method != "apikey") {
+			t.Errorf("test %d: expected principal %q, got %+v", i, tc.principal, principal)
+		}
+	}
+}
+
+func TestJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %s", err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %s", err)
+	}
+
+	// Only the first key is in the key set
+	data, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("could not write jwks: %s", err)
+	}
+
+	authn, err := auth.NewJWT(path, "https://auth.example.com", "switchback")
+	if err != nil {
+		t.Fatalf("could not load jwks: %s", err)
+	}
+
+	valid := func() jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Subject:   "producer",
+			Issuer:    "https://auth.example.com",
+			Audience:  jwt.ClaimStrings{"switchback"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+	}
+
+	sign := func(claims jwt.RegisteredClaims, method jwt.SigningMethod, kid string, signer interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+
+		tks, err := token.SignedString(signer)
+		if err != nil {
+			t.Fatalf("could not sign token: %s", err)
+		}
+		return tks
+	}
+
+	expired, issuer, audience, subject := valid(), valid(), valid(), valid()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	issuer.Issuer = "https://evil.example.com"
+	audience.Audience = jwt.ClaimStrings{"other"}
+	subject.Subject = ""
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", sign(valid(), jwt.SigningMethodRS256, "k1", key), nil},
+		{"no kid with one key", sign(valid(), jwt.SigningMethodRS256, "", key), nil},
+		{"expired", sign(expired, jwt.SigningMethodRS256, "k1", key), auth.ErrUnauthenticated},
+		{"wrong issuer", sign(issuer, jwt.SigningMethodRS256, "k1", key), auth.ErrUnauthenticated},
+		{"wrong audience", sign(audience, jwt.SigningMethodRS256, "k1", key), auth.ErrUnauthenticated},
+		{"no subject", sign(subject, jwt.SigningMethodRS256, "k1", key), auth.ErrUnauthenticated},
+		{"bad key", sign(valid(), jwt.SigningMethodRS256, "k1", other), auth.ErrUnauthenticated},
+		{"unknown kid", sign(valid(), jwt.SigningMethodRS256, "k2", key), auth.ErrUnauthenticated},
+		{"hmac", sign(valid(), jwt.SigningMethodHS256, "k1", []byte("s3cr3t")), auth.ErrUnauthenticated},
+		{"api key", "s3cr3t", auth.ErrNoCredentials},
+	}
+
+	for _, tc := range tests {
+		principal, err := authn.Authenticate(bearer(tc.token))
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.err, err)
+			continue
+		}
+
+		if tc.err == nil && (principal.Name != "producer" || principal.Method != "jwt") {
+			t.Errorf("%s: unexpected principal %+v", tc.name, principal)
+		}
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not json", "keys"},
+		{"no keys", `{"keys": []}`},
+		{"encryption keys only", `{"keys": [{"kty": "RSA", "kid": "k1", "use": "enc", "n": "AQAB", "e": "AQAB"}]}`},
+		{"bad modulus", `{"keys": [{"kty": "RSA", "kid": "k1", "n": "!!", "e": "AQAB"}]}`},
+		{"unsupported curve", `{"keys": [{"kty": "EC", "kid": "k1", "crv": "P-192", "x": "AQAB", "y": "AQAB"}]}`},
+	}
+
+	for _, tc := range tests {
+		if _, err := auth.ParseJWKS([]byte(tc.data)); err == nil {
+			t.Errorf("expected jwks with %s to be rejected", tc.name)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := auth.Chain{auth.APIKeys{"s3cr3t": "producer"}, auth.MTLS{}}
+	if principal, err := chain.Authenticate(bearer("s3cr3t")); err != nil || principal.Name != "producer" {
+		t.Errorf("expected the api key to authenticate the request, got %+v (%v)", principal, err)
+	}
+
+	if _, err := chain.Authenticate(bearer("unknown")); !errors.Is(err, auth.ErrNoCredentials) {
+		t.Errorf("expected no credentials, got %v", err)
+	}
+}
+
+func TestTokenTransportSecurity(t *testing.T) {
+	if !auth.Token("s3cr3t").RequireTransportSecurity() {
+		t.Error("expected tokens to require transport security")
+	}
+
+	if auth.InsecureToken("s3cr3t").RequireTransportSecurity() {
+		t.Error("expected insecure tokens to allow plaintext connections")
+	}
+
+	md, err := auth.InsecureToken("s3cr3t").GetRequestMetadata(context.Background())
+	if err != nil || md["authorization"] != "Bearer s3cr3t" {
+		t.Errorf("unexpected request metadata %v (%v)", md, err)
+	}
+}
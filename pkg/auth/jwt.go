@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// JWT authenticates requests with bearer tokens signed by one of the keys in a local
+// JWKS file. The subject of the token is used as the principal name.
+type JWT struct {
+	keys     map[string]crypto.PublicKey
+	issuer   string
+	audience string
+}
+
+// NewJWT loads the public keys from the JWKS file at the specified path. If issuer or
+// audience are not empty then tokens must have matching iss and aud claims.
+func NewJWT(path, issuer, audience string) (_ *JWT, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, fmt.Errorf("could not read jwks file: %w", err)
+	}
+
+	auth := &JWT{issuer: issuer, audience: audience}
+	if auth.keys, err = ParseJWKS(data); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+func (a *JWT) Authenticate(ctx context.Context) (_ *Principal, err error) {
+	var tks string
+	if tks, err = BearerToken(ctx); err != nil {
+		return nil, err
+	}
+
+	// Static API keys are also bearer tokens; only attempt to verify tokens that look
+	// like a JWT so that other authenticators can be tried.
+	if strings.Count(tks, ".") != 2 {
+		return nil, ErrNoCredentials
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	if _, err = jwt.ParseWithClaims(tks, claims, a.keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnauthenticated, err)
+	}
+
+	if a.issuer != "" && !claims.VerifyIssuer(a.issuer, true) {
+		return nil, fmt.Errorf("%w: invalid issuer", ErrUnauthenticated)
+	}
+
+	if a.audience != "" && !claims.VerifyAudience(a.audience, true) {
+		return nil, fmt.Errorf("%w: invalid audience", ErrUnauthenticated)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrUnauthenticated)
+	}
+	return &Principal{Name: claims.Subject, Method: "jwt"}, nil
+}
+
+func (a *JWT) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+	default:
+		return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+
+	// If the token does not specify a key id and there is only one key, use it
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	} `json:"keys"`
+}
+
+// ParseJWKS parses the RSA and EC public keys in a JSON Web Key Set by key id.
+func ParseJWKS(data []byte) (keys map[string]crypto.PublicKey, err error) {
+	var set jwks
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("could not parse jwks: %w", err)
+	}
+
+	keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		switch key.Kty {
+		case "RSA":
+			var n, e []byte
+			if n, err = base64.RawURLEncoding.DecodeString(key.N); err != nil {
+				return nil, fmt.Errorf("invalid rsa key %q: %w", key.Kid, err)
+			}
+			if e, err = base64.RawURLEncoding.DecodeString(key.E); err != nil {
+				return nil, fmt.Errorf("invalid rsa key %q: %w", key.Kid, err)
+			}
+			keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch key.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				return nil, fmt.Errorf("unsupported curve %q for key %q", key.Crv, key.Kid)
+			}
+
+			var x, y []byte
+			if x, err = base64.RawURLEncoding.DecodeString(key.X); err != nil {
+				return nil, fmt.Errorf("invalid ec key %q: %w", key.Kid, err)
+			}
+			if y, err = base64.RawURLEncoding.DecodeString(key.Y); err != nil {
+				return nil, fmt.Errorf("invalid ec key %q: %w", key.Kid, err)
+			}
+			keys[key.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		default:
+			continue
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found in jwks")
+	}
+	return keys, nil
+}
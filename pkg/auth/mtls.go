@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// MTLS authenticates requests using the common name of the verified client certificate
+// presented during the mutual TLS handshake.
+type MTLS struct{}
+
+func (MTLS) Authenticate(ctx context.Context) (*Principal, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	cert := info.State.VerifiedChains[0][0]
+	if cert.Subject.CommonName == "" {
+		return nil, ErrUnauthenticated
+	}
+	return &Principal{Name: cert.Subject.CommonName, Method: "mtls"}, nil
+}
@@ -0,0 +1,219 @@
+package switchback
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// PublishBatch publishes the events of each batch in order, as though they had been sent
+// individually on a Publish stream. Batches are limited to the max batch size in events
+// and to the max batch bytes when serialized; larger messages are rejected by the
+// transport as resource exhausted.
+func (s *Server) PublishBatch(stream api.Switchback_PublishBatchServer) error {
+	return s.publishStream(stream, func() ([]*api.Event, error) {
+		batch, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(batch.Events) > s.conf.Events.MaxBatchSize {
+			return nil, status.Errorf(codes.InvalidArgument, "batch has %d events, exceeding the maximum of %d", len(batch.Events), s.conf.Events.MaxBatchSize)
+		}
+		return batch.Events, nil
+	})
+}
+
+// SubscribeBatch sends the events queued for the subscriber in batches of up to the max
+// batch size of the subscription, waiting up to its linger for a batch to fill.
+func (s *Server) SubscribeBatch(in *api.Subscription, stream api.Switchback_SubscribeBatchServer) (err error) {
+	size := s.conf.Events.MaxBatchSize
+	if in.MaxBatchSize > 0 && int(in.MaxBatchSize) < size {
+		size = int(in.MaxBatchSize)
+	}
+
+	var linger time.Duration
+	if in.Linger != "" {
+		if linger, err = time.ParseDuration(in.Linger); err != nil || linger < 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid linger %q", in.Linger)
+		}
+
+		if linger > s.conf.Events.MaxLinger {
+			linger = s.conf.Events.MaxLinger
+		}
+	}
+	return s.subscribe(in, batchStream{stream, size, linger})
+}
+
+// subscriber is the server side of a Subscribe or SubscribeBatch stream.
+type subscriber interface {
+	Context() context.Context
+
+	// send the events to the subscriber in order.
+	send(events []*api.Event) error
+
+	// batcher returns a batcher that collects events for the subscriber; batches are
+	// limited to the specified number of bytes.
+	batcher(bytes int) *batcher
+
+	// proxy opens the same kind of stream to the cluster leader, returning a function
+	// that receives the next events from it.
+	proxy(ctx context.Context, client api.SwitchbackClient, in *api.Subscription) (func() ([]*api.Event, error), error)
+}
+
+// eventStream sends each event in its own message.
+type eventStream struct {
+	api.Switchback_SubscribeServer
+}
+
+func (s eventStream) send(events []*api.Event) error {
+	for _, event := range events {
+		if err := s.Send(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s eventStream) batcher(int) *batcher {
+	return newBatcher(1, 0, 0)
+}
+
+func (s eventStream) proxy(ctx context.Context, client api.SwitchbackClient, in *api.Subscription) (func() ([]*api.Event, error), error) {
+	return proxyEvents(ctx, client, in)
+}
+
+// proxyEvents opens a Subscribe stream to the leader for subscribers that are sent
+// individual events.
+func proxyEvents(ctx context.Context, client api.SwitchbackClient, in *api.Subscription) (_ func() ([]*api.Event, error), err error) {
+	var upstream api.Switchback_SubscribeClient
+	if upstream, err = client.Subscribe(ctx, in); err != nil {
+		return nil, err
+	}
+
+	return func() ([]*api.Event, error) {
+		event, err := upstream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return []*api.Event{event}, nil
+	}, nil
+}
+
+// batchStream sends events in batches.
+type batchStream struct {
+	api.Switchback_SubscribeBatchServer
+	size   int
+	linger time.Duration
+}
+
+func (s batchStream) send(events []*api.Event) error {
+	return s.Send(&api.EventBatch{Events: events})
+}
+
+func (s batchStream) batcher(bytes int) *batcher {
+	return newBatcher(s.size, s.linger, bytes)
+}
+
+func (s batchStream) proxy(ctx context.Context, client api.SwitchbackClient, in *api.Subscription) (_ func() ([]*api.Event, error), err error) {
+	var upstream api.Switchback_SubscribeBatchClient
+	if upstream, err = client.SubscribeBatch(ctx, in); err != nil {
+		return nil, err
+	}
+
+	return func() ([]*api.Event, error) {
+		batch, err := upstream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return batch.Events, nil
+	}, nil
+}
+
+// batcher collects the events queued for a subscriber into batches. A batch is ready
+// when it has the maximum number of events or, without a linger, when no more events
+// are queued; with a linger, a partial batch is sent when the linger elapses after the
+// first event was added to it. Adding an event that would exceed the byte limit should
+// be preceded by sending the current batch.
+type batcher struct {
+	size   int
+	linger time.Duration
+	limit  int
+	events []*api.Event
+	bytes  int
+	timer  *time.Timer
+	expire <-chan time.Time
+}
+
+func newBatcher(size int, linger time.Duration, limit int) *batcher {
+	return &batcher{size: size, linger: linger, limit: limit, events: make([]*api.Event, 0, size)}
+}
+
+// add the event to the batch and return true if the batch is ready to send; queued is
+// the number of events waiting to be added.
+func (b *batcher) add(event *api.Event, queued int) bool {
+	b.events = append(b.events, event)
+	if b.limit > 0 {
+		b.bytes += proto.Size(event)
+	}
+
+	if len(b.events) >= b.size {
+		return true
+	}
+
+	if b.linger <= 0 {
+		return queued == 0
+	}
+
+	if len(b.events) == 1 {
+		if b.timer == nil {
+			b.timer = time.NewTimer(b.linger)
+		} else {
+			b.timer.Reset(b.linger)
+		}
+		b.expire = b.timer.C
+	}
+	return false
+}
+
+// overflows returns true if adding the event would exceed the byte limit of the batch.
+func (b *batcher) overflows(event *api.Event) bool {
+	return b.limit > 0 && len(b.events) > 0 && b.bytes+proto.Size(event) > b.limit
+}
+
+// lingering returns a channel that receives when a partial batch should be sent, or nil
+// if there is no partial batch waiting.
+func (b *batcher) lingering() <-chan time.Time {
+	return b.expire
+}
+
+// take returns the batch and starts a new one.
+func (b *batcher) take() []*api.Event {
+	b.stop()
+	events := b.events
+	b.events = make([]*api.Event, 0, b.size)
+	b.bytes = 0
+	return events
+}
+
+func (b *batcher) stop() {
+	if b.timer != nil && !b.timer.Stop() {
+		select {
+		case <-b.timer.C:
+		default:
+		}
+	}
+	b.expire = nil
+}
+
+func ignoreEOF(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package switchback_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPublishBatchSize(t *testing.T) {
+	srv := serveEmbedded(t, func(conf *config.Config) {
+		conf.Events.MaxBatchBytes = 4 * 1024 * 1024
+	})
+	client := embeddedClient(t, srv)
+
+	// A full batch of modest events is larger than the message size of a single event
+	batch := &api.EventBatch{Events: make([]*api.Event, 0, 1000)}
+	for i := 0; i < 1000; i++ {
+		batch.Events = append(batch.Events, &api.Event{Topic: "batched", Data: bytes.Repeat([]byte{'x'}, 2048)})
+	}
+
+	stream, err := client.PublishBatch(context.Background())
+	if err != nil {
+		t.Fatalf("could not open publish stream: %s", err)
+	}
+
+	if err = stream.Send(batch); err != nil {
+		t.Fatalf("could not send batch: %s", err)
+	}
+
+	rep, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("could not publish batch: %s", err)
+	}
+
+	if rep.Events != 1000 {
+		t.Errorf("expected 1000 events to be published, got %d", rep.Events)
+	}
+
+	// Batches larger than the max batch bytes are rejected
+	for _, event := range batch.Events {
+		event.Data = bytes.Repeat([]byte{'x'}, 8192)
+	}
+
+	if stream, err = client.PublishBatch(context.Background(), grpc.MaxCallSendMsgSize(64*1024*1024)); err != nil {
+		t.Fatalf("could not open publish stream: %s", err)
+	}
+
+	stream.Send(batch)
+	if _, err = stream.CloseAndRecv(); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected resource exhausted for a batch over the max batch bytes, got %v", err)
+	}
+}
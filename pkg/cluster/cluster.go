@@ -0,0 +1,450 @@
+/*
+Package cluster replicates the events published to a switchback server and the offsets
+acknowledged by its consumer groups to the other nodes of a cluster using Raft. The
+leader accepts publishers and subscribers; if it fails, a follower that has the same
+topic logs and group offsets is elected and groups resume from their last acknowledged
+offset when their subscribers reconnect to it.
+*/
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/bbengfort/switchback/pkg/dedup"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	applyTimeout     = 5 * time.Second
+	transportTimeout = 10 * time.Second
+)
+
+// ErrNotLeader is returned when a command is applied to a node that is not the leader.
+var ErrNotLeader = errors.New("node is not the cluster leader")
+
+// Node is a member of a switchback cluster.
+type Node struct {
+	conf       config.ClusterConfig
+	raft       *raft.Raft
+	fsm        *fsm
+	transport  *raft.NetworkTransport
+	store      *raftboltdb.BoltStore
+	notify     chan bool
+	leadership chan bool
+	done       chan struct{}
+	shutdown   sync.Once
+	stopped    error
+}
+
+// Member describes a node in the cluster configuration.
+type Member struct {
+	ID       string
+	Addr     string
+	Endpoint string
+	Leader   bool
+	Voter    bool
+}
+
+// New starts the raft node and bootstraps the cluster from the configured peers if the
+// node has no existing state. Idempotent producers are deduplicated within the window.
+func New(conf config.ClusterConfig, window time.Duration) (n *Node, err error) {
+	n = &Node{
+		conf:       conf,
+		fsm:        newFSM(conf.Compression, conf.Retention, dedup.New(window)),
+		notify:     make(chan bool, 8),
+		leadership: make(chan bool, 1),
+		done:       make(chan struct{}),
+	}
+
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:   "raft",
+		Level:  hclogLevel(zerolog.GlobalLevel()),
+		Output: log.With().Str("component", "cluster").Logger(),
+	})
+
+	rconf := raft.DefaultConfig()
+	rconf.LocalID = raft.ServerID(conf.NodeID)
+	rconf.Logger = logger
+	rconf.NotifyCh = n.notify
+
+	servers := make([]raft.Server, 0, len(conf.Peers))
+	var advertise net.Addr
+	for _, peer := range conf.Peers {
+		parts := strings.SplitN(peer, "@", 2)
+		servers = append(servers, raft.Server{ID: raft.ServerID(parts[0]), Address: raft.ServerAddress(parts[1])})
+		if parts[0] == conf.NodeID {
+			if advertise, err = net.ResolveTCPAddr("tcp", parts[1]); err != nil {
+				return nil, fmt.Errorf("could not resolve cluster address: %w", err)
+			}
+		}
+	}
+
+	if n.transport, err = raft.NewTCPTransportWithLogger(conf.BindAddr, advertise, 3, transportTimeout, logger); err != nil {
+		return nil, fmt.Errorf("could not listen for cluster peers: %w", err)
+	}
+
+	var (
+		logs   raft.LogStore
+		stable raft.StableStore
+		snaps  raft.SnapshotStore
+	)
+	if conf.DataDir == "" {
+		mem := raft.NewInmemStore()
+		logs, stable, snaps = mem, mem, raft.NewInmemSnapshotStore()
+	} else {
+		if err = os.MkdirAll(conf.DataDir, 0755); err != nil {
+			n.transport.Close()
+			return nil, err
+		}
+
+		if n.store, err = raftboltdb.NewBoltStore(filepath.Join(conf.DataDir, "raft.db")); err != nil {
+			n.transport.Close()
+			return nil, fmt.Errorf("could not open raft log: %w", err)
+		}
+		logs, stable = n.store, n.store
+
+		if snaps, err = raft.NewFileSnapshotStoreWithLogger(conf.DataDir, 2, logger); err != nil {
+			n.close()
+			return nil, fmt.Errorf("could not open raft snapshots: %w", err)
+		}
+	}
+
+	var existing bool
+	if existing, err = raft.HasExistingState(logs, stable, snaps); err != nil {
+		n.close()
+		return nil, err
+	}
+
+	if n.raft, err = raft.NewRaft(rconf, n.fsm, logs, stable, snaps, n.transport); err != nil {
+		n.close()
+		return nil, fmt.Errorf("could not start raft: %w", err)
+	}
+
+	if !existing {
+		if err = n.raft.BootstrapCluster(raft.Configuration{Servers: servers}).Error(); err != nil && !errors.Is(err, raft.ErrCantBootstrap) {
+			n.Shutdown()
+			return nil, fmt.Errorf("could not bootstrap cluster: %w", err)
+		}
+	}
+
+	go n.observe()
+	log.Info().Str("node", conf.NodeID).Str("bind", conf.BindAddr).Int("peers", len(servers)).Msg("cluster node started")
+	return n, nil
+}
+
+// observe leadership changes: when the node becomes the leader it advertises its gRPC
+// endpoint to the cluster so that followers can refer clients to it.
+func (n *Node) observe() {
+	defer close(n.leadership)
+	for {
+		select {
+		case <-n.done:
+			return
+		case leader := <-n.notify:
+			if leader {
+				log.Info().Str("node", n.conf.NodeID).Uint64("term", n.Term()).Msg("elected cluster leader")
+				if err := n.apply(&command{Type: advertiseCommand, Node: n.conf.NodeID, Endpoint: n.conf.Advertise}); err != nil {
+					log.Warn().Err(err).Msg("could not advertise endpoint to cluster")
+				}
+			} else {
+				log.Warn().Str("node", n.conf.NodeID).Msg("lost cluster leadership")
+			}
+
+			select {
+			case n.leadership <- leader:
+			case <-n.done:
+				return
+			}
+		}
+	}
+}
+
+// Leadership returns a channel that receives true when the node becomes the leader and
+// false when it loses leadership. The channel must be read to observe later changes and
+// is closed when the node is shutdown.
+func (n *Node) Leadership() <-chan bool {
+	return n.leadership
+}
+
+// Publish appends the event to the replicated log and returns it once it has been
+// committed with its offset and epoch assigned. ErrNotLeader is returned if the node is
+// not the leader. If the event is a duplicate from an idempotent producer, it is
+// returned with its original offset and epoch along with dedup.ErrDuplicate.
+func (n *Node) Publish(event *api.Event) (_ *api.Event, err error) {
+	cmd := &command{Type: publishCommand, Codec: n.conf.Compression}
+	if cmd.Event, err = encodeEvent(cmd.Codec, event); err != nil {
+		return nil, err
+	}
+
+	var rep interface{}
+	if rep, err = n.applyResponse(cmd); err != nil {
+		return nil, err
+	}
+
+	if dup, ok := rep.(*duplicate); ok {
+		return dup.event, dedup.ErrDuplicate
+	}
+	return rep.(*api.Event), nil
+}
+
+// PublishAll appends the events to the replicated log as a single entry so that either
+// all or none of them are committed. The events that were committed are returned with
+// their offsets and epochs assigned; duplicates from idempotent producers are omitted.
+func (n *Node) PublishAll(events []*api.Event) (_ []*api.Event, err error) {
+	cmd := &command{Type: transactionCommand, Codec: n.conf.Compression, Events: make([][]byte, 0, len(events))}
+	for _, event := range events {
+		var data []byte
+		if data, err = encodeEvent(cmd.Codec, event); err != nil {
+			return nil, err
+		}
+		cmd.Events = append(cmd.Events, data)
+	}
+
+	var rep interface{}
+	if rep, err = n.applyResponse(cmd); err != nil {
+		return nil, err
+	}
+	return rep.([]*api.Event), nil
+}
+
+// Commit replicates the offsets acknowledged by consumer groups.
+func (n *Node) Commit(offsets []Offset) error {
+	return n.apply(&command{Type: commitCommand, Offsets: offsets})
+}
+
+func (n *Node) apply(cmd *command) error {
+	_, err := n.applyResponse(cmd)
+	return err
+}
+
+func (n *Node) applyResponse(cmd *command) (_ interface{}, err error) {
+	if !n.IsLeader() {
+		return nil, ErrNotLeader
+	}
+
+	var data []byte
+	if data, err = json.Marshal(cmd); err != nil {
+		return nil, err
+	}
+
+	future := n.raft.Apply(data, applyTimeout)
+	if err = future.Error(); err != nil {
+		if errors.Is(err, raft.ErrNotLeader) || errors.Is(err, raft.ErrLeadershipLost) {
+			return nil, ErrNotLeader
+		}
+		return nil, err
+	}
+
+	rep := future.Response()
+	if err, ok := rep.(error); ok {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// IsLeader returns true if the node is currently the cluster leader.
+func (n *Node) IsLeader() bool {
+	return n.raft.State() == raft.Leader
+}
+
+// Leader returns the id and advertised gRPC endpoint of the current leader; the id is
+// empty if there is no leader.
+func (n *Node) Leader() (id, endpoint string) {
+	_, sid := n.raft.LeaderWithID()
+	id = string(sid)
+
+	n.fsm.RLock()
+	defer n.fsm.RUnlock()
+	return id, n.fsm.endpoints[id]
+}
+
+// Term returns the current raft term, which is the epoch of events published in it.
+func (n *Node) Term() uint64 {
+	term, _ := strconv.ParseUint(n.raft.Stats()["term"], 10, 64)
+	return term
+}
+
+// Members returns the nodes in the current cluster configuration.
+func (n *Node) Members() (_ []Member, err error) {
+	future := n.raft.GetConfiguration()
+	if err = future.Error(); err != nil {
+		return nil, err
+	}
+
+	leader, _ := n.raft.LeaderWithID()
+	n.fsm.RLock()
+	defer n.fsm.RUnlock()
+
+	servers := future.Configuration().Servers
+	members := make([]Member, 0, len(servers))
+	for _, server := range servers {
+		members = append(members, Member{
+			ID:       string(server.ID),
+			Addr:     string(server.Address),
+			Endpoint: n.fsm.endpoints[string(server.ID)],
+			Leader:   server.Address == leader,
+			Voter:    server.Suffrage == raft.Voter,
+		})
+	}
+	return members, nil
+}
+
+// Offset returns the offset of the last event committed to the topic.
+func (n *Node) Offset(topic string) uint64 {
+	n.fsm.RLock()
+	defer n.fsm.RUnlock()
+	if t, ok := n.fsm.topics[topic]; ok {
+		return t.offset
+	}
+	return 0
+}
+
+// Topics returns the offset of the last event committed to each topic.
+func (n *Node) Topics() map[string]uint64 {
+	n.fsm.RLock()
+	defer n.fsm.RUnlock()
+
+	topics := make(map[string]uint64, len(n.fsm.topics))
+	for name, topic := range n.fsm.topics {
+		topics[name] = topic.offset
+	}
+	return topics
+}
+
+// Committed returns the last offset acknowledged by the group on the topic.
+func (n *Node) Committed(topic, group string) (offset uint64, ok bool) {
+	n.fsm.RLock()
+	defer n.fsm.RUnlock()
+	offset, ok = n.fsm.groups[topic][group]
+	return offset, ok
+}
+
+// Since returns the retained events in the topic after the offset.
+func (n *Node) Since(topic string, offset uint64) []*api.Event {
+	n.fsm.RLock()
+	defer n.fsm.RUnlock()
+
+	t, ok := n.fsm.topics[topic]
+	if !ok {
+		return nil
+	}
+
+	for i, event := range t.events {
+		if event.Meta.GetOffset() > offset {
+			return append([]*api.Event(nil), t.events[i:]...)
+		}
+	}
+	return nil
+}
+
+// Storage describes the space used by the node: the size of the raft log and snapshots
+// in the data directory, if the node has one, and the events retained in memory for
+// groups to resume from.
+type Storage struct {
+	Durable       bool
+	DiskBytes     uint64
+	Retained      int
+	RetainedBytes uint64
+}
+
+// Storage returns the space used by the node, or an error if its raft log cannot be read.
+func (n *Node) Storage() (storage Storage, err error) {
+	n.fsm.RLock()
+	for _, topic := range n.fsm.topics {
+		storage.Retained += len(topic.events)
+		for _, event := range topic.events {
+			storage.RetainedBytes += uint64(proto.Size(event))
+		}
+	}
+	n.fsm.RUnlock()
+
+	if n.store == nil {
+		return storage, nil
+	}
+
+	storage.Durable = true
+	if _, err = n.store.LastIndex(); err != nil {
+		return storage, fmt.Errorf("could not read raft log: %w", err)
+	}
+
+	err = filepath.WalkDir(n.conf.DataDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		var info fs.FileInfo
+		if info, err = entry.Info(); err != nil {
+			return err
+		}
+		storage.DiskBytes += uint64(info.Size())
+		return nil
+	})
+	return storage, err
+}
+
+// Shutdown stops the node, first transferring leadership to another node if it is the
+// leader so that the cluster does not have to wait for an election timeout. The node is
+// only stopped once; later calls return the result of the first.
+func (n *Node) Shutdown() error {
+	n.shutdown.Do(func() { n.stopped = n.stop() })
+	return n.stopped
+}
+
+func (n *Node) stop() (err error) {
+	close(n.done)
+	if n.IsLeader() && len(n.conf.Peers) > 1 {
+		if err = n.raft.LeadershipTransfer().Error(); err != nil {
+			log.Warn().Err(err).Msg("could not transfer cluster leadership")
+		}
+	}
+
+	if err = n.raft.Shutdown().Error(); err != nil {
+		return err
+	}
+	return n.close()
+}
+
+func (n *Node) close() (err error) {
+	if err = n.transport.Close(); err != nil {
+		return err
+	}
+
+	if n.store != nil {
+		if err = n.store.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hclogLevel(level zerolog.Level) hclog.Level {
+	switch level {
+	case zerolog.TraceLevel:
+		return hclog.Trace
+	case zerolog.DebugLevel:
+		return hclog.Debug
+	case zerolog.InfoLevel:
+		return hclog.Info
+	case zerolog.WarnLevel:
+		return hclog.Warn
+	default:
+		return hclog.Error
+	}
+}
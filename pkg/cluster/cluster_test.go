@@ -0,0 +1,273 @@
+package cluster_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/cluster"
+	"github.com/bbengfort/switchback/pkg/compress"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+// startCluster runs an in-memory cluster of n nodes on local ports and returns them once
+// a leader has been elected. Nodes that are still running are shutdown when the test is
+// complete.
+func startCluster(t *testing.T, n int) []*cluster.Node {
+	t.Helper()
+	return startCompressed(t, n, compress.None)
+}
+
+// startCompressed runs a cluster whose log entries are compressed with the codec.
+func startCompressed(t *testing.T, n int, codec string) []*cluster.Node {
+	t.Helper()
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	peers := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		peers = append(peers, fmt.Sprintf("n%d@%s", i, freeAddr(t)))
+	}
+
+	nodes := make([]*cluster.Node, 0, n)
+	for i, peer := range peers {
+		conf := config.ClusterConfig{
+			Enabled:     true,
+			NodeID:      fmt.Sprintf("n%d", i+1),
+			BindAddr:    peer[len("n1@"):],
+			Advertise:   fmt.Sprintf("node%d:7773", i+1),
+			Peers:       peers,
+			Retention:   16,
+			Compression: codec,
+		}
+
+		node, err := cluster.New(conf, time.Minute)
+		if err != nil {
+			t.Fatalf("could not start node %s: %s", conf.NodeID, err)
+		}
+		t.Cleanup(func() { node.Shutdown() })
+
+		// Leadership changes must be read for the node to observe later changes
+		go func() {
+			for range node.Leadership() {
+			}
+		}()
+		nodes = append(nodes, node)
+	}
+
+	leaderOf(t, nodes)
+	return nodes
+}
+
+// leaderOf waits for one of the nodes to be elected leader and returns it.
+func leaderOf(t *testing.T, nodes []*cluster.Node) (leader *cluster.Node) {
+	t.Helper()
+	waitFor(t, func() bool {
+		for _, node := range nodes {
+			if node.IsLeader() {
+				leader = node
+				return true
+			}
+		}
+		return false
+	})
+	return leader
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	sock, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not find a free port: %s", err)
+	}
+	defer sock.Close()
+	return sock.Addr().String()
+}
+
+// waitFor polls the condition until it is true or fails the test after a timeout.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLeaderElection(t *testing.T) {
+	nodes := startCluster(t, 3)
+	leader := leaderOf(t, nodes)
+
+	var leaders int
+	for _, node := range nodes {
+		if node.IsLeader() {
+			leaders++
+		}
+	}
+	if leaders != 1 {
+		t.Fatalf("expected exactly one leader, got %d", leaders)
+	}
+
+	// Every node learns the leader and the endpoint it advertised once elected
+	for _, node := range nodes {
+		node := node
+		waitFor(t, func() bool {
+			_, endpoint := node.Leader()
+			return endpoint != ""
+		})
+
+		id, endpoint := node.Leader()
+		members, err := leader.Members()
+		if err != nil {
+			t.Fatalf("could not get members: %s", err)
+		}
+
+		for _, member := range members {
+			if member.Leader && (member.ID != id || member.Endpoint != endpoint) {
+				t.Errorf("expected leader %s at %s, got %+v", id, endpoint, member)
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		if node == leader {
+			continue
+		}
+
+		if _, err := node.Publish(&api.Event{Topic: "orders", Data: []byte("order")}); !errors.Is(err, cluster.ErrNotLeader) {
+			t.Errorf("expected followers to refuse to publish, got %v", err)
+		}
+	}
+}
+
+func TestReplication(t *testing.T) {
+	nodes := startCluster(t, 3)
+	leader := leaderOf(t, nodes)
+
+	for i := 1; i <= 5; i++ {
+		event, err := leader.Publish(&api.Event{Topic: "orders", Data: []byte("order")})
+		if err != nil {
+			t.Fatalf("could not publish event: %s", err)
+		}
+
+		// The epoch of an event is the term of the leader that appended it
+		if event.Meta.Offset != uint64(i) || event.Meta.Epoch != leader.Term() {
+			t.Errorf("expected offset %d in epoch %d, got %+v", i, leader.Term(), event.Meta)
+		}
+	}
+
+	events, err := leader.PublishAll([]*api.Event{{Topic: "payments", Data: []byte("a")}, {Topic: "payments", Data: []byte("b")}})
+	if err != nil || len(events) != 2 {
+		t.Fatalf("could not publish transaction: %v (%d events)", err, len(events))
+	}
+
+	if err = leader.Commit([]cluster.Offset{{Topic: "orders", Group: "workers", Offset: 3}}); err != nil {
+		t.Fatalf("could not commit offsets: %s", err)
+	}
+
+	for _, node := range nodes {
+		node := node
+		waitFor(t, func() bool {
+			offset, ok := node.Committed("orders", "workers")
+			return ok && offset == 3
+		})
+
+		if topics := node.Topics(); topics["orders"] != 5 || topics["payments"] != 2 {
+			t.Errorf("expected topic offsets to be replicated, got %v", topics)
+		}
+
+		since := node.Since("orders", 3)
+		if len(since) != 2 || since[0].Meta.Offset != 4 || since[1].Meta.Epoch != leader.Term() {
+			t.Errorf("expected events 4 and 5 to be retained, got %v", since)
+		}
+	}
+}
+
+func TestFollowerTakeover(t *testing.T) {
+	nodes := startCluster(t, 3)
+	leader := leaderOf(t, nodes)
+
+	published, err := leader.Publish(&api.Event{Topic: "orders", Data: []byte("order")})
+	if err != nil {
+		t.Fatalf("could not publish event: %s", err)
+	}
+
+	if err = leader.Commit([]cluster.Offset{{Topic: "orders", Group: "workers", Offset: 1}}); err != nil {
+		t.Fatalf("could not commit offsets: %s", err)
+	}
+
+	followers := make([]*cluster.Node, 0, len(nodes)-1)
+	for _, node := range nodes {
+		if node != leader {
+			followers = append(followers, node)
+		}
+	}
+
+	// Wait for the followers to apply the entries before the leader is stopped
+	for _, node := range followers {
+		node := node
+		waitFor(t, func() bool {
+			_, ok := node.Committed("orders", "workers")
+			return ok
+		})
+	}
+
+	if err = leader.Shutdown(); err != nil {
+		t.Fatalf("could not shutdown leader: %s", err)
+	}
+
+	// Shutting down a node again does not panic
+	if err = leader.Shutdown(); err != nil {
+		t.Fatalf("could not shutdown leader twice: %s", err)
+	}
+
+	takeover := leaderOf(t, followers)
+	if offset, ok := takeover.Committed("orders", "workers"); !ok || offset != 1 {
+		t.Errorf("expected the new leader to have the group offset, got %d", offset)
+	}
+
+	event, err := takeover.Publish(&api.Event{Topic: "orders", Data: []byte("order")})
+	if err != nil {
+		t.Fatalf("could not publish event to new leader: %s", err)
+	}
+
+	if event.Meta.Offset != 2 {
+		t.Errorf("expected the new leader to continue from offset 2, got %d", event.Meta.Offset)
+	}
+
+	if event.Meta.Epoch != takeover.Term() || event.Meta.Epoch <= published.Meta.Epoch {
+		t.Errorf("expected epoch %d to be the new term and after epoch %d", event.Meta.Epoch, published.Meta.Epoch)
+	}
+}
+
+// Events in the log are compressed with the codec and decoded by every node.
+func TestCompressedLog(t *testing.T) {
+	for _, codec := range []string{compress.Gzip, compress.Zstd, compress.Snappy} {
+		nodes := startCompressed(t, 2, codec)
+		leader := leaderOf(t, nodes)
+
+		event := &api.Event{Topic: "orders", Data: []byte("order"), Attributes: map[string]string{"codec": codec}}
+		if _, err := leader.Publish(event); err != nil {
+			t.Fatalf("%s: could not publish event: %s", codec, err)
+		}
+
+		if _, err := leader.PublishAll([]*api.Event{{Topic: "orders", Data: []byte("batch")}}); err != nil {
+			t.Fatalf("%s: could not publish transaction: %s", codec, err)
+		}
+
+		for _, node := range nodes {
+			node := node
+			waitFor(t, func() bool { return node.Offset("orders") == 2 })
+
+			events := node.Since("orders", 0)
+			if len(events) != 2 || string(events[0].Data) != "order" || events[0].Attributes["codec"] != codec || string(events[1].Data) != "batch" {
+				t.Errorf("%s: expected the events to be decoded, got %v", codec, events)
+			}
+		}
+	}
+}
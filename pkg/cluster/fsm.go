@@ -0,0 +1,309 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/compress"
+	"github.com/bbengfort/switchback/pkg/dedup"
+	"github.com/hashicorp/raft"
+	"google.golang.org/protobuf/proto"
+)
+
+type commandType uint8
+
+const (
+	publishCommand commandType = iota + 1
+	commitCommand
+	advertiseCommand
+	transactionCommand
+)
+
+// command is an entry in the raft log; events are protobuf encoded and compressed with
+// the codec, if any.
+type command struct {
+	Type     commandType `json:"type"`
+	Codec    string      `json:"codec,omitempty"`
+	Event    []byte      `json:"event,omitempty"`
+	Events   [][]byte    `json:"events,omitempty"`
+	Offsets  []Offset    `json:"offsets,omitempty"`
+	Node     string      `json:"node,omitempty"`
+	Endpoint string      `json:"endpoint,omitempty"`
+}
+
+// Offset is the last offset acknowledged by a consumer group on a topic.
+type Offset struct {
+	Topic  string `json:"topic"`
+	Group  string `json:"group"`
+	Offset uint64 `json:"offset"`
+}
+
+// fsm is the replicated state machine: the offset and most recent events of every
+// topic, the offsets acknowledged by every group, the gRPC endpoints of the nodes, and
+// the recent sequences of idempotent producers so that deduplication survives failover.
+type fsm struct {
+	sync.RWMutex
+	codec     string
+	retention int
+	topics    map[string]*topicLog
+	groups    map[string]map[string]uint64
+	endpoints map[string]string
+	producers *dedup.Window
+}
+
+// duplicate is the response to a published event that was deduplicated; it contains the
+// offset and epoch originally assigned to the event.
+type duplicate struct {
+	event *api.Event
+}
+
+type topicLog struct {
+	offset uint64
+	events []*api.Event
+}
+
+// state is the serialized form of the fsm in snapshots; retained events are compressed
+// with the codec, if any.
+type state struct {
+	Codec     string                       `json:"codec,omitempty"`
+	Topics    map[string]topicState        `json:"topics"`
+	Groups    map[string]map[string]uint64 `json:"groups"`
+	Endpoints map[string]string            `json:"endpoints"`
+	Producers *dedup.Window                `json:"producers,omitempty"`
+}
+
+type topicState struct {
+	Offset uint64   `json:"offset"`
+	Events [][]byte `json:"events"`
+}
+
+func newFSM(codec string, retention int, producers *dedup.Window) *fsm {
+	return &fsm{
+		codec:     codec,
+		retention: retention,
+		topics:    make(map[string]*topicLog),
+		groups:    make(map[string]map[string]uint64),
+		endpoints: make(map[string]string),
+		producers: producers,
+	}
+}
+
+// Apply a committed raft log entry. Published events are assigned the next offset in
+// their topic and the term of the leader that appended them as their epoch; the event
+// is returned as the response so the leader can route it to its consumers. Duplicates
+// from idempotent producers are checked against the time the leader appended the entry
+// so that every node makes the same decision. The events of a transaction are applied
+// together and the ones that are not duplicates are returned.
+func (f *fsm) Apply(entry *raft.Log) interface{} {
+	var cmd command
+	if err := json.Unmarshal(entry.Data, &cmd); err != nil {
+		return fmt.Errorf("could not decode command: %w", err)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	switch cmd.Type {
+	case publishCommand:
+		event, err := decodeEvent(cmd.Codec, cmd.Event)
+		if err != nil {
+			return err
+		}
+
+		if !f.append(event, entry) {
+			return &duplicate{event: event}
+		}
+		return event
+	case transactionCommand:
+		// Decode all of the events before any are appended so the transaction is atomic
+		events := make([]*api.Event, 0, len(cmd.Events))
+		for _, data := range cmd.Events {
+			event, err := decodeEvent(cmd.Codec, data)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+
+		appended := make([]*api.Event, 0, len(events))
+		for _, event := range events {
+			if f.append(event, entry) {
+				appended = append(appended, event)
+			}
+		}
+		return appended
+	case commitCommand:
+		for _, offset := range cmd.Offsets {
+			if _, ok := f.groups[offset.Topic]; !ok {
+				f.groups[offset.Topic] = make(map[string]uint64)
+			}
+
+			if offset.Offset > f.groups[offset.Topic][offset.Group] {
+				f.groups[offset.Topic][offset.Group] = offset.Offset
+			}
+		}
+		return nil
+	case advertiseCommand:
+		f.endpoints[cmd.Node] = cmd.Endpoint
+		return nil
+	default:
+		return fmt.Errorf("unknown command type %d", cmd.Type)
+	}
+}
+
+// append assigns the event the next offset in its topic and retains it, returning false
+// if it is a duplicate, in which case it is given the offset and epoch originally
+// assigned to it instead. Must hold the lock to call this method.
+func (f *fsm) append(event *api.Event, entry *raft.Log) bool {
+	if event.Meta == nil {
+		event.Meta = &api.Metadata{}
+	}
+
+	if offset, ok := f.producers.Check(event.Topic, event.Producer, event.Sequence, entry.AppendedAt); ok {
+		event.Meta.Offset = offset
+		event.Meta.Epoch = f.epoch(event.Topic, offset)
+		return false
+	}
+
+	topic := f.topic(event.Topic)
+	topic.offset++
+	event.Meta.Offset = topic.offset
+	event.Meta.Epoch = entry.Term
+	f.producers.Record(event.Topic, event.Producer, event.Sequence, topic.offset, entry.AppendedAt)
+
+	topic.events = append(topic.events, event)
+	if len(topic.events) > f.retention {
+		topic.events = topic.events[len(topic.events)-f.retention:]
+	}
+	return true
+}
+
+// Must hold the lock to call this method.
+func (f *fsm) topic(name string) *topicLog {
+	if t, ok := f.topics[name]; ok {
+		return t
+	}
+
+	t := &topicLog{events: make([]*api.Event, 0, 16)}
+	f.topics[name] = t
+	return t
+}
+
+// epoch returns the epoch of the retained event at the offset or zero if it has been
+// discarded. Must hold the lock to call this method.
+func (f *fsm) epoch(topic string, offset uint64) uint64 {
+	if t, ok := f.topics[topic]; ok {
+		for _, event := range t.events {
+			if event.Meta.GetOffset() == offset {
+				return event.Meta.Epoch
+			}
+		}
+	}
+	return 0
+}
+
+// Snapshot serializes the state while holding the read lock; it is persisted later.
+func (f *fsm) Snapshot() (_ raft.FSMSnapshot, err error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	s := state{
+		Codec:     f.codec,
+		Topics:    make(map[string]topicState, len(f.topics)),
+		Groups:    f.groups,
+		Endpoints: f.endpoints,
+		Producers: f.producers,
+	}
+
+	for name, topic := range f.topics {
+		ts := topicState{Offset: topic.offset, Events: make([][]byte, 0, len(topic.events))}
+		for _, event := range topic.events {
+			var data []byte
+			if data, err = encodeEvent(f.codec, event); err != nil {
+				return nil, err
+			}
+			ts.Events = append(ts.Events, data)
+		}
+		s.Topics[name] = ts
+	}
+
+	snap := &snapshot{}
+	if snap.data, err = json.Marshal(s); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Restore replaces the state with the snapshot.
+func (f *fsm) Restore(rc io.ReadCloser) (err error) {
+	defer rc.Close()
+
+	s := state{Producers: dedup.New(f.producers.Duration())}
+	if err = json.NewDecoder(rc).Decode(&s); err != nil {
+		return fmt.Errorf("could not decode snapshot: %w", err)
+	}
+
+	topics := make(map[string]*topicLog, len(s.Topics))
+	for name, ts := range s.Topics {
+		topic := &topicLog{offset: ts.Offset, events: make([]*api.Event, 0, len(ts.Events))}
+		for _, data := range ts.Events {
+			var event *api.Event
+			if event, err = decodeEvent(s.Codec, data); err != nil {
+				return err
+			}
+			topic.events = append(topic.events, event)
+		}
+		topics[name] = topic
+	}
+
+	if s.Groups == nil {
+		s.Groups = make(map[string]map[string]uint64)
+	}
+
+	if s.Endpoints == nil {
+		s.Endpoints = make(map[string]string)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.topics, f.groups, f.endpoints, f.producers = topics, s.Groups, s.Endpoints, s.Producers
+	return nil
+}
+
+// encodeEvent marshals the event and compresses it with the codec.
+func encodeEvent(codec string, event *api.Event) (data []byte, err error) {
+	if data, err = proto.Marshal(event); err != nil {
+		return nil, err
+	}
+	return compress.Encode(codec, data)
+}
+
+// decodeEvent decompresses the data with the codec and unmarshals the event.
+func decodeEvent(codec string, data []byte) (_ *api.Event, err error) {
+	if data, err = compress.Decode(codec, data); err != nil {
+		return nil, fmt.Errorf("could not decompress event: %w", err)
+	}
+
+	event := &api.Event{}
+	if err = proto.Unmarshal(data, event); err != nil {
+		return nil, fmt.Errorf("could not decode event: %w", err)
+	}
+	return event, nil
+}
+
+type snapshot struct {
+	data []byte
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if _, err := sink.Write(s.data); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {}
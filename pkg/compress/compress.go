@@ -0,0 +1,169 @@
+/*
+Package compress registers the zstd and snappy compressors with gRPC alongside gzip so
+that clients and servers can negotiate the compression of their streams, and compresses
+the events that a server stores or sends to other servers. The codec is recorded with
+the compressed data so that consumers always receive the original bytes.
+*/
+package compress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// Codec names, which are also the names of the gRPC compressors.
+const (
+	None   = ""
+	Gzip   = gzip.Name
+	Zstd   = "zstd"
+	Snappy = "snappy"
+)
+
+func init() {
+	encoding.RegisterCompressor(&zstdCompressor{})
+	encoding.RegisterCompressor(snappyCompressor{})
+}
+
+// Validate returns an error if the codec is not registered.
+func Validate(codec string) error {
+	if codec != None && encoding.GetCompressor(codec) == nil {
+		return fmt.Errorf("unknown compression codec %q (use gzip, zstd, or snappy)", codec)
+	}
+	return nil
+}
+
+// Encode compresses the data with the codec; the data is returned unchanged if the
+// codec is None.
+func Encode(codec string, data []byte) (_ []byte, err error) {
+	if codec == None {
+		return data, nil
+	}
+
+	compressor := encoding.GetCompressor(codec)
+	if compressor == nil {
+		return nil, Validate(codec)
+	}
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if w, err = compressor.Compress(&buf); err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(data); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decompresses data that was compressed with the codec.
+func Decode(codec string, data []byte) (_ []byte, err error) {
+	if codec == None {
+		return data, nil
+	}
+
+	compressor := encoding.GetCompressor(codec)
+	if compressor == nil {
+		return nil, Validate(codec)
+	}
+
+	var r io.Reader
+	if r, err = compressor.Decompress(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// zstdCompressor pools encoders and decoders since they are expensive to create.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func (c *zstdCompressor) Name() string {
+	return Zstd
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (_ io.WriteCloser, err error) {
+	enc, ok := c.encoders.Get().(*zstd.Encoder)
+	if !ok {
+		if enc, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	}
+
+	enc.Reset(w)
+	return &zstdWriter{Encoder: enc, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (_ io.Reader, err error) {
+	dec, ok := c.decoders.Get().(*zstd.Decoder)
+	if !ok {
+		if dec, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = dec.Reset(r); err != nil {
+		c.decoders.Put(dec)
+		return nil, err
+	}
+	return &zstdReader{Decoder: dec, pool: &c.decoders}, nil
+}
+
+// zstdWriter returns its encoder to the pool when it is closed.
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+	return err
+}
+
+// zstdReader returns its decoder to the pool when it has been read to the end.
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (n int, err error) {
+	if r.Decoder == nil {
+		return 0, io.EOF
+	}
+
+	if n, err = r.Decoder.Read(p); err == io.EOF {
+		r.pool.Put(r.Decoder)
+		r.Decoder = nil
+	}
+	return n, err
+}
+
+// snappyCompressor uses the snappy framing format.
+type snappyCompressor struct{}
+
+func (snappyCompressor) Name() string {
+	return Snappy
+}
+
+func (snappyCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+
+func (snappyCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return snappy.NewReader(r), nil
+}
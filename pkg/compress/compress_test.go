@@ -0,0 +1,145 @@
+package compress_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/bbengfort/switchback/pkg/compress"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var codecs = []string{compress.Gzip, compress.Zstd, compress.Snappy}
+
+func TestRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("switchback compresses repeated events "), 128)
+	for _, codec := range append(codecs, compress.None) {
+		if err := compress.Validate(codec); err != nil {
+			t.Errorf("expected %q to be a valid codec: %s", codec, err)
+		}
+
+		encoded, err := compress.Encode(codec, data)
+		if err != nil {
+			t.Fatalf("could not encode with %q: %s", codec, err)
+		}
+
+		if codec != compress.None && len(encoded) >= len(data) {
+			t.Errorf("expected %q to compress %d bytes, got %d bytes", codec, len(data), len(encoded))
+		}
+
+		decoded, err := compress.Decode(codec, encoded)
+		if err != nil {
+			t.Fatalf("could not decode with %q: %s", codec, err)
+		}
+
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("expected %q to decode the original data", codec)
+		}
+
+		// Empty data is also round tripped
+		if encoded, err = compress.Encode(codec, nil); err != nil {
+			t.Fatalf("could not encode empty data with %q: %s", codec, err)
+		}
+
+		if decoded, err = compress.Decode(codec, encoded); err != nil || len(decoded) != 0 {
+			t.Errorf("expected %q to decode empty data, got %d bytes (%v)", codec, len(decoded), err)
+		}
+	}
+}
+
+// Encoders and decoders that are pooled can be used concurrently and reused.
+func TestRoundTripConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for _, codec := range codecs {
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(codec string, i int) {
+				defer wg.Done()
+				data := bytes.Repeat([]byte{byte(i)}, 1024*(i+1))
+				for j := 0; j < 16; j++ {
+					encoded, err := compress.Encode(codec, data)
+					if err != nil {
+						t.Errorf("could not encode with %q: %s", codec, err)
+						return
+					}
+
+					decoded, err := compress.Decode(codec, encoded)
+					if err != nil || !bytes.Equal(decoded, data) {
+						t.Errorf("could not round trip with %q: %v", codec, err)
+						return
+					}
+				}
+			}(codec, i)
+		}
+	}
+	wg.Wait()
+}
+
+func TestUnknownCodec(t *testing.T) {
+	if err := compress.Validate("lz4"); err == nil {
+		t.Error("expected unknown codec to be invalid")
+	}
+
+	if _, err := compress.Encode("lz4", []byte("event")); err == nil {
+		t.Error("expected encoding with an unknown codec to fail")
+	}
+
+	if _, err := compress.Decode("lz4", []byte("event")); err == nil {
+		t.Error("expected decoding with an unknown codec to fail")
+	}
+}
+
+// Data can only be decoded with the codec it was encoded with.
+func TestMismatchedCodec(t *testing.T) {
+	for _, codec := range codecs {
+		encoded, err := compress.Encode(codec, []byte("event"))
+		if err != nil {
+			t.Fatalf("could not encode with %q: %s", codec, err)
+		}
+
+		for _, other := range codecs {
+			if other == codec {
+				continue
+			}
+
+			if decoded, err := compress.Decode(other, encoded); err == nil && bytes.Equal(decoded, []byte("event")) {
+				t.Errorf("expected data encoded with %q not to decode with %q", codec, other)
+			}
+		}
+	}
+}
+
+// Clients can select any of the codecs to compress their requests to a gRPC server.
+func TestCodecSelection(t *testing.T) {
+	sock, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %s", err)
+	}
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(sock)
+	defer srv.Stop()
+
+	cc, err := grpc.Dial(sock.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("could not dial server: %s", err)
+	}
+	defer cc.Close()
+	client := healthpb.NewHealthClient(cc)
+
+	for _, codec := range codecs {
+		if _, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.UseCompressor(codec)); err != nil {
+			t.Errorf("could not send request compressed with %q: %s", codec, err)
+		}
+	}
+
+	if _, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.UseCompressor("lz4")); err == nil {
+		t.Error("expected a request with an unknown codec to fail")
+	}
+}
@@ -1,31 +1,231 @@
 package config
 
 import (
-	"github.com/kelseyhightower/envconfig"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/bbengfort/switchback/pkg/compress"
 	"github.com/rs/zerolog"
 )
 
 type Config struct {
-	Maintenance bool         `split_words:"true" default:"false"`
-	LogLevel    LevelDecoder `split_words:"true" default:"info"`
-	ConsoleLog  bool         `split_words:"true" default:"false"`
-	BindAddr    string       `split_words:"true" default:":7773"`
-	processed   bool
+	Name            string        `yaml:"name" toml:"name"`
+	Maintenance     bool          `split_words:"true" default:"false" yaml:"maintenance" toml:"maintenance"`
+	LogLevel        LevelDecoder  `split_words:"true" default:"info" yaml:"log_level" toml:"log_level"`
+	ConsoleLog      bool          `split_words:"true" default:"false" yaml:"console_log" toml:"console_log"`
+	BindAddr        string        `split_words:"true" default:":7773" yaml:"bind_addr" toml:"bind_addr"`
+	Health          bool          `default:"true" yaml:"health" toml:"health"`
+	Reflection      bool          `default:"false" yaml:"reflection" toml:"reflection"`
+	ShutdownTimeout time.Duration `split_words:"true" default:"30s" yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	TLS             TLSConfig     `yaml:"tls" toml:"tls"`
+	Auth            AuthConfig    `yaml:"auth" toml:"auth"`
+	Limits          LimitsConfig  `yaml:"limits" toml:"limits"`
+	Events          EventsConfig  `yaml:"events" toml:"events"`
+	Metrics         MetricsConfig `yaml:"metrics" toml:"metrics"`
+	Gateway         GatewayConfig `yaml:"gateway" toml:"gateway"`
+	Tracing         TracingConfig `yaml:"tracing" toml:"tracing"`
+	Cluster         ClusterConfig `yaml:"cluster" toml:"cluster"`
+	Mirror          MirrorConfig  `yaml:"mirror" toml:"mirror"`
+	Webhooks        WebhookConfig `yaml:"webhooks" toml:"webhooks"`
+	MQTT            MQTTConfig    `yaml:"mqtt" toml:"mqtt"`
+	processed       bool
+	path            string
 }
 
-func New() (_ Config, err error) {
-	var conf Config
-	if err = envconfig.Process("switchback", &conf); err != nil {
-		return Config{}, err
-	}
+// TLSConfig specifies the server certificate and key to serve TLS with; if a client CA
+// is specified then clients must present a certificate signed by it (mutual TLS).
+type TLSConfig struct {
+	CertFile string `split_words:"true" yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `split_words:"true" yaml:"key_file" toml:"key_file"`
+	ClientCA string `envconfig:"CLIENT_CA" yaml:"client_ca" toml:"client_ca"`
+}
 
-	// Validate config-specific constraints
-	if err = conf.Validate(); err != nil {
-		return Config{}, err
-	}
+// AuthConfig specifies how clients are authenticated and the ACL that authorizes their
+// access to topics, either as rules in the config file or in a separate JSON file. If
+// auth is enabled but no ACL is specified then all authenticated principals are allowed
+// to publish and subscribe to any topic.
+type AuthConfig struct {
+	Enabled  bool              `default:"false" yaml:"enabled" toml:"enabled"`
+	APIKeys  map[string]string `envconfig:"API_KEYS" yaml:"api_keys" toml:"api_keys"`
+	JWKSFile string            `envconfig:"JWKS_FILE" yaml:"jwks_file" toml:"jwks_file"`
+	Issuer   string            `yaml:"issuer" toml:"issuer"`
+	Audience string            `yaml:"audience" toml:"audience"`
+	MTLS     bool              `envconfig:"MTLS" default:"false" yaml:"mtls" toml:"mtls"`
+	ACLFile  string            `envconfig:"ACL_FILE" yaml:"acl_file" toml:"acl_file"`
+	ACL      []auth.Rule       `ignored:"true" yaml:"acl" toml:"acl"`
+}
+
+// LimitsConfig specifies token bucket rate limits in events and bytes per second that
+// are applied globally, to each principal, and to each topic when events are published.
+// Zero values mean unlimited. If backpressure is enabled, publishers that exceed the
+// limits are slowed down rather than receiving a resource exhausted error. Events larger
+// than one second worth of a bytes limit are always rejected as invalid.
+type LimitsConfig struct {
+	Backpressure    bool    `default:"false" yaml:"backpressure" toml:"backpressure"`
+	GlobalEvents    float64 `split_words:"true" yaml:"global_events" toml:"global_events"`
+	GlobalBytes     float64 `split_words:"true" yaml:"global_bytes" toml:"global_bytes"`
+	PrincipalEvents float64 `split_words:"true" yaml:"principal_events" toml:"principal_events"`
+	PrincipalBytes  float64 `split_words:"true" yaml:"principal_bytes" toml:"principal_bytes"`
+	TopicEvents     float64 `split_words:"true" yaml:"topic_events" toml:"topic_events"`
+	TopicBytes      float64 `split_words:"true" yaml:"topic_bytes" toml:"topic_bytes"`
+	GroupDelivery   float64 `split_words:"true" yaml:"group_delivery" toml:"group_delivery"`
+}
+
+// EventsConfig specifies the constraints that published events must satisfy. The max
+// size limits the data payload in bytes; the producer id and the routing key are limited
+// by the max producer length and the max key size. Events from idempotent producers are deduplicated by
+// their sequence numbers within the dedup window; a zero window disables deduplication.
+// Open transactions are aborted after the transaction timeout, which also limits the
+// timeout a publisher may request, and may hold at most max transaction events. Batches
+// of published or delivered events are limited to the max batch size and subscribers
+// may not ask the server to linger for longer than the max linger to fill a batch. A
+// published batch is also limited to max batch bytes when serialized (or the maximum
+// message size of a single event if that is larger), which sets the maximum message
+// size the gRPC server will receive; larger batches are rejected as resource exhausted.
+type EventsConfig struct {
+	MaxSize              int           `split_words:"true" default:"1048576" yaml:"max_size" toml:"max_size"`
+	MaxTopicLength       int           `split_words:"true" default:"255" yaml:"max_topic_length" toml:"max_topic_length"`
+	TopicPattern         string        `split_words:"true" default:"^[A-Za-z0-9_-]+(\\.[A-Za-z0-9_-]+)*$" yaml:"topic_pattern" toml:"topic_pattern"`
+	MaxAttributes        int           `split_words:"true" default:"32" yaml:"max_attributes" toml:"max_attributes"`
+	MaxAttributeSize     int           `split_words:"true" default:"1024" yaml:"max_attribute_size" toml:"max_attribute_size"`
+	MaxProducerLength    int           `split_words:"true" default:"255" yaml:"max_producer_length" toml:"max_producer_length"`
+	MaxKeySize           int           `split_words:"true" default:"1024" yaml:"max_key_size" toml:"max_key_size"`
+	DedupWindow          time.Duration `split_words:"true" default:"5m" yaml:"dedup_window" toml:"dedup_window"`
+	TransactionTimeout   time.Duration `split_words:"true" default:"1m" yaml:"transaction_timeout" toml:"transaction_timeout"`
+	MaxTransactionEvents int           `split_words:"true" default:"10000" yaml:"max_transaction_events" toml:"max_transaction_events"`
+	MaxBatchSize         int           `split_words:"true" default:"1000" yaml:"max_batch_size" toml:"max_batch_size"`
+	MaxLinger            time.Duration `split_words:"true" default:"5s" yaml:"max_linger" toml:"max_linger"`
+	MaxBatchBytes        int           `split_words:"true" default:"16777216" yaml:"max_batch_bytes" toml:"max_batch_bytes"`
+}
+
+// GatewayConfig specifies the address of the HTTP/JSON gateway for clients that cannot
+// use gRPC. The gateway is served with the same TLS configuration and authentication
+// as the gRPC server. Clients must send the headers of a request within the header
+// timeout so that slow clients cannot hold connections open indefinitely. Browsers may only open WebSockets from the listed origins (or any
+// origin with *); with no origins, only same-origin WebSockets are allowed.
+type GatewayConfig struct {
+	Enabled       bool          `default:"false" yaml:"enabled" toml:"enabled"`
+	BindAddr      string        `split_words:"true" default:":7776" yaml:"bind_addr" toml:"bind_addr"`
+	Origins       []string      `yaml:"origins" toml:"origins"`
+	PingInterval  time.Duration `split_words:"true" default:"30s" yaml:"ping_interval" toml:"ping_interval"`
+	WriteTimeout  time.Duration `split_words:"true" default:"10s" yaml:"write_timeout" toml:"write_timeout"`
+	HeaderTimeout time.Duration `split_words:"true" default:"10s" yaml:"header_timeout" toml:"header_timeout"`
+}
+
+// MetricsConfig specifies the address of the http server that Prometheus metrics are
+// served on at the /metrics path.
+type MetricsConfig struct {
+	Enabled  bool   `default:"false" yaml:"enabled" toml:"enabled"`
+	BindAddr string `split_words:"true" default:":7774" yaml:"bind_addr" toml:"bind_addr"`
+}
+
+// TracingConfig specifies how OpenTelemetry spans are exported: either via OTLP to a
+// collector at the endpoint or to stdout (useful for tests and debugging).
+type TracingConfig struct {
+	Enabled     bool    `default:"false" yaml:"enabled" toml:"enabled"`
+	Exporter    string  `default:"otlp" yaml:"exporter" toml:"exporter"`
+	Endpoint    string  `default:"localhost:4317" yaml:"endpoint" toml:"endpoint"`
+	Insecure    bool    `default:"true" yaml:"insecure" toml:"insecure"`
+	SampleRatio float64 `split_words:"true" default:"1.0" yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+// ClusterConfig specifies how the node joins a Raft cluster that replicates the events
+// published to each topic and the offsets acknowledged by each group. Peers are the
+// id@host:port raft addresses of every node in the cluster, including this one, and the
+// advertised address is the endpoint clients of this node's gRPC server connect to. If
+// no data directory is specified the raft log is kept in memory and a restarted node
+// recovers its state from its peers. If a compression codec is specified, events are
+// compressed in the raft log and snapshots and requests forwarded to the leader are
+// compressed in transit.
+type ClusterConfig struct {
+	Enabled        bool          `default:"false" yaml:"enabled" toml:"enabled"`
+	NodeID         string        `split_words:"true" yaml:"node_id" toml:"node_id"`
+	BindAddr       string        `split_words:"true" default:":7775" yaml:"bind_addr" toml:"bind_addr"`
+	Advertise      string        `yaml:"advertise" toml:"advertise"`
+	Peers          []string      `yaml:"peers" toml:"peers"`
+	DataDir        string        `split_words:"true" yaml:"data_dir" toml:"data_dir"`
+	Retention      int           `default:"1024" yaml:"retention" toml:"retention"`
+	CommitInterval time.Duration `split_words:"true" default:"1s" yaml:"commit_interval" toml:"commit_interval"`
+	Compression    string        `yaml:"compression" toml:"compression"`
+}
+
+// MirrorConfig specifies remote switchback servers to copy topics from. Remotes can only
+// be specified in the config file. Mirror progress is checkpointed to the checkpoint
+// file (if specified) so that events replayed by a remote are not copied twice. Only
+// remotes that are clustered are checkpointed, since the offsets of a standalone server
+// restart from zero when it restarts.
+type MirrorConfig struct {
+	Enabled    bool           `default:"false" yaml:"enabled" toml:"enabled"`
+	Checkpoint string         `yaml:"checkpoint" toml:"checkpoint"`
+	Refresh    time.Duration  `default:"30s" yaml:"refresh" toml:"refresh"`
+	Remotes    []RemoteConfig `ignored:"true" yaml:"remotes" toml:"remotes"`
+}
+
+// RemoteConfig specifies a remote server and the glob patterns of the topics to copy
+// from it. Events are read from the remote as a member of the consumer group, which
+// defaults to a group named after this server. Events can be compressed in transit with
+// the specified compression codec. The token is only sent to remotes connected with TLS
+// unless insecure token is set.
+type RemoteConfig struct {
+	Name          string   `yaml:"name" toml:"name"`
+	Endpoint      string   `yaml:"endpoint" toml:"endpoint"`
+	Topics        []string `yaml:"topics" toml:"topics"`
+	Group         string   `yaml:"group" toml:"group"`
+	Token         string   `yaml:"token" toml:"token"`
+	InsecureToken bool     `yaml:"insecure_token" toml:"insecure_token"`
+	CA            string   `yaml:"ca" toml:"ca"`
+	CertFile      string   `yaml:"cert_file" toml:"cert_file"`
+	KeyFile       string   `yaml:"key_file" toml:"key_file"`
+	Compression   string   `yaml:"compression" toml:"compression"`
+}
+
+// WebhookConfig specifies push subscriptions that the server delivers by POSTing each
+// event to a URL. Hooks can only be specified in the config file. Failed deliveries are
+// retried with exponential backoff starting at the backoff and doubling up to the max
+// backoff; events that cannot be delivered after the max attempts are dead-lettered.
+type WebhookConfig struct {
+	Enabled     bool          `default:"false" yaml:"enabled" toml:"enabled"`
+	MaxAttempts int           `split_words:"true" default:"5" yaml:"max_attempts" toml:"max_attempts"`
+	Backoff     time.Duration `default:"1s" yaml:"backoff" toml:"backoff"`
+	MaxBackoff  time.Duration `split_words:"true" default:"1m" yaml:"max_backoff" toml:"max_backoff"`
+	Timeout     time.Duration `default:"10s" yaml:"timeout" toml:"timeout"`
+	Hooks       []HookConfig  `ignored:"true" yaml:"hooks" toml:"hooks"`
+}
+
+// HookConfig specifies the topic and URL of a push subscription. Events are consumed as
+// a member of the consumer group, which defaults to a group named after the hook. If a
+// secret is specified, payloads are signed with it so the receiver can verify them.
+// Events that cannot be delivered are published to the dead letter topic if specified,
+// otherwise they are dropped.
+type HookConfig struct {
+	Name       string `yaml:"name" toml:"name"`
+	Topic      string `yaml:"topic" toml:"topic"`
+	Group      string `yaml:"group" toml:"group"`
+	URL        string `yaml:"url" toml:"url"`
+	Secret     string `yaml:"secret" toml:"secret"`
+	DeadLetter string `yaml:"dead_letter" toml:"dead_letter"`
+}
+
+// MQTTConfig specifies the listener for MQTT 3.1.1 clients, which is served with the
+// same TLS configuration and authentication as the gRPC server. Subscriptions with
+// wildcards discover new topics at the refresh interval. At most max inflight QoS 1
+// messages are sent to each subscription before they are acknowledged.
+type MQTTConfig struct {
+	Enabled     bool          `default:"false" yaml:"enabled" toml:"enabled"`
+	BindAddr    string        `split_words:"true" default:":1883" yaml:"bind_addr" toml:"bind_addr"`
+	Refresh     time.Duration `default:"1s" yaml:"refresh" toml:"refresh"`
+	MaxInflight int           `split_words:"true" default:"32" yaml:"max_inflight" toml:"max_inflight"`
+}
 
-	conf.processed = true
-	return conf, nil
+// New returns the configuration from defaults and the environment.
+func New() (Config, error) {
+	return Load("")
 }
 
 func (c Config) GetLogLevel() zerolog.Level {
@@ -37,5 +237,260 @@ func (c Config) IsZero() bool {
 }
 
 func (c Config) Validate() error {
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("invalid configuration: shutdown timeout must be positive")
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Auth.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Limits.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Events.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Gateway.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Tracing.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Cluster.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Mirror.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Webhooks.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.MQTT.Validate(); err != nil {
+		return err
+	}
+
+	if c.Auth.Enabled && c.Auth.MTLS && !c.TLS.Mutual() {
+		return errors.New("invalid configuration: mtls authentication requires a tls client ca")
+	}
+	return nil
+}
+
+// Enabled returns true if the server should serve TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// Mutual returns true if clients are required to present a certificate.
+func (c TLSConfig) Mutual() bool {
+	return c.ClientCA != ""
+}
+
+func (c TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("invalid configuration: both tls cert file and key file must be specified")
+	}
+
+	if c.ClientCA != "" && !c.Enabled() {
+		return errors.New("invalid configuration: mutual tls requires a server cert and key")
+	}
+	return nil
+}
+
+func (c AuthConfig) Validate() error {
+	if c.Enabled && len(c.APIKeys) == 0 && c.JWKSFile == "" && !c.MTLS {
+		return errors.New("invalid configuration: auth requires api keys, a jwks file, or mtls")
+	}
+
+	if c.ACLFile != "" && len(c.ACL) > 0 {
+		return errors.New("invalid configuration: specify either an acl file or acl rules, not both")
+	}
+
+	if err := auth.ACL(c.ACL).Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+func (c LimitsConfig) Validate() error {
+	for _, limit := range []float64{c.GlobalEvents, c.GlobalBytes, c.PrincipalEvents, c.PrincipalBytes, c.TopicEvents, c.TopicBytes, c.GroupDelivery} {
+		if limit < 0 {
+			return errors.New("invalid configuration: rate limits cannot be negative")
+		}
+	}
+	return nil
+}
+
+func (c EventsConfig) Validate() error {
+	if c.MaxSize <= 0 || c.MaxTopicLength <= 0 || c.MaxAttributes < 0 || c.MaxAttributeSize <= 0 || c.MaxProducerLength <= 0 || c.MaxKeySize <= 0 {
+		return errors.New("invalid configuration: event limits must be positive")
+	}
+
+	if c.DedupWindow < 0 {
+		return errors.New("invalid configuration: dedup window cannot be negative")
+	}
+
+	if c.TransactionTimeout <= 0 || c.MaxTransactionEvents <= 0 {
+		return errors.New("invalid configuration: transaction limits must be positive")
+	}
+
+	if c.MaxBatchSize <= 0 || c.MaxBatchBytes <= 0 || c.MaxLinger < 0 {
+		return errors.New("invalid configuration: batch limits must be positive")
+	}
+
+	if _, err := regexp.Compile(c.TopicPattern); err != nil {
+		return fmt.Errorf("invalid configuration: could not compile topic pattern: %w", err)
+	}
+	return nil
+}
+
+func (c GatewayConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.PingInterval <= 0 || c.WriteTimeout <= 0 || c.HeaderTimeout <= 0 {
+		return errors.New("invalid configuration: gateway ping interval, write timeout, and header timeout must be positive")
+	}
+	return nil
+}
+
+func (c TracingConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Exporter != "otlp" && c.Exporter != "stdout" {
+		return fmt.Errorf("invalid configuration: unknown tracing exporter %q", c.Exporter)
+	}
+
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return errors.New("invalid configuration: tracing sample ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+func (c ClusterConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.NodeID == "" || c.BindAddr == "" {
+		return errors.New("invalid configuration: cluster requires a node id and bind addr")
+	}
+
+	if c.Retention <= 0 || c.CommitInterval <= 0 {
+		return errors.New("invalid configuration: cluster retention and commit interval must be positive")
+	}
+
+	member := false
+	for _, peer := range c.Peers {
+		parts := strings.SplitN(peer, "@", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid configuration: cluster peer %q must be id@host:port", peer)
+		}
+
+		if parts[0] == c.NodeID {
+			member = true
+		}
+	}
+
+	if !member {
+		return errors.New("invalid configuration: cluster peers must include this node")
+	}
+
+	if err := compress.Validate(c.Compression); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+func (c MirrorConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Refresh <= 0 {
+		return errors.New("invalid configuration: mirror refresh interval must be positive")
+	}
+
+	names := make(map[string]struct{}, len(c.Remotes))
+	for _, remote := range c.Remotes {
+		if remote.Name == "" || remote.Endpoint == "" || len(remote.Topics) == 0 {
+			return errors.New("invalid configuration: mirror remotes require a name, endpoint, and topics")
+		}
+
+		if _, ok := names[remote.Name]; ok {
+			return fmt.Errorf("invalid configuration: duplicate mirror remote %q", remote.Name)
+		}
+		names[remote.Name] = struct{}{}
+
+		for _, pattern := range remote.Topics {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid configuration: invalid mirror topic pattern %q", pattern)
+			}
+		}
+
+		if (remote.CertFile == "") != (remote.KeyFile == "") {
+			return fmt.Errorf("invalid configuration: mirror remote %q requires both a cert and key file", remote.Name)
+		}
+
+		if err := compress.Validate(remote.Compression); err != nil {
+			return fmt.Errorf("invalid configuration: mirror remote %q: %w", remote.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c WebhookConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.MaxAttempts <= 0 || c.Backoff <= 0 || c.MaxBackoff < c.Backoff || c.Timeout <= 0 {
+		return errors.New("invalid configuration: webhook attempts, backoff, and timeout must be positive")
+	}
+
+	names := make(map[string]struct{}, len(c.Hooks))
+	for _, hook := range c.Hooks {
+		if hook.Name == "" || hook.Topic == "" || hook.URL == "" {
+			return errors.New("invalid configuration: webhooks require a name, topic, and url")
+		}
+
+		if _, ok := names[hook.Name]; ok {
+			return fmt.Errorf("invalid configuration: duplicate webhook %q", hook.Name)
+		}
+		names[hook.Name] = struct{}{}
+
+		if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid configuration: webhook %q requires an http or https url", hook.Name)
+		}
+
+		if hook.DeadLetter == hook.Topic {
+			return fmt.Errorf("invalid configuration: webhook %q cannot dead letter to the topic it consumes", hook.Name)
+		}
+	}
+	return nil
+}
+
+func (c MQTTConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Refresh <= 0 || c.MaxInflight <= 0 || c.MaxInflight > 65535 {
+		return errors.New("invalid configuration: mqtt refresh must be positive and max inflight between 1 and 65535")
+	}
 	return nil
 }
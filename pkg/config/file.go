@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+const prefix = "switchback"
+
+// Load returns the configuration from defaults, the YAML or TOML file at path (if
+// specified), and the environment in increasing order of precedence; e.g. an explicitly
+// set environment variable overrides the same setting in the config file.
+func Load(path string) (_ Config, err error) {
+	var conf Config
+	if err = envconfig.Process(prefix, &conf); err != nil {
+		return Config{}, err
+	}
+
+	if path != "" {
+		if err = conf.decode(path); err != nil {
+			return Config{}, err
+		}
+
+		// Reapply the environment since the file overwrote it along with the defaults
+		var env Config
+		if err = envconfig.Process(prefix, &env); err != nil {
+			return Config{}, err
+		}
+		overrideFromEnv(strings.ToUpper(prefix), reflect.ValueOf(&conf).Elem(), reflect.ValueOf(env))
+	}
+
+	// The server name is recorded as the source of events published to it
+	if conf.Name == "" {
+		if conf.Name, err = os.Hostname(); err != nil {
+			return Config{}, fmt.Errorf("could not determine server name: %w", err)
+		}
+	}
+
+	// Cluster peers refer clients to this node at its bind address by default
+	if conf.Cluster.Advertise == "" {
+		conf.Cluster.Advertise = conf.BindAddr
+	}
+
+	// Validate config-specific constraints
+	if err = conf.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	conf.processed = true
+	conf.path = path
+	return conf, nil
+}
+
+// Reload reads the configuration again from the same file and environment it was
+// originally loaded from.
+func (c Config) Reload() (Config, error) {
+	return Load(c.path)
+}
+
+// Path returns the config file the configuration was loaded from, if any.
+func (c Config) Path() string {
+	return c.path
+}
+
+// decode the config file over the current config; unknown keys are an error so that
+// typos in the file do not silently fall back to the defaults.
+func (c *Config) decode(path string) (err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("could not parse config file: %w", err)
+		}
+	case ".toml":
+		var meta toml.MetaData
+		if meta, err = toml.Decode(string(data), c); err != nil {
+			return fmt.Errorf("could not parse config file: %w", err)
+		}
+
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("could not parse config file: unknown key %q", undecoded[0].String())
+		}
+	default:
+		return fmt.Errorf("unknown config file format %q, use .yaml, .yml, or .toml", ext)
+	}
+	return nil
+}
+
+var (
+	gatherRegexp  = regexp.MustCompile("([^A-Z]+|[A-Z]+[^A-Z]+|[A-Z]+)")
+	acronymRegexp = regexp.MustCompile("([A-Z]+)([A-Z][^A-Z]+)")
+)
+
+// overrideFromEnv copies every field from env to conf whose environment variable is
+// set. Keys are derived from the struct tags in the same way as envconfig.
+func overrideFromEnv(prefix string, conf, env reflect.Value) {
+	for i := 0; i < conf.NumField(); i++ {
+		field := conf.Type().Field(i)
+		if !conf.Field(i).CanSet() || field.Tag.Get("ignored") == "true" {
+			continue
+		}
+
+		alt := strings.ToUpper(field.Tag.Get("envconfig"))
+		key := field.Name
+		if field.Tag.Get("split_words") == "true" {
+			words := make([]string, 0, 4)
+			for _, word := range gatherRegexp.FindAllString(field.Name, -1) {
+				if m := acronymRegexp.FindStringSubmatch(word); len(m) == 3 {
+					words = append(words, m[1], m[2])
+				} else {
+					words = append(words, word)
+				}
+			}
+			key = strings.Join(words, "_")
+		}
+
+		if alt != "" {
+			key = alt
+		}
+		key = strings.ToUpper(prefix + "_" + key)
+
+		if field.Type.Kind() == reflect.Struct {
+			overrideFromEnv(key, conf.Field(i), env.Field(i))
+			continue
+		}
+
+		_, ok := os.LookupEnv(key)
+		if !ok && alt != "" {
+			_, ok = os.LookupEnv(alt)
+		}
+
+		if ok {
+			conf.Field(i).Set(env.Field(i))
+		}
+	}
+}
@@ -33,3 +33,8 @@ func (ll *LevelDecoder) Decode(value string) error {
 	}
 	return nil
 }
+
+// UnmarshalText allows the log level to be specified by name in YAML and TOML files.
+func (ll *LevelDecoder) UnmarshalText(text []byte) error {
+	return ll.Decode(string(text))
+}
@@ -0,0 +1,196 @@
+/*
+Package dedup remembers the offsets assigned to recent events from idempotent producers
+so that events a producer retries after a failure can be identified as duplicates. A
+producer declares an id and numbers its events with increasing sequence numbers; an
+event whose sequence is not greater than the highest sequence seen from the producer on
+the topic is a duplicate, and if it is still within the window the offset that was
+originally assigned to it is returned so the producer can treat the retry as a success.
+*/
+package dedup
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDuplicate is returned when an idempotent producer publishes an event with a sequence
+// number it has already published to the topic.
+var ErrDuplicate = errors.New("event is a duplicate of a previously published event")
+
+// maxSequences bounds the number of sequences remembered per producer and topic so that
+// a high rate producer does not grow the window without bound. Older sequences are
+// still detected as duplicates but their original offset is no longer known.
+const maxSequences = 4096
+
+// Window tracks producer sequences per topic for the configured duration. A zero window
+// disables deduplication. Callers supply the current time so that replicated state
+// machines can use the time recorded in their log and remain deterministic.
+type Window struct {
+	sync.Mutex
+	window time.Duration
+	topics map[string]map[string]*producer
+	swept  time.Time
+}
+
+// producer is the recent history of a single producer on a topic.
+type producer struct {
+	highest uint64
+	seen    time.Time
+	history []entry
+}
+
+type entry struct {
+	Sequence uint64 `json:"sequence"`
+	Offset   uint64 `json:"offset"`
+	Time     int64  `json:"time"`
+}
+
+// New returns a window that remembers producer sequences for the specified duration.
+func New(window time.Duration) *Window {
+	return &Window{window: window, topics: make(map[string]map[string]*producer)}
+}
+
+// Enabled returns true if the window deduplicates events.
+func (w *Window) Enabled() bool {
+	return w != nil && w.window > 0
+}
+
+// Duration returns how long producer sequences are remembered.
+func (w *Window) Duration() time.Duration {
+	if w == nil {
+		return 0
+	}
+	return w.window
+}
+
+// Check returns true if the event with the producer and sequence was already published
+// to the topic along with the offset it was assigned (zero if it has left the window).
+// Events without a producer id or sequence number are never duplicates.
+func (w *Window) Check(topic, id string, sequence uint64, now time.Time) (offset uint64, duplicate bool) {
+	if !w.Enabled() || id == "" || sequence == 0 {
+		return 0, false
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	w.expire(now)
+
+	// Entries outside the window are ignored whether or not they have been swept yet so
+	// that the result only depends on the sequence of calls and the times supplied.
+	cutoff := now.Add(-w.window)
+	p, ok := w.topics[topic][id]
+	if !ok || p.seen.Before(cutoff) || sequence > p.highest {
+		return 0, false
+	}
+
+	for i := len(p.history) - 1; i >= 0 && p.history[i].Time >= cutoff.UnixNano(); i-- {
+		if p.history[i].Sequence == sequence {
+			return p.history[i].Offset, true
+		}
+	}
+	return 0, true
+}
+
+// Record the offset assigned to the producer's event on the topic.
+func (w *Window) Record(topic, id string, sequence, offset uint64, now time.Time) {
+	if !w.Enabled() || id == "" || sequence == 0 {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	producers, ok := w.topics[topic]
+	if !ok {
+		producers = make(map[string]*producer)
+		w.topics[topic] = producers
+	}
+
+	p, ok := producers[id]
+	if !ok || p.seen.Before(now.Add(-w.window)) {
+		p = &producer{history: make([]entry, 0, 16)}
+		producers[id] = p
+	}
+
+	if sequence > p.highest {
+		p.highest = sequence
+	}
+	p.seen = now
+	p.history = append(p.history, entry{Sequence: sequence, Offset: offset, Time: now.UnixNano()})
+	if len(p.history) > maxSequences {
+		p.history = p.history[len(p.history)-maxSequences:]
+	}
+}
+
+// Must hold the lock to call this method. The history of each producer is trimmed to
+// the window, and producers that have not published within the window are forgotten so
+// that their sequence numbers may restart. Sweeps happen at most once per window.
+func (w *Window) expire(now time.Time) {
+	if now.Sub(w.swept) < w.window {
+		return
+	}
+	w.swept = now
+
+	cutoff := now.Add(-w.window)
+	for topic, producers := range w.topics {
+		for id, p := range producers {
+			if p.seen.Before(cutoff) {
+				delete(producers, id)
+				continue
+			}
+
+			i := 0
+			for i < len(p.history) && p.history[i].Time < cutoff.UnixNano() {
+				i++
+			}
+			p.history = p.history[i:]
+		}
+
+		if len(producers) == 0 {
+			delete(w.topics, topic)
+		}
+	}
+}
+
+// state is the serialized form of a producer for snapshots.
+type state struct {
+	Highest uint64  `json:"highest"`
+	Seen    int64   `json:"seen"`
+	History []entry `json:"history"`
+}
+
+// MarshalJSON serializes the producer histories, e.g. for a cluster snapshot.
+func (w *Window) MarshalJSON() ([]byte, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	topics := make(map[string]map[string]state, len(w.topics))
+	for topic, producers := range w.topics {
+		topics[topic] = make(map[string]state, len(producers))
+		for id, p := range producers {
+			topics[topic][id] = state{Highest: p.highest, Seen: p.seen.UnixNano(), History: p.history}
+		}
+	}
+	return json.Marshal(topics)
+}
+
+// UnmarshalJSON replaces the producer histories with the serialized state.
+func (w *Window) UnmarshalJSON(data []byte) (err error) {
+	var topics map[string]map[string]state
+	if err = json.Unmarshal(data, &topics); err != nil {
+		return err
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	w.topics = make(map[string]map[string]*producer, len(topics))
+	for topic, producers := range topics {
+		w.topics[topic] = make(map[string]*producer, len(producers))
+		for id, s := range producers {
+			w.topics[topic][id] = &producer{highest: s.Highest, seen: time.Unix(0, s.Seen), history: s.History}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package switchback
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufSize = 1024 * 1024
+
+// ServeEmbedded runs the server in-process on an in-memory bufconn listener rather than
+// a TCP socket. It returns immediately once the server is ready to accept requests;
+// use Client to connect to it and Shutdown to stop it. This is primarily intended for
+// tests and for single-binary applications that embed a switchback server.
+func (s *Server) ServeEmbedded() error {
+	if s.bufnet != nil {
+		return errors.New("embedded server is already running")
+	}
+
+	s.bufnet = bufconn.Listen(bufSize)
+	s.started = time.Now()
+	go s.Run(s.bufnet)
+	s.setServing(true)
+	if s.cluster != nil {
+		go s.replicate()
+	}
+
+	if s.mirrors != nil {
+		s.startMirrors()
+	}
+
+	if s.webhooks != nil {
+		s.startWebhooks()
+	}
+	log.Info().Str("listen", "bufconn").Str("version", Version()).Msg("switchback embedded server started")
+	return nil
+}
+
+// Client returns a ready client connected to the embedded server. The underlying
+// connection is closed when the server is shutdown.
+func (s *Server) Client(ctx context.Context, opts ...grpc.DialOption) (_ api.SwitchbackClient, err error) {
+	if s.bufnet == nil {
+		return nil, errors.New("embedded server is not running")
+	}
+
+	opts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.bufnet.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	}, opts...)
+
+	var cc *grpc.ClientConn
+	if cc, err = grpc.DialContext(ctx, "bufnet", opts...); err != nil {
+		return nil, err
+	}
+
+	s.connmu.Lock()
+	s.conns = append(s.conns, cc)
+	s.connmu.Unlock()
+	return api.NewSwitchbackClient(cc), nil
+}
+
+// PubSub returns the router the server publishes events to so that embedded servers
+// can publish and subscribe in-process without going through gRPC.
+func (s *Server) PubSub() *PubSub {
+	return s.pubsub
+}
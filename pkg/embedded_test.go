@@ -0,0 +1,253 @@
+package switchback_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+// serveEmbedded runs an embedded server with the default config, modified by the
+// configure function if given, and shuts it down when the test is complete.
+func serveEmbedded(t *testing.T, configure func(*config.Config)) *switchback.Server {
+	t.Helper()
+	conf, err := config.New()
+	if err != nil {
+		t.Fatalf("could not load config: %s", err)
+	}
+
+	conf.LogLevel = config.LevelDecoder(zerolog.ErrorLevel)
+	conf.ShutdownTimeout = time.Second
+	if configure != nil {
+		configure(&conf)
+	}
+
+	srv, err := switchback.New(conf)
+	if err != nil {
+		t.Fatalf("could not create server: %s", err)
+	}
+
+	if err = srv.ServeEmbedded(); err != nil {
+		t.Fatalf("could not serve embedded server: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := srv.Shutdown(); err != nil {
+			t.Errorf("could not shutdown server: %s", err)
+		}
+	})
+	return srv
+}
+
+// embeddedClient returns a client connected to the embedded server.
+func embeddedClient(t *testing.T, srv *switchback.Server) api.SwitchbackClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := srv.Client(ctx)
+	if err != nil {
+		t.Fatalf("could not connect to embedded server: %s", err)
+	}
+	return client
+}
+
+// receive reads n events from the channel or fails the test after the timeout.
+func receive(t *testing.T, events <-chan *api.Event, n int) []*api.Event {
+	t.Helper()
+	out := make([]*api.Event, 0, n)
+	timeout := time.After(5 * time.Second)
+	for len(out) < n {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("events closed after %d of %d events", len(out), n)
+			}
+			out = append(out, event)
+		case <-timeout:
+			t.Fatalf("timed out after %d of %d events", len(out), n)
+		}
+	}
+	return out
+}
+
+func TestServeEmbedded(t *testing.T) {
+	srv := serveEmbedded(t, nil)
+	if err := srv.ServeEmbedded(); err == nil {
+		t.Error("expected an error serving the embedded server twice")
+	}
+
+	client := embeddedClient(t, srv)
+	state, err := client.Status(context.Background(), &api.HealthCheck{})
+	if err != nil {
+		t.Fatalf("could not get status: %s", err)
+	}
+
+	if state.Status != switchback.HealthOK {
+		t.Errorf("expected status %q, got %q", switchback.HealthOK, state.Status)
+	}
+}
+
+func TestClientNotServing(t *testing.T) {
+	conf, err := config.New()
+	if err != nil {
+		t.Fatalf("could not load config: %s", err)
+	}
+	conf.LogLevel = config.LevelDecoder(zerolog.ErrorLevel)
+
+	srv, err := switchback.New(conf)
+	if err != nil {
+		t.Fatalf("could not create server: %s", err)
+	}
+
+	if _, err = srv.Client(context.Background()); err == nil {
+		t.Error("expected an error connecting to a server that is not serving")
+	}
+}
+
+func TestEmbeddedPublishSubscribe(t *testing.T) {
+	srv := serveEmbedded(t, nil)
+	client := embeddedClient(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub, err := client.Subscribe(ctx, &api.Subscription{Topic: "embedded", Group: "testers"})
+	if err != nil {
+		t.Fatalf("could not subscribe: %s", err)
+	}
+
+	// The consumer is connected asynchronously, wait for the group before publishing
+	waitForGroups(t, srv.PubSub(), "embedded", 1)
+
+	pub, err := client.Publish(ctx)
+	if err != nil {
+		t.Fatalf("could not open publish stream: %s", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err = pub.Send(&api.Event{Topic: "embedded", Data: []byte(fmt.Sprintf("event %d", i))}); err != nil {
+			t.Fatalf("could not publish event %d: %s", i, err)
+		}
+	}
+
+	rep, err := pub.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("could not close publish stream: %s", err)
+	}
+
+	if rep.Events != 10 {
+		t.Errorf("expected 10 events published, got %d", rep.Events)
+	}
+
+	for i := 0; i < 10; i++ {
+		event, err := sub.Recv()
+		if err != nil {
+			t.Fatalf("could not receive event %d: %s", i, err)
+		}
+
+		if data := fmt.Sprintf("event %d", i); string(event.Data) != data {
+			t.Errorf("expected %q, got %q", data, event.Data)
+		}
+
+		if event.Meta.GetOffset() != uint64(i+1) {
+			t.Errorf("expected offset %d, got %d", i+1, event.Meta.GetOffset())
+		}
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	pubsub := switchback.NewPubSub()
+	defer pubsub.Close()
+
+	first, err := pubsub.Connect(&api.Subscription{Topic: "orders", Group: "billing"})
+	if err != nil {
+		t.Fatalf("could not connect consumer: %s", err)
+	}
+
+	other, err := pubsub.Connect(&api.Subscription{Topic: "orders"})
+	if err != nil {
+		t.Fatalf("could not connect consumer: %s", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err = pubsub.Publish(context.Background(), &api.Event{Topic: "orders", Data: []byte{byte(i)}}); err != nil {
+			t.Fatalf("could not publish event: %s", err)
+		}
+	}
+
+	// Every group receives every event
+	for _, consumer := range []*switchback.Consumer{first, other} {
+		for i, event := range receive(t, consumer.Events(), 3) {
+			if event.Meta.GetOffset() != uint64(i+1) {
+				t.Errorf("expected offset %d, got %d", i+1, event.Meta.GetOffset())
+			}
+		}
+	}
+
+	// Disconnecting the only consumer in a group removes the group
+	pubsub.Disconnect(other)
+	if _, ok := <-other.Events(); ok {
+		t.Error("expected the events of a disconnected consumer to be closed")
+	}
+
+	topics := pubsub.Topics()
+	if len(topics) != 1 || topics[0].Offset != 3 || topics[0].Groups != 1 {
+		t.Errorf("unexpected topics %+v", topics)
+	}
+}
+
+func TestPubSubConcurrentDisconnect(t *testing.T) {
+	pubsub := switchback.NewPubSub()
+	consumers := make([]*switchback.Consumer, 0, 100)
+	for i := 0; i < 100; i++ {
+		consumer, err := pubsub.Connect(&api.Subscription{Topic: "shutdown", Group: "racers"})
+		if err != nil {
+			t.Fatalf("could not connect consumer: %s", err)
+		}
+		consumers = append(consumers, consumer)
+	}
+
+	// Consumers are disconnected by their streams while the router is closed, e.g.
+	// when the server shuts down or loses leadership; neither may close them twice.
+	var wg sync.WaitGroup
+	for _, consumer := range consumers {
+		wg.Add(1)
+		go func(c *switchback.Consumer) {
+			defer wg.Done()
+			pubsub.Disconnect(c)
+		}(consumer)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pubsub.Close()
+	}()
+	wg.Wait()
+
+	if stats := pubsub.Stats(); len(stats) != 0 {
+		t.Errorf("expected all groups to be removed, got %+v", stats)
+	}
+}
+
+// waitForGroups waits until the topic has the number of consumer groups.
+func waitForGroups(t *testing.T, pubsub *switchback.PubSub, topic string, groups int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, stats := range pubsub.Topics() {
+			if stats.Topic == topic && stats.Groups >= groups {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d groups on %q", groups, topic)
+}
@@ -0,0 +1,329 @@
+package switchback
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/cluster"
+	"github.com/bbengfort/switchback/pkg/compress"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// forwardedHeader identifies the node that forwarded a request so that a node that
+	// is no longer the leader does not forward it again.
+	forwardedHeader = "x-switchback-forwarded-by"
+
+	// Subscriptions forwarded to a leader that fails are retried on the new leader.
+	forwardRetries = 5
+	forwardBackoff = 500 * time.Millisecond
+)
+
+// forwardPublish proxies a publish stream received by a follower to the cluster leader.
+// Each event is authorized and rate limited for the client before it is forwarded,
+// since with mutual TLS the leader authenticates the forwarded stream with the node's
+// certificate rather than the client's. The client's authorization header is forwarded
+// so that the leader also authenticates and validates the events; it does not admit them
+// again, so followers do not need permission to publish.
+func (s *Server) forwardPublish(stream publishServer, events <-chan *api.Event, errc <-chan error) (err error) {
+	principal := principalName(stream.Context())
+	var client api.SwitchbackClient
+	if client, err = s.leaderClient(stream.Context()); err != nil {
+		return err
+	}
+
+	var upstream api.Switchback_PublishClient
+	if upstream, err = client.Publish(s.forwardContext(stream.Context())); err != nil {
+		return err
+	}
+
+	drain := s.drainer()
+	for {
+		select {
+		case <-drain.start:
+			log.Debug().Msg("closing forwarded publisher to drain server")
+			return forwardReply(stream, upstream)
+		case err = <-errc:
+			if err != io.EOF {
+				log.Error().Err(err).Msg("could not recv event from stream")
+				return err
+			}
+			return forwardReply(stream, upstream)
+		case event := <-events:
+			if err = s.admit(stream.Context(), principal, event); err != nil {
+				return err
+			}
+
+			if err = upstream.Send(event); err != nil {
+				if err != io.EOF {
+					return err
+				}
+				// The leader closed the stream, reply with its final response or error
+				return forwardReply(stream, upstream)
+			}
+		}
+	}
+}
+
+func forwardReply(stream publishServer, upstream api.Switchback_PublishClient) error {
+	rep, err := upstream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(rep)
+}
+
+// forwardEvents publishes events received by a follower, e.g. from the gateway, to the
+// cluster leader on a new publish stream and returns the leader's reply. The events are
+// admitted for the client before any of them are forwarded.
+func (s *Server) forwardEvents(ctx context.Context, events []*api.Event) (_ *api.ClosePublish, err error) {
+	principal := principalName(ctx)
+	for _, event := range events {
+		if err = s.admit(ctx, principal, event); err != nil {
+			return nil, err
+		}
+	}
+
+	var client api.SwitchbackClient
+	if client, err = s.leaderClient(ctx); err != nil {
+		return nil, err
+	}
+
+	var upstream api.Switchback_PublishClient
+	if upstream, err = client.Publish(s.forwardContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		if err = upstream.Send(event); err != nil {
+			if err == io.EOF {
+				// The leader closed the stream, return its reply or error
+				break
+			}
+			return nil, err
+		}
+	}
+	return upstream.CloseAndRecv()
+}
+
+// forwardSubscribe proxies a subscription received by a follower to the cluster leader.
+// If the leader fails, the subscription is resumed on the newly elected leader (which
+// may be this node) from the group's last committed offset.
+func (s *Server) forwardSubscribe(in *api.Subscription, stream subscriber) (err error) {
+	atomic.AddInt32(&s.subs, 1)
+	defer atomic.AddInt32(&s.subs, -1)
+
+	for attempt := 0; ; attempt++ {
+		if err = s.proxySubscribe(in, stream); status.Code(err) != codes.Unavailable || attempt >= forwardRetries {
+			return err
+		}
+
+		log.Debug().Err(err).Int("attempt", attempt+1).Msg("retrying forwarded subscription")
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.drainer().start:
+			return err
+		case <-time.After(forwardBackoff):
+		}
+
+		if s.cluster.IsLeader() {
+			return s.subscribe(in, stream)
+		}
+	}
+}
+
+func (s *Server) proxySubscribe(in *api.Subscription, stream subscriber) (err error) {
+	var client api.SwitchbackClient
+	if client, err = s.leaderClient(stream.Context()); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(s.forwardContext(stream.Context()))
+	defer cancel()
+
+	var upstream func() ([]*api.Event, error)
+	if upstream, err = stream.proxy(ctx, client, in); err != nil {
+		return err
+	}
+
+	// Stop receiving events from the leader when the server drains
+	draining := make(chan struct{})
+	go func() {
+		select {
+		case <-s.drainer().start:
+			close(draining)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		var events []*api.Event
+		if events, err = upstream(); err != nil {
+			select {
+			case <-draining:
+				return nil
+			default:
+			}
+
+			if err == io.EOF || stream.Context().Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if err = stream.send(events); err != nil {
+			return err
+		}
+		s.subrate.Mark(uint64(len(events)))
+	}
+}
+
+// leaderClient returns a client connected to the cluster leader; connections to other
+// nodes are cached by endpoint and closed when the server shuts down. Requests that
+// have already been forwarded by another node are not forwarded again.
+func (s *Server) leaderClient(ctx context.Context) (_ api.SwitchbackClient, err error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(forwardedHeader)) > 0 {
+		return nil, s.notLeader()
+	}
+
+	id, endpoint := s.cluster.Leader()
+	if id == "" || endpoint == "" {
+		return nil, s.notLeader()
+	}
+
+	s.peermu.Lock()
+	defer s.peermu.Unlock()
+	if cc, ok := s.peers[endpoint]; ok {
+		return api.NewSwitchbackClient(cc), nil
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if s.certs != nil {
+		opts[0] = grpc.WithTransportCredentials(s.certs.ClientCredentials())
+	}
+
+	if codec := s.conf.Cluster.Compression; codec != compress.None {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(codec)))
+	}
+
+	var cc *grpc.ClientConn
+	if cc, err = grpc.Dial(endpoint, opts...); err != nil {
+		log.Error().Err(err).Str("leader", id).Str("endpoint", endpoint).Msg("could not connect to cluster leader")
+		return nil, status.Error(codes.Unavailable, "could not connect to the cluster leader")
+	}
+
+	if s.peers == nil {
+		s.peers = make(map[string]*grpc.ClientConn)
+	}
+	s.peers[endpoint] = cc
+	return api.NewSwitchbackClient(cc), nil
+}
+
+// forwardedKey marks the context of a request forwarded by another node of the cluster.
+type forwardedKey struct{}
+
+// forwardedContext marks the context if the request was forwarded by a cluster peer:
+// the forwarded header names another member of the cluster and the request was sent
+// from the host of that member's cluster address. The events of a forwarded request
+// were already admitted by the peer.
+func (s *Server) forwardedContext(ctx context.Context) context.Context {
+	if s.cluster == nil {
+		return ctx
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get(forwardedHeader)) != 1 {
+		return ctx
+	}
+	node := md.Get(forwardedHeader)[0]
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil || node == s.conf.Cluster.NodeID {
+		return ctx
+	}
+
+	remote, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return ctx
+	}
+
+	var members []cluster.Member
+	if members, err = s.cluster.Members(); err != nil {
+		return ctx
+	}
+
+	for _, member := range members {
+		if member.ID != node {
+			continue
+		}
+
+		var host string
+		if host, _, err = net.SplitHostPort(member.Addr); err != nil {
+			return ctx
+		}
+
+		var addrs []string
+		if addrs, err = net.LookupHost(host); err != nil {
+			return ctx
+		}
+
+		for _, addr := range addrs {
+			if net.ParseIP(addr).Equal(net.ParseIP(remote)) {
+				return context.WithValue(ctx, forwardedKey{}, node)
+			}
+		}
+	}
+
+	log.Warn().Str("node", node).Str("remote", remote).Msg("forwarded request is not from a cluster peer")
+	return ctx
+}
+
+// forwarded returns true if the context is of a request forwarded by a cluster peer.
+func forwarded(ctx context.Context) bool {
+	_, ok := ctx.Value(forwardedKey{}).(string)
+	return ok
+}
+
+// forwardContext returns the outgoing context for a forwarded request with the client's
+// authorization header and the id of this node.
+func (s *Server) forwardContext(ctx context.Context) context.Context {
+	md := metadata.Pairs(forwardedHeader, s.conf.Cluster.NodeID)
+	if in, ok := metadata.FromIncomingContext(ctx); ok {
+		if authorization := in.Get("authorization"); len(authorization) > 0 {
+			md.Set("authorization", authorization...)
+		}
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// members returns the cluster membership reported by the Status RPC.
+func (s *Server) members() (_ []*api.ClusterMember, err error) {
+	var members []cluster.Member
+	if members, err = s.cluster.Members(); err != nil {
+		return nil, err
+	}
+
+	out := make([]*api.ClusterMember, 0, len(members))
+	for _, member := range members {
+		out = append(out, &api.ClusterMember{
+			Id:       member.ID,
+			Address:  member.Addr,
+			Endpoint: member.Endpoint,
+			Leader:   member.Leader,
+			Voter:    member.Voter,
+		})
+	}
+	return out, nil
+}
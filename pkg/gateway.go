@@ -0,0 +1,472 @@
+package switchback
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Gateway endpoints; topics are addressed as /v1/topics/{topic}/events to publish and
+// /v1/topics/{topic}/subscribe to subscribe.
+const (
+	gatewayStatus      = "/v1/status"
+	gatewayTopics      = "/v1/topics"
+	gatewayMaintenance = "/v1/maintenance"
+	gatewaySubscribe   = "/v1/topics/subscribe"
+)
+
+var (
+	gatewayMarshal   = protojson.MarshalOptions{UseProtoNames: true}
+	gatewayUnmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// setupGateway creates the http server that maps JSON requests onto the gRPC service
+// for clients that cannot use gRPC. Requests are authenticated with the same
+// credentials as the gRPC service: an authorization header or a client certificate.
+func (s *Server) setupGateway() {
+	mux := http.NewServeMux()
+	mux.HandleFunc(gatewayStatus, s.gatewayStatus)
+	mux.HandleFunc(gatewayTopics, s.gatewayTopics)
+	mux.HandleFunc(gatewayTopics+"/", s.gatewayTopic)
+	mux.HandleFunc(gatewayMaintenance, s.gatewayMaintenance)
+	mux.HandleFunc(gatewayWebSocket, s.gatewayWebSocket)
+	s.gateway = &http.Server{Addr: s.conf.Gateway.BindAddr, Handler: mux, ReadHeaderTimeout: s.conf.Gateway.HeaderTimeout}
+
+	if s.certs != nil {
+		s.gateway.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: s.certs.GetCertificate,
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				conf, err := s.certs.GetConfigForClient(hello)
+				if err != nil {
+					return nil, err
+				}
+				conf.NextProtos = []string{"h2", "http/1.1"}
+				return conf, nil
+			},
+		}
+	}
+}
+
+// serveGateway runs the gateway http server until it is shutdown.
+func (s *Server) serveGateway() {
+	log.Info().Str("listen", s.conf.Gateway.BindAddr).Bool("tls", s.certs != nil).Msg("gateway server started")
+
+	var err error
+	if s.certs != nil {
+		err = s.gateway.ListenAndServeTLS("", "")
+	} else {
+		err = s.gateway.ListenAndServe()
+	}
+
+	if err != nil && err != http.ErrServerClosed {
+		s.echan <- err
+	}
+}
+
+// gatewayStatus returns the service state; like the Status RPC it does not require
+// authentication and is available in maintenance mode.
+func (s *Server) gatewayStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	out, err := s.Status(r.Context(), &api.HealthCheck{})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// gatewayTopics lists the topics matching the optional pattern query parameter.
+func (s *Server) gatewayTopics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	ctx, err := s.gatewayContext(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	out, err := s.ListTopics(ctx, &api.TopicQuery{Pattern: r.URL.Query().Get("pattern")})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// gatewayTopic dispatches requests to publish to or subscribe to a topic.
+func (s *Server) gatewayTopic(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, gatewayTopics+"/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		writeError(w, status.Errorf(codes.NotFound, "no gateway endpoint at %q", r.URL.Path))
+		return
+	}
+
+	topic := parts[0]
+	switch parts[1] {
+	case "events":
+		if allowMethod(w, r, http.MethodPost) {
+			s.gatewayPublish(w, r, topic)
+		}
+	case "subscribe":
+		if allowMethod(w, r, http.MethodGet) {
+			s.gatewaySubscribe(w, r, topic)
+		}
+	default:
+		writeError(w, status.Errorf(codes.NotFound, "no gateway endpoint at %q", r.URL.Path))
+	}
+}
+
+// gatewayPublish publishes a single event or a batch of events (an object with an
+// events array) to the topic and responds with a ClosePublish. The topic of the events
+// is taken from the path. Every event in a batch is validated and authorized before any
+// of them are published, so an invalid batch is rejected as a whole; only the rate
+// limits (or the attributes added for tracing) can reject an event after the events
+// before it in the batch were published, and those events are not rolled back.
+func (s *Server) gatewayPublish(w http.ResponseWriter, r *http.Request, topic string) {
+	ctx, err := s.gatewayContext(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var events []*api.Event
+	if events, err = s.readEvents(w, r); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	for _, event := range events {
+		if event.Topic != "" && event.Topic != topic {
+			writeError(w, status.Errorf(codes.InvalidArgument, "event topic %q does not match %q", event.Topic, topic))
+			return
+		}
+		event.Topic = topic
+
+		if err = s.check(ctx, event); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	var reply *api.ClosePublish
+	if s.cluster != nil && !s.cluster.IsLeader() {
+		if reply, err = s.forwardEvents(ctx, events); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, reply)
+		return
+	}
+
+	atomic.AddInt32(&s.pubs, 1)
+	defer atomic.AddInt32(&s.pubs, -1)
+
+	principal := principalName(ctx)
+	reply = &api.ClosePublish{}
+	for _, event := range events {
+		if err = s.publishReply(ctx, principal, event, reply); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// readEvents decodes the event or batch of events in the request body, which is limited
+// to the maximum message size of the gRPC service.
+func (s *Server) readEvents(w http.ResponseWriter, r *http.Request) (_ []*api.Event, err error) {
+	var body []byte
+	if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, int64(s.valid.MaxBatchMessageSize()))); err != nil {
+		return nil, status.Errorf(codes.ResourceExhausted, "could not read request body: %s", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(body, &fields); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "request body must be a JSON event or batch of events")
+	}
+
+	if _, ok := fields["events"]; ok {
+		batch := &api.EventBatch{}
+		if err = gatewayUnmarshal.Unmarshal(body, batch); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "could not parse batch: %s", err)
+		}
+
+		if len(batch.Events) > s.conf.Events.MaxBatchSize {
+			return nil, status.Errorf(codes.InvalidArgument, "batch has %d events, exceeding the maximum of %d", len(batch.Events), s.conf.Events.MaxBatchSize)
+		}
+		return batch.Events, nil
+	}
+
+	event := &api.Event{}
+	if err = gatewayUnmarshal.Unmarshal(body, event); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "could not parse event: %s", err)
+	}
+	return []*api.Event{event}, nil
+}
+
+// gatewaySubscribe connects the client to the topic's consumer group (the group query
+// parameter) and streams events as server-sent events if the client accepts them or as
+// newline delimited JSON otherwise. The stream ends when the client disconnects.
+func (s *Server) gatewaySubscribe(w http.ResponseWriter, r *http.Request, topic string) {
+	ctx, err := s.gatewayContext(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, status.Error(codes.Unimplemented, "streaming is not supported by the connection"))
+		return
+	}
+
+	// Check the subscription before the response is started so that the client receives
+	// an error status rather than an error in the stream.
+	in := &api.Subscription{Topic: topic, Group: r.URL.Query().Get("group")}
+	if err = s.valid.Topic(in.Topic); err != nil {
+		writeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	if err = s.authorize(ctx, auth.Subscribe, in.Topic, in.Group); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Track the stream so that it can be drained
+	closeStream, ok := s.openStream()
+	if !ok {
+		writeError(w, status.Error(codes.Unavailable, "the switchback server is currently in maintenance mode"))
+		return
+	}
+	defer closeStream()
+	activeStreams.WithLabelValues(gatewaySubscribe).Inc()
+	defer activeStreams.WithLabelValues(gatewaySubscribe).Dec()
+
+	stream := &httpStream{ctx: ctx, w: w, flusher: flusher, size: s.conf.Events.MaxBatchSize}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		stream.sse = true
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if err = s.subscribe(in, stream); err != nil {
+		log.Debug().Err(err).Str("topic", topic).Msg("gateway subscription closed")
+		stream.fail(err)
+	}
+}
+
+// gatewayMaintenance enters or exits maintenance mode with a MaintenanceRequest body.
+func (s *Server) gatewayMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	ctx, err := s.authenticateRequest(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var body []byte
+	if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, int64(s.valid.MaxMessageSize()))); err != nil {
+		writeError(w, status.Errorf(codes.ResourceExhausted, "could not read request body: %s", err))
+		return
+	}
+
+	in := &api.MaintenanceRequest{}
+	if err = gatewayUnmarshal.Unmarshal(body, in); err != nil {
+		writeError(w, status.Errorf(codes.InvalidArgument, "could not parse maintenance request: %s", err))
+		return
+	}
+
+	out, err := s.Maintenance(ctx, in)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// gatewayContext refuses requests in maintenance mode and otherwise authenticates them.
+func (s *Server) gatewayContext(r *http.Request) (context.Context, error) {
+	if s.InMaintenance() {
+		return nil, status.Error(codes.Unavailable, "the switchback server is currently in maintenance mode")
+	}
+	return s.authenticateRequest(r)
+}
+
+// authenticateRequest returns a context for the http request that carries its
+// credentials the way a gRPC request would: the authorization header as incoming
+// metadata and the client certificate as the peer's TLS info. If the server
+// authenticates clients, the principal is added to the context.
+func (s *Server) authenticateRequest(r *http.Request) (ctx context.Context, err error) {
+	md := metadata.MD{}
+	if authorization := r.Header.Values("Authorization"); len(authorization) > 0 {
+		md.Set("authorization", authorization...)
+	}
+	ctx = metadata.NewIncomingContext(r.Context(), md)
+
+	p := &peer.Peer{}
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		p.Addr = addr
+	}
+
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	ctx = peer.NewContext(ctx, p)
+
+	if s.authn == nil {
+		return ctx, nil
+	}
+	return s.authenticate(ctx)
+}
+
+// httpStream sends events to a gateway subscriber as server-sent events or newline
+// delimited JSON, flushing the response after each batch.
+type httpStream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	flusher http.Flusher
+	sse     bool
+	size    int
+}
+
+func (s *httpStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *httpStream) send(events []*api.Event) (err error) {
+	for _, event := range events {
+		var data []byte
+		if data, err = gatewayMarshal.Marshal(event); err != nil {
+			return err
+		}
+
+		if s.sse {
+			_, err = fmt.Fprintf(s.w, "id: %d\ndata: %s\n\n", event.Meta.GetOffset(), data)
+		} else {
+			_, err = fmt.Fprintf(s.w, "%s\n", data)
+		}
+
+		if err != nil {
+			return io.EOF
+		}
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *httpStream) batcher(bytes int) *batcher {
+	return newBatcher(s.size, 0, bytes)
+}
+
+func (s *httpStream) proxy(ctx context.Context, client api.SwitchbackClient, in *api.Subscription) (func() ([]*api.Event, error), error) {
+	return proxyEvents(ctx, client, in)
+}
+
+// fail writes the error that closed the subscription to the stream, since the status of
+// the response has already been sent.
+func (s *httpStream) fail(err error) {
+	data, _ := json.Marshal(map[string]string{"error": status.Convert(err).Message()})
+	if s.sse {
+		fmt.Fprintf(s.w, "event: error\ndata: %s\n\n", data)
+	} else {
+		fmt.Fprintf(s.w, "%s\n", data)
+	}
+	s.flusher.Flush()
+}
+
+// allowMethod writes a method not allowed response and returns false if the request
+// does not use the method.
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	writeErrorStatus(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed", r.Method))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, msg proto.Message) {
+	data, err := gatewayMarshal.Marshal(msg)
+	if err != nil {
+		writeError(w, status.Errorf(codes.Internal, "could not marshal response: %s", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// writeError responds with the http status that corresponds to the gRPC status error.
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeErrorStatus(w, httpStatus(st.Code()), st.Message())
+}
+
+func writeErrorStatus(w http.ResponseWriter, code int, msg string) {
+	data, _ := json.Marshal(map[string]string{"error": msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// httpStatus maps gRPC codes to http status codes.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,232 @@
+package switchback_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+// serveGateway runs a server with the gateway enabled and returns the server and the
+// address of the gateway once it is accepting requests.
+func serveGateway(t *testing.T, configure func(*config.Config)) (*switchback.Server, string) {
+	t.Helper()
+	conf, err := config.New()
+	if err != nil {
+		t.Fatalf("could not load config: %s", err)
+	}
+
+	conf.LogLevel = config.LevelDecoder(zerolog.ErrorLevel)
+	conf.ShutdownTimeout = time.Second
+	conf.BindAddr = freeAddr(t)
+	conf.Gateway.Enabled = true
+	conf.Gateway.BindAddr = freeAddr(t)
+	if configure != nil {
+		configure(&conf)
+	}
+
+	srv, err := switchback.New(conf)
+	if err != nil {
+		t.Fatalf("could not create server: %s", err)
+	}
+
+	go srv.Serve()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	waitFor(t, func() bool {
+		rep, err := http.Get("http://" + conf.Gateway.BindAddr + "/v1/status")
+		if err != nil {
+			return false
+		}
+		rep.Body.Close()
+		return true
+	})
+	return srv, conf.Gateway.BindAddr
+}
+
+// post sends the JSON body to the gateway with the token, if any, and returns the status
+// code and the decoded response.
+func post(t *testing.T, url, token, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("could not create request: %s", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rep, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not send request: %s", err)
+	}
+	defer rep.Body.Close()
+
+	out := make(map[string]interface{})
+	if err = json.NewDecoder(rep.Body).Decode(&out); err != nil {
+		t.Fatalf("could not decode response: %s", err)
+	}
+	return rep.StatusCode, out
+}
+
+func TestGatewayPublish(t *testing.T) {
+	srv, addr := serveGateway(t, nil)
+	url := "http://" + addr + "/v1/topics/orders/events"
+
+	consumer, err := srv.PubSub().Connect(&api.Subscription{Topic: "orders"})
+	if err != nil {
+		t.Fatalf("could not connect consumer: %s", err)
+	}
+
+	// A single event is published to the topic in the path
+	code, rep := post(t, url, "", `{"data": "b3JkZXI=", "attributes": {"id": "1"}}`)
+	if code != http.StatusOK || rep["events"] != "1" || rep["topic_offset"] != "1" {
+		t.Fatalf("expected the event to be published, got %d: %v", code, rep)
+	}
+
+	// A batch of events is published in order
+	code, rep = post(t, url, "", `{"events": [{"data": "b3JkZXI=", "attributes": {"id": "2"}}, {"topic": "orders", "data": "b3JkZXI=", "attributes": {"id": "3"}}]}`)
+	if code != http.StatusOK || rep["events"] != "2" || rep["topic_offset"] != "3" {
+		t.Fatalf("expected the batch to be published, got %d: %v", code, rep)
+	}
+
+	// A batch with an invalid event is rejected as a whole
+	code, rep = post(t, url, "", `{"events": [{"data": "b3JkZXI="}, {"topic": "payments", "data": "b3JkZXI="}]}`)
+	if code != http.StatusBadRequest || !strings.Contains(rep["error"].(string), "does not match") {
+		t.Fatalf("expected the batch to be rejected, got %d: %v", code, rep)
+	}
+
+	if code, _ = post(t, url, "", `not json`); code != http.StatusBadRequest {
+		t.Errorf("expected a body that is not json to be rejected, got %d", code)
+	}
+
+	for i, event := range receive(t, consumer.Events(), 3) {
+		if string(event.Data) != "order" || event.Attributes["id"] != strconv.Itoa(i+1) || event.Meta.GetOffset() != uint64(i+1) {
+			t.Errorf("unexpected event %d: %v", i, event)
+		}
+	}
+
+	select {
+	case event := <-consumer.Events():
+		t.Errorf("expected no events from the rejected batch, got %v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	rep2, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("could not send request: %s", err)
+	}
+	rep2.Body.Close()
+
+	if rep2.StatusCode != http.StatusMethodNotAllowed || rep2.Header.Get("Allow") != http.MethodPost {
+		t.Errorf("expected only posts to be allowed, got %d", rep2.StatusCode)
+	}
+}
+
+func TestGatewayAuth(t *testing.T) {
+	_, addr := serveGateway(t, func(conf *config.Config) {
+		conf.Auth.Enabled = true
+		conf.Auth.APIKeys = map[string]string{"s3cr3t": "producer"}
+		conf.Auth.ACL = []auth.Rule{{Principal: "producer", Permissions: []auth.Permission{auth.Publish}, Topics: []string{"orders"}}}
+	})
+
+	tests := []struct {
+		name  string
+		topic string
+		token string
+		code  int
+	}{
+		{"no credentials", "orders", "", http.StatusUnauthorized},
+		{"unknown key", "orders", "0th3r", http.StatusUnauthorized},
+		{"not allowed", "payments", "s3cr3t", http.StatusForbidden},
+		{"allowed", "orders", "s3cr3t", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		code, rep := post(t, "http://"+addr+"/v1/topics/"+tc.topic+"/events", tc.token, `{"data": "b3JkZXI="}`)
+		if code != tc.code {
+			t.Errorf("%s: expected status %d, got %d: %v", tc.name, tc.code, code, rep)
+		}
+	}
+
+	// The status endpoint does not require authentication
+	rep, err := http.Get("http://" + addr + "/v1/status")
+	if err != nil {
+		t.Fatalf("could not get status: %s", err)
+	}
+	rep.Body.Close()
+
+	if rep.StatusCode != http.StatusOK {
+		t.Errorf("expected status without credentials, got %d", rep.StatusCode)
+	}
+}
+
+func TestGatewayMaintenance(t *testing.T) {
+	srv, addr := serveGateway(t, nil)
+	url := "http://" + addr + "/v1/topics/orders/events"
+
+	srv.SetMaintenance(true)
+	code, rep := post(t, url, "", `{"data": "b3JkZXI="}`)
+	if code != http.StatusServiceUnavailable || !strings.Contains(rep["error"].(string), "maintenance") {
+		t.Errorf("expected publish to be refused in maintenance mode, got %d: %v", code, rep)
+	}
+
+	status, err := http.Get("http://" + addr + "/v1/status")
+	if err != nil {
+		t.Fatalf("could not get status: %s", err)
+	}
+	status.Body.Close()
+
+	if status.StatusCode != http.StatusOK {
+		t.Errorf("expected status in maintenance mode, got %d", status.StatusCode)
+	}
+
+	srv.SetMaintenance(false)
+	if code, rep = post(t, url, "", `{"data": "b3JkZXI="}`); code != http.StatusOK {
+		t.Errorf("expected publish after maintenance mode, got %d: %v", code, rep)
+	}
+}
+
+// Connections from clients that do not send the request headers in time are closed.
+func TestGatewayHeaderTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	_, addr := serveGateway(t, func(conf *config.Config) {
+		conf.Gateway.HeaderTimeout = timeout
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("could not connect to gateway: %s", err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if _, err = io.WriteString(conn, "POST /v1/topics/orders/events HTTP/1.1\r\nHost: "+addr+"\r\n"); err != nil {
+		t.Fatalf("could not write request: %s", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err = io.ReadAll(conn); err != nil {
+		var nerr net.Error
+		if errors.As(err, &nerr) && nerr.Timeout() {
+			t.Fatal("expected the gateway to close the connection")
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < timeout {
+		t.Errorf("expected the connection to be closed after %s, closed after %s", timeout, elapsed)
+	}
+}
@@ -0,0 +1,48 @@
+package switchback
+
+import (
+	"sync/atomic"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// serviceName is the name of the Switchback service in grpc.health.v1 requests; the
+// empty service name reports the health of the server as a whole.
+var serviceName = api.Switchback_ServiceDesc.ServiceName
+
+// setServing records whether the server is running, i.e. it has been served and has not
+// been shutdown, and updates the grpc.health.v1 serving status.
+func (s *Server) setServing(running bool) {
+	var val int32
+	if running {
+		val = 1
+	}
+	atomic.StoreInt32(&s.running, val)
+	s.updateServing()
+}
+
+// updateServing sets the grpc.health.v1 serving status from the server lifecycle and
+// maintenance mode; the server only reports serving when it is running and is not in
+// maintenance mode.
+func (s *Server) updateServing() {
+	if s.healthsrv == nil {
+		return
+	}
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if atomic.LoadInt32(&s.running) == 1 && !s.InMaintenance() {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+
+	s.healthsrv.SetServingStatus("", status)
+	s.healthsrv.SetServingStatus(serviceName, status)
+}
+
+func newHealthServer() *health.Server {
+	srv := health.NewServer()
+	srv.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	srv.SetServingStatus(serviceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	return srv
+}
@@ -0,0 +1,57 @@
+package switchback_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthServing(t *testing.T) {
+	conf, err := config.New()
+	if err != nil {
+		t.Fatalf("could not load config: %s", err)
+	}
+
+	conf.LogLevel = config.LevelDecoder(zerolog.ErrorLevel)
+	conf.ShutdownTimeout = time.Second
+	conf.BindAddr = freeAddr(t)
+	conf.Maintenance = true
+
+	srv, err := switchback.New(conf)
+	if err != nil {
+		t.Fatalf("could not create server: %s", err)
+	}
+
+	go srv.Serve()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	cc, err := grpc.Dial(conf.BindAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("could not dial server: %s", err)
+	}
+	defer cc.Close()
+	client := healthpb.NewHealthClient(cc)
+
+	check := func(expected healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		waitFor(t, func() bool {
+			rep, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			return err == nil && rep.Status == expected
+		})
+	}
+
+	check(healthpb.HealthCheckResponse_NOT_SERVING)
+	srv.SetMaintenance(false)
+	check(healthpb.HealthCheckResponse_SERVING)
+	srv.SetMaintenance(true)
+	check(healthpb.HealthCheckResponse_NOT_SERVING)
+	srv.SetMaintenance(false)
+	check(healthpb.HealthCheckResponse_SERVING)
+}
@@ -2,24 +2,37 @@ package switchback
 
 import (
 	"context"
+	"errors"
+	"net"
 	"time"
 
+	"github.com/bbengfort/switchback/pkg/auth"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
 const (
-	statusEndpoint = "/switchback.v1.Switchback/Status"
+	statusEndpoint      = "/switchback.v1.Switchback/Status"
+	healthCheckEndpoint = "/grpc.health.v1.Health/Check"
+	healthWatchEndpoint = "/grpc.health.v1.Health/Watch"
+	maintenanceEndpoint = "/switchback.v1.Switchback/Maintenance"
 )
 
+// public returns true for the health check methods that are allowed in maintenance
+// mode and that do not require authentication, e.g. for load balancers and probes.
+func public(method string) bool {
+	return method == statusEndpoint || method == healthCheckEndpoint || method == healthWatchEndpoint
+}
+
 func (s *Server) UnaryInterceptors() grpc.ServerOption {
-	return grpc.ChainUnaryInterceptor(s.UnaryAvailable())
+	return grpc.ChainUnaryInterceptor(s.UnaryAvailable(), s.UnaryAuthenticate())
 }
 
 func (s *Server) StreamInterceptors() grpc.ServerOption {
-	return grpc.ChainStreamInterceptor(s.StreamAvailable())
+	return grpc.ChainStreamInterceptor(s.StreamAvailable(), s.StreamAuthenticate())
 }
 
 // UnaryAvailable returns an interceptor that should be first in the chain - returning
@@ -29,14 +42,16 @@ func (s *Server) UnaryAvailable() grpc.UnaryServerInterceptor {
 		// Since this is the first interceptor, track the latency of the method
 		start := time.Now()
 
-		if s.conf.Maintenance && info.FullMethod != statusEndpoint {
-			// The only RPC we allow in maintenance mode is Status
+		if s.InMaintenance() && !public(info.FullMethod) && info.FullMethod != maintenanceEndpoint {
+			// The only RPCs we allow in maintenance mode are health checks and the
+			// request to exit maintenance mode
 			// Otherwise stop processing and return unavailable
 			return nil, status.Error(codes.Unavailable, "the switchback server is currently in maintenance mode")
 		}
 
 		// Call the handler to finalize the request and get the response
 		out, err = handler(ctx, in)
+		rpcLatency.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
 
 		// Log with zerolog - checkout grpclog.LoggerV2 for default logging
 		log.Debug().Err(err).Str("method", info.FullMethod).Dur("latency", time.Since(start)).Msg("grpc unary request")
@@ -51,16 +66,137 @@ func (s *Server) StreamAvailable() grpc.StreamServerInterceptor {
 		// Since this is the first interceptor, track the uptime of the stream
 		start := time.Now()
 
-		if s.conf.Maintenance {
-			// Do not allow a stream to be opened in maintenance mode
-			return status.Error(codes.Unavailable, "the switchback server is currently in maintenance mode")
+		// Track the stream so that it can be drained; streams cannot be opened in maintenance mode
+		if !public(info.FullMethod) {
+			closeStream, ok := s.openStream()
+			if !ok {
+				return status.Error(codes.Unavailable, "the switchback server is currently in maintenance mode")
+			}
+			defer closeStream()
 		}
 
 		// Call the handler to execute the stream RPC
+		activeStreams.WithLabelValues(info.FullMethod).Inc()
 		err := handler(srv, ss)
+		activeStreams.WithLabelValues(info.FullMethod).Dec()
+		rpcLatency.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
 
 		// Log with zerolog - checkout grcplog.LoggerV2 for default logging
 		log.Debug().Err(err).Str("method", info.FullMethod).Dur("duration", time.Since(start)).Msg("grpc stream request")
 		return err
 	}
 }
+
+// UnaryAuthenticate returns an interceptor that identifies the principal making the
+// request and adds it to the request context. Health checks do not require auth.
+func (s *Server) UnaryAuthenticate() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, in interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (out interface{}, err error) {
+		if s.authn == nil || public(info.FullMethod) {
+			return handler(ctx, in)
+		}
+
+		if ctx, err = s.authenticate(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, in)
+	}
+}
+
+// StreamAuthenticate returns an interceptor that identifies the principal opening the
+// stream and adds it to the stream context.
+func (s *Server) StreamAuthenticate() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		if s.authn == nil || public(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		var ctx context.Context
+		if ctx, err = s.authenticate(ss.Context()); err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (s *Server) authenticate(ctx context.Context) (_ context.Context, err error) {
+	var principal *auth.Principal
+	if principal, err = s.authn.Authenticate(ctx); err != nil {
+		if errors.Is(err, auth.ErrNoCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "missing or unknown credentials")
+		}
+		log.Debug().Err(err).Msg("could not authenticate request")
+		return nil, status.Error(codes.Unauthenticated, "could not authenticate request")
+	}
+	return auth.NewContext(ctx, principal), nil
+}
+
+// authorize returns a PermissionDenied error if the principal in the context is not
+// granted the permission on the topic and group by the ACL.
+func (s *Server) authorize(ctx context.Context, perm auth.Permission, topic, group string) error {
+	if !s.allowed(ctx, perm, topic, group) {
+		principal, _ := auth.FromContext(ctx)
+		name := ""
+		if principal != nil {
+			name = principal.Name
+		}
+		log.Warn().Str("principal", name).Str("permission", perm.String()).Str("topic", topic).Str("group", group).Msg("permission denied")
+		return status.Errorf(codes.PermissionDenied, "not allowed to %s on topic %q", perm, topic)
+	}
+	return nil
+}
+
+// allowed returns true if auth is disabled, there is no ACL, or the principal in the
+// context is granted the permission on the topic and group by the ACL.
+func (s *Server) allowed(ctx context.Context, perm auth.Permission, topic, group string) bool {
+	s.aclmu.RLock()
+	acl := s.acl
+	s.aclmu.RUnlock()
+
+	if s.authn == nil || acl == nil {
+		return true
+	}
+
+	principal, _ := auth.FromContext(ctx)
+	return acl.Allowed(principal, perm, topic, group)
+}
+
+// visible returns true if auth is disabled, there is no ACL, or the principal in the
+// context is granted any permission on the topic by the ACL.
+func (s *Server) visible(ctx context.Context, topic string) bool {
+	s.aclmu.RLock()
+	acl := s.acl
+	s.aclmu.RUnlock()
+
+	if s.authn == nil || acl == nil {
+		return true
+	}
+
+	principal, _ := auth.FromContext(ctx)
+	return acl.Visible(principal, topic)
+}
+
+// principalName returns the name of the authenticated principal in the context or the
+// address of the peer if the request is not authenticated.
+func principalName(ctx context.Context) string {
+	if principal, ok := auth.FromContext(ctx); ok && principal != nil {
+		return principal.Name
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// contextStream wraps a server stream to replace its context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,185 @@
+package switchback
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/config"
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrRateLimited = errors.New("rate limit exceeded")
+	ErrExceedsRate = errors.New("event is larger than the bytes allowed per second by the rate limit")
+)
+
+// idleBuckets is how long the buckets of a principal or topic are kept after they were
+// last used; idle buckets have refilled so they are removed rather than kept forever.
+const idleBuckets = 5 * time.Minute
+
+// RateLimiter enforces the global, per-principal, and per-topic publish limits. Each
+// scope has a token bucket for events and one for bytes; the burst of each bucket is
+// one second worth of its rate.
+type RateLimiter struct {
+	sync.Mutex
+	conf       config.LimitsConfig
+	global     *buckets
+	principals map[string]*buckets
+	topics     map[string]*buckets
+	swept      time.Time
+}
+
+type buckets struct {
+	events *rate.Limiter
+	bytes  *rate.Limiter
+	used   time.Time
+}
+
+func NewRateLimiter(conf config.LimitsConfig) *RateLimiter {
+	return &RateLimiter{
+		conf:       conf,
+		global:     newBuckets(conf.GlobalEvents, conf.GlobalBytes),
+		principals: make(map[string]*buckets),
+		topics:     make(map[string]*buckets),
+		swept:      time.Now(),
+	}
+}
+
+// Publish reserves tokens for an event of the specified size from the global,
+// principal, and topic buckets. If backpressure is enabled it waits until the event is
+// allowed (or the context is done), otherwise it returns ErrRateLimited immediately if
+// any of the buckets do not have enough tokens. Tokens are only consumed if the event
+// is allowed by all of the buckets. An event larger than the burst of a bytes bucket can
+// never be allowed, so ErrExceedsRate is returned for it without waiting.
+func (l *RateLimiter) Publish(ctx context.Context, principal, topic string, size int) (err error) {
+	now := time.Now()
+	l.Lock()
+	l.sweep(now)
+	scopes := []*buckets{l.global, l.lookup(l.principals, principal, l.conf.PrincipalEvents, l.conf.PrincipalBytes, now), l.lookup(l.topics, topic, l.conf.TopicEvents, l.conf.TopicBytes, now)}
+	backpressure := l.conf.Backpressure
+	l.Unlock()
+
+	for _, scope := range scopes {
+		if scope.bytes != nil && size > scope.bytes.Burst() {
+			return ErrExceedsRate
+		}
+	}
+
+	reservations := make([]*rate.Reservation, 0, 6)
+	cancel := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+
+	var delay time.Duration
+	for _, scope := range scopes {
+		for _, limit := range []struct {
+			limiter *rate.Limiter
+			n       int
+		}{{scope.events, 1}, {scope.bytes, size}} {
+			if limit.limiter == nil {
+				continue
+			}
+
+			r := limit.limiter.ReserveN(now, limit.n)
+			if !r.OK() {
+				// The limits were updated since the size was checked against the burst
+				cancel()
+				return ErrExceedsRate
+			}
+
+			reservations = append(reservations, r)
+			if d := r.DelayFrom(now); d > delay {
+				delay = d
+			}
+		}
+	}
+
+	if delay == 0 {
+		return nil
+	}
+
+	if !backpressure {
+		cancel()
+		return ErrRateLimited
+	}
+
+	// The buckets are in use until the reservation elapses and must not be swept before
+	l.Lock()
+	for _, scope := range scopes[1:] {
+		if until := now.Add(delay); until.After(scope.used) {
+			scope.used = until
+		}
+	}
+	l.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	}
+}
+
+// Update replaces the configured limits; buckets are recreated on their next use.
+func (l *RateLimiter) Update(conf config.LimitsConfig) {
+	l.Lock()
+	defer l.Unlock()
+	l.conf = conf
+	l.global = newBuckets(conf.GlobalEvents, conf.GlobalBytes)
+	l.principals = make(map[string]*buckets)
+	l.topics = make(map[string]*buckets)
+}
+
+// Must hold the lock to call this method.
+func (l *RateLimiter) lookup(scope map[string]*buckets, key string, events, bytes float64, now time.Time) *buckets {
+	b, ok := scope[key]
+	if !ok {
+		b = newBuckets(events, bytes)
+		scope[key] = b
+	}
+
+	if now.After(b.used) {
+		b.used = now
+	}
+	return b
+}
+
+// sweep removes the principal and topic buckets that have not been used recently so
+// that the limiter does not grow with every principal and topic it has seen. A bucket
+// refills in at most a second once its reservations have elapsed, so an idle bucket is
+// full and is the same as a new one. Must hold the lock to call this method.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < idleBuckets {
+		return
+	}
+
+	for _, scope := range []map[string]*buckets{l.principals, l.topics} {
+		for key, b := range scope {
+			if now.Sub(b.used) > idleBuckets {
+				delete(scope, key)
+			}
+		}
+	}
+	l.swept = now
+}
+
+func newBuckets(events, bytes float64) *buckets {
+	return &buckets{events: newLimiter(events), bytes: newLimiter(bytes)}
+}
+
+// newLimiter returns a token bucket with the specified rate and a burst of one second
+// worth of tokens or nil if the rate is unlimited.
+func newLimiter(limit float64) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(limit), int(math.Max(1, math.Ceil(limit))))
+}
@@ -0,0 +1,150 @@
+package switchback
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultDrainDeadline = 30 * time.Second
+
+// drainer signals open streams to finish. When start is closed, publish streams are sent
+// a final ClosePublish and subscribe streams flush the events buffered for them; when
+// halt is closed any streams that are still flushing are closed immediately. Streams
+// capture the drainer that is current when they are opened.
+type drainer struct {
+	start chan struct{}
+	halt  chan struct{}
+}
+
+func newDrainer() *drainer {
+	return &drainer{start: make(chan struct{}), halt: make(chan struct{})}
+}
+
+// InMaintenance returns true if the server is refusing new requests.
+func (s *Server) InMaintenance() bool {
+	return atomic.LoadInt32(&s.maint) == 1
+}
+
+// SetMaintenance enters or exits maintenance mode and updates the health status.
+func (s *Server) SetMaintenance(enabled bool) {
+	var val int32
+	if enabled {
+		val = 1
+	}
+
+	if atomic.SwapInt32(&s.maint, val) != val {
+		if enabled {
+			log.Warn().Msg("entering maintenance mode")
+		} else {
+			log.Info().Msg("exiting maintenance mode")
+		}
+	}
+	s.updateServing()
+}
+
+// Drain signals all open streams to finish and waits for them to do so. If streams are
+// still open when the deadline expires they are halted and Drain waits for them to exit
+// or for the context to be done. The server should be refusing new streams (e.g. in
+// maintenance mode) before Drain is called.
+func (s *Server) Drain(ctx context.Context, deadline time.Duration) error {
+	s.drainmu.Lock()
+	drain := s.drain
+	s.drain = newDrainer()
+	s.drainmu.Unlock()
+
+	log.Info().Dur("deadline", deadline).Msg("draining open streams")
+	close(drain.start)
+
+	done := make(chan struct{})
+	go func() {
+		s.drainmu.Lock()
+		for s.open > 0 {
+			s.closed.Wait()
+		}
+		s.drainmu.Unlock()
+		close(done)
+	}()
+
+	timer := time.NewTimer(deadline)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		log.Info().Msg("all streams drained")
+		return nil
+	case <-timer.C:
+		log.Warn().Msg("drain deadline exceeded, closing remaining streams")
+		close(drain.halt)
+	case <-ctx.Done():
+		close(drain.halt)
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drainer returns the drainer that newly opened streams should observe.
+func (s *Server) drainer() *drainer {
+	s.drainmu.Lock()
+	defer s.drainmu.Unlock()
+	return s.drain
+}
+
+// openStream registers a new stream so that Drain waits for it; the returned function
+// must be called when the stream exits. The maintenance check and the registration are
+// made under the drain lock so that a stream is either refused or waited for by a drain
+// that starts concurrently. False is returned if the server is in maintenance mode.
+func (s *Server) openStream() (closeStream func(), ok bool) {
+	s.drainmu.Lock()
+	defer s.drainmu.Unlock()
+	if s.InMaintenance() {
+		return nil, false
+	}
+
+	s.open++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.drainmu.Lock()
+			s.open--
+			s.closed.Broadcast()
+			s.drainmu.Unlock()
+		})
+	}, true
+}
+
+// Maintenance is an admin RPC that flips maintenance mode at runtime. If drain is
+// requested the RPC returns after open streams have been drained.
+func (s *Server) Maintenance(ctx context.Context, in *api.MaintenanceRequest) (out *api.ServiceState, err error) {
+	if err = s.authorize(ctx, auth.Admin, "", ""); err != nil {
+		return nil, err
+	}
+
+	deadline := defaultDrainDeadline
+	if in.Deadline != "" {
+		if deadline, err = time.ParseDuration(in.Deadline); err != nil || deadline <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid drain deadline %q", in.Deadline)
+		}
+	}
+
+	s.SetMaintenance(in.Enabled)
+	if in.Enabled && in.Drain {
+		if err = s.Drain(ctx, deadline); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+	}
+	return s.Status(ctx, &api.HealthCheck{})
+}
@@ -0,0 +1,126 @@
+package switchback
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+const namespace = "switchback"
+
+// anonymousGroup is the group label of the metrics of groups that were not named by the
+// subscriber and were assigned a random id.
+const anonymousGroup = "anonymous"
+
+var (
+	eventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_published_total",
+		Help:      "The number of events published to each topic.",
+	}, []string{"topic"})
+
+	bytesPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bytes_published_total",
+		Help:      "The number of event data bytes published to each topic.",
+	}, []string{"topic"})
+
+	eventsDelivered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_delivered_total",
+		Help:      "The number of events sent to subscribers of each topic and group (anonymous for unnamed groups).",
+	}, []string{"topic", "group"})
+
+	eventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_dropped_total",
+		Help:      "The number of events that could not be delivered to a group.",
+	}, []string{"topic", "reason"})
+
+	eventsMirrored = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_mirrored_total",
+		Help:      "The number of events copied to each topic from each mirror remote.",
+	}, []string{"remote", "topic"})
+
+	activeStreams = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_streams",
+		Help:      "The number of open publish and subscribe streams.",
+	}, []string{"method"})
+
+	rpcLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_duration_seconds",
+		Help:      "The latency of unary requests and the duration of streams.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 12),
+	}, []string{"method", "code"})
+)
+
+// pubsubCollector reports the state of the consumer groups when metrics are scraped.
+type pubsubCollector struct {
+	pubsub    *PubSub
+	consumers *prometheus.Desc
+	buffered  *prometheus.Desc
+	capacity  *prometheus.Desc
+	lag       *prometheus.Desc
+}
+
+func newPubSubCollector(pubsub *PubSub) *pubsubCollector {
+	labels := []string{"topic", "group"}
+	return &pubsubCollector{
+		pubsub:    pubsub,
+		consumers: prometheus.NewDesc(prometheus.BuildFQName(namespace, "group", "consumers"), "The number of consumers connected to each group.", labels, nil),
+		buffered:  prometheus.NewDesc(prometheus.BuildFQName(namespace, "group", "buffered_events"), "The number of events queued for the consumers of each group.", labels, nil),
+		capacity:  prometheus.NewDesc(prometheus.BuildFQName(namespace, "group", "buffer_capacity"), "The total buffer capacity of the consumers of each group.", labels, nil),
+		lag:       prometheus.NewDesc(prometheus.BuildFQName(namespace, "group", "lag"), "The number of events published to the topic not yet queued for the group.", labels, nil),
+	}
+}
+
+func (c *pubsubCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.consumers
+	ch <- c.buffered
+	ch <- c.capacity
+	ch <- c.lag
+}
+
+func (c *pubsubCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range c.pubsub.Stats() {
+		ch <- prometheus.MustNewConstMetric(c.consumers, prometheus.GaugeValue, float64(stats.Consumers), stats.Topic, stats.Group)
+		ch <- prometheus.MustNewConstMetric(c.buffered, prometheus.GaugeValue, float64(stats.Buffered), stats.Topic, stats.Group)
+		ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(stats.Capacity), stats.Topic, stats.Group)
+		ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(stats.Lag), stats.Topic, stats.Group)
+	}
+}
+
+// setupMetrics creates a registry for the server's metrics and the http server that
+// exposes them for scraping.
+func (s *Server) setupMetrics() (err error) {
+	registry := prometheus.NewRegistry()
+	for _, collector := range []prometheus.Collector{
+		eventsPublished, bytesPublished, eventsDelivered, eventsDropped, eventsMirrored, activeStreams, rpcLatency,
+		newPubSubCollector(s.pubsub),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	} {
+		if err = registry.Register(collector); err != nil {
+			return err
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	s.metrics = &http.Server{Addr: s.conf.Metrics.BindAddr, Handler: mux}
+	return nil
+}
+
+// serveMetrics runs the metrics http server until it is shutdown.
+func (s *Server) serveMetrics() {
+	log.Info().Str("listen", s.conf.Metrics.BindAddr).Msg("metrics server started")
+	if err := s.metrics.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		s.echan <- err
+	}
+}
@@ -0,0 +1,444 @@
+package switchback
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/bbengfort/switchback/pkg/compress"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Attributes added to events copied from a remote server. The source, offset, and epoch
+// are those assigned by the server the event was originally published to; the remote is
+// the server the event was copied from and the path lists every server that has received
+// the event, which prevents events from being mirrored in a loop.
+const (
+	MirrorSource = "switchback.mirror.source"
+	MirrorOffset = "switchback.mirror.offset"
+	MirrorEpoch  = "switchback.mirror.epoch"
+	MirrorRemote = "switchback.mirror.remote"
+	MirrorPath   = "switchback.mirror.path"
+)
+
+const checkpointInterval = 5 * time.Second
+
+// mirrors copies topics from remote servers to this server.
+type mirrors struct {
+	remotes     []*mirror
+	checkpoints *checkpoints
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// mirror subscribes to the topics on a remote server that match its patterns and
+// republishes their events to this server.
+type mirror struct {
+	sync.Mutex
+	srv     *Server
+	conf    config.RemoteConfig
+	cc      *grpc.ClientConn
+	client  api.SwitchbackClient
+	running map[string]struct{}
+	copied  uint64
+	err     error
+}
+
+// setupMirrors connects to the remote servers and loads the mirror checkpoints.
+func (s *Server) setupMirrors() (err error) {
+	s.mirrors = &mirrors{remotes: make([]*mirror, 0, len(s.conf.Mirror.Remotes))}
+	if s.mirrors.checkpoints, err = loadCheckpoints(s.conf.Mirror.Checkpoint); err != nil {
+		return err
+	}
+
+	for _, conf := range s.conf.Mirror.Remotes {
+		if conf.Group == "" {
+			conf.Group = "mirror." + s.conf.Name
+		}
+
+		m := &mirror{srv: s, conf: conf, running: make(map[string]struct{})}
+		if m.cc, err = dialRemote(conf); err != nil {
+			return fmt.Errorf("could not connect to mirror remote %q: %w", conf.Name, err)
+		}
+		m.client = api.NewSwitchbackClient(m.cc)
+		s.mirrors.remotes = append(s.mirrors.remotes, m)
+	}
+
+	s.health["mirror"] = s.mirrorHealth
+	return nil
+}
+
+func dialRemote(conf config.RemoteConfig) (_ *grpc.ClientConn, err error) {
+	opts := make([]grpc.DialOption, 0, 3)
+	if conf.Token != "" {
+		if conf.InsecureToken {
+			opts = append(opts, grpc.WithPerRPCCredentials(auth.InsecureToken(conf.Token)))
+		} else {
+			opts = append(opts, grpc.WithPerRPCCredentials(auth.Token(conf.Token)))
+		}
+	}
+
+	if conf.Compression != compress.None {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(conf.Compression)))
+	}
+
+	if conf.CA == "" && conf.CertFile == "" {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		return grpc.Dial(conf.Endpoint, opts...)
+	}
+
+	tlsconf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if conf.CA != "" {
+		if tlsconf.RootCAs, err = LoadCertPool(conf.CA); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.CertFile != "" {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile); err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		tlsconf.Certificates = []tls.Certificate{cert}
+	}
+
+	opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsconf)))
+	return grpc.Dial(conf.Endpoint, opts...)
+}
+
+// startMirrors runs the mirrors until stopMirrors is called.
+func (s *Server) startMirrors() {
+	var ctx context.Context
+	ctx, s.mirrors.cancel = context.WithCancel(context.Background())
+
+	for _, m := range s.mirrors.remotes {
+		s.mirrors.wg.Add(1)
+		go m.run(ctx)
+	}
+
+	s.mirrors.wg.Add(1)
+	go func() {
+		defer s.mirrors.wg.Done()
+		ticker := time.NewTicker(checkpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.mirrors.checkpoints.Save(); err != nil {
+					log.Warn().Err(err).Msg("could not save mirror checkpoints")
+				}
+			}
+		}
+	}()
+}
+
+// stopMirrors stops copying events, saves the checkpoints, and closes the connections
+// to the remote servers.
+func (s *Server) stopMirrors() (err error) {
+	if s.mirrors.cancel != nil {
+		s.mirrors.cancel()
+		s.mirrors.wg.Wait()
+	}
+
+	for _, m := range s.mirrors.remotes {
+		m.cc.Close()
+	}
+	return s.mirrors.checkpoints.Save()
+}
+
+// run discovers the topics on the remote that match the mirror's patterns, starting a
+// copy of each new topic, until the context is canceled. Copies that fail are restarted
+// the next time topics are discovered. In a cluster only the leader copies events.
+func (m *mirror) run(ctx context.Context) {
+	defer m.srv.mirrors.wg.Done()
+	ticker := time.NewTicker(m.srv.conf.Mirror.Refresh)
+	defer ticker.Stop()
+
+	for {
+		if m.srv.cluster == nil || m.srv.cluster.IsLeader() {
+			m.discover(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *mirror) discover(ctx context.Context) {
+	rep, err := m.client.ListTopics(ctx, &api.TopicQuery{})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Warn().Err(err).Str("remote", m.conf.Name).Msg("could not list mirror remote topics")
+			m.fail(err)
+		}
+		return
+	}
+
+	m.fail(nil)
+	for _, topic := range rep.Topics {
+		if !m.matches(topic.Name) {
+			continue
+		}
+
+		m.Lock()
+		if _, ok := m.running[topic.Name]; ok {
+			m.Unlock()
+			continue
+		}
+		m.running[topic.Name] = struct{}{}
+		m.Unlock()
+
+		m.srv.mirrors.wg.Add(1)
+		go m.copy(ctx, topic.Name)
+	}
+}
+
+func (m *mirror) matches(topic string) bool {
+	for _, pattern := range m.conf.Topics {
+		if ok, _ := path.Match(pattern, topic); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// copy subscribes to the topic on the remote and republishes its events locally until
+// the context is canceled or an error occurs.
+func (m *mirror) copy(ctx context.Context, topic string) {
+	defer m.srv.mirrors.wg.Done()
+	defer func() {
+		m.Lock()
+		delete(m.running, topic)
+		m.Unlock()
+	}()
+
+	stream, err := m.client.Subscribe(ctx, &api.Subscription{Topic: topic, Group: m.conf.Group})
+	if err != nil {
+		log.Warn().Err(err).Str("remote", m.conf.Name).Str("topic", topic).Msg("could not subscribe to mirror remote")
+		m.fail(err)
+		return
+	}
+
+	log.Info().Str("remote", m.conf.Name).Str("topic", topic).Str("group", m.conf.Group).Msg("mirroring topic")
+	for {
+		var event *api.Event
+		if event, err = stream.Recv(); err != nil {
+			if ctx.Err() == nil {
+				log.Warn().Err(err).Str("remote", m.conf.Name).Str("topic", topic).Msg("mirror subscription closed")
+				m.fail(err)
+			}
+			return
+		}
+
+		if err = m.republish(ctx, event); err != nil {
+			log.Warn().Err(err).Str("remote", m.conf.Name).Str("topic", topic).Msg("could not republish mirrored event")
+			m.fail(err)
+			return
+		}
+	}
+}
+
+// republish an event from the remote to this server unless this server has already
+// received it or it was already copied before the checkpoint. Only events from a remote
+// cluster (the epoch is non-zero) are checkpointed since the offsets of a standalone
+// server restart from zero when the server restarts. The copied event is validated like
+// a published event since the mirror attributes count toward the limits; invalid events
+// are dropped.
+func (m *mirror) republish(ctx context.Context, event *api.Event) (err error) {
+	name := m.srv.conf.Name
+	origin := event.Attributes[MirrorSource]
+	if origin == "" {
+		if origin = event.Meta.GetSource(); origin == "" {
+			origin = m.conf.Name
+		}
+	}
+
+	hops := []string{origin}
+	if via := event.Attributes[MirrorPath]; via != "" {
+		hops = strings.Split(via, ",")
+	}
+
+	for _, hop := range hops {
+		if hop == name {
+			eventsDropped.WithLabelValues(event.Topic, "mirror_loop").Inc()
+			return nil
+		}
+	}
+
+	key := m.conf.Name + "/" + event.Topic
+	offset, epoch := event.Meta.GetOffset(), event.Meta.GetEpoch()
+	clustered := epoch > 0
+	if clustered && offset <= m.srv.mirrors.checkpoints.Get(key) {
+		return nil
+	}
+
+	copied := &api.Event{
+		Topic:      event.Topic,
+		Data:       event.Data,
+		Attributes: make(map[string]string, len(event.Attributes)+5),
+		Meta:       &api.Metadata{Source: origin},
+	}
+	for key, val := range event.Attributes {
+		copied.Attributes[key] = val
+	}
+
+	if _, ok := copied.Attributes[MirrorOffset]; !ok {
+		copied.Attributes[MirrorOffset] = strconv.FormatUint(offset, 10)
+		copied.Attributes[MirrorEpoch] = strconv.FormatUint(epoch, 10)
+	}
+	copied.Attributes[MirrorSource] = origin
+	copied.Attributes[MirrorRemote] = m.conf.Name
+	copied.Attributes[MirrorPath] = strings.Join(append(hops, name), ",")
+
+	if err = m.srv.valid.Event(copied); err != nil {
+		m.drop(copied, key, offset, clustered)
+		log.Error().Err(err).Str("remote", m.conf.Name).Str("topic", copied.Topic).Msg("could not mirror invalid event")
+		return nil
+	}
+
+	if err = m.srv.schemas.Validate(copied.Topic, copied.Data); err != nil {
+		m.drop(copied, key, offset, clustered)
+		log.Error().Err(err).Str("remote", m.conf.Name).Str("topic", copied.Topic).Msg("could not mirror event that does not match the topic schema")
+		return nil
+	}
+
+	if err = m.srv.route(ExtractTrace(ctx, copied), copied); err != nil {
+		return err
+	}
+
+	if clustered {
+		m.srv.mirrors.checkpoints.Set(key, offset)
+	}
+	eventsMirrored.WithLabelValues(m.conf.Name, event.Topic).Inc()
+	atomic.AddUint64(&m.copied, 1)
+	return nil
+}
+
+// drop an invalid event, checkpointing it so that it is not copied again.
+func (m *mirror) drop(event *api.Event, key string, offset uint64, clustered bool) {
+	eventsDropped.WithLabelValues(event.Topic, "invalid_mirror").Inc()
+	if clustered {
+		m.srv.mirrors.checkpoints.Set(key, offset)
+	}
+}
+
+func (m *mirror) fail(err error) {
+	m.Lock()
+	m.err = err
+	m.Unlock()
+}
+
+func (s *Server) mirrorHealth() *api.ComponentHealth {
+	var topics int
+	var copied uint64
+	failed := make([]string, 0)
+	for _, m := range s.mirrors.remotes {
+		m.Lock()
+		topics += len(m.running)
+		if m.err != nil {
+			failed = append(failed, m.conf.Name)
+		}
+		m.Unlock()
+		copied += atomic.LoadUint64(&m.copied)
+	}
+
+	health := &api.ComponentHealth{
+		Name:    "mirror",
+		Status:  HealthOK,
+		Message: fmt.Sprintf("%d remotes, %d topics, %d events copied", len(s.mirrors.remotes), topics, copied),
+	}
+
+	if len(failed) > 0 {
+		health.Status = HealthDegraded
+		health.Message = fmt.Sprintf("%s; errors from %s", health.Message, strings.Join(failed, ", "))
+	}
+	return health
+}
+
+// checkpoints records the offset of the last event copied from each remote topic and
+// periodically saves them to disk if a path is specified.
+type checkpoints struct {
+	sync.Mutex
+	path    string
+	offsets map[string]uint64
+	dirty   bool
+}
+
+func loadCheckpoints(path string) (c *checkpoints, err error) {
+	c = &checkpoints{path: path, offsets: make(map[string]uint64)}
+	if path == "" {
+		return c, nil
+	}
+
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("could not read mirror checkpoints: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &c.offsets); err != nil {
+		return nil, fmt.Errorf("could not parse mirror checkpoints: %w", err)
+	}
+	return c, nil
+}
+
+func (c *checkpoints) Get(key string) uint64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.offsets[key]
+}
+
+func (c *checkpoints) Set(key string, offset uint64) {
+	c.Lock()
+	defer c.Unlock()
+	c.offsets[key] = offset
+	c.dirty = true
+}
+
+// Save writes the checkpoints to a temporary file and renames it so that the file is
+// not corrupted if the server crashes while writing it.
+func (c *checkpoints) Save() (err error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+
+	var data []byte
+	if data, err = json.Marshal(c.offsets); err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
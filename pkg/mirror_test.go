@@ -0,0 +1,82 @@
+package switchback_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+// Events copied from a remote are validated with the mirror attributes they are given,
+// and the events that exceed the limits are dropped rather than republished.
+func TestMirrorValidation(t *testing.T) {
+	conf, err := config.New()
+	if err != nil {
+		t.Fatalf("could not load config: %s", err)
+	}
+
+	conf.LogLevel = config.LevelDecoder(zerolog.ErrorLevel)
+	conf.ShutdownTimeout = time.Second
+	conf.BindAddr = freeAddr(t)
+
+	remote, err := switchback.New(conf)
+	if err != nil {
+		t.Fatalf("could not create remote server: %s", err)
+	}
+
+	go remote.Serve()
+	t.Cleanup(func() { remote.Shutdown() })
+
+	// The mirror adds five attributes to each copied event
+	srv := serveEmbedded(t, func(local *config.Config) {
+		local.Name = "local"
+		local.Events.MaxAttributes = 8
+		local.Mirror.Enabled = true
+		local.Mirror.Refresh = 10 * time.Millisecond
+		local.Mirror.Remotes = []config.RemoteConfig{{Name: "remote", Endpoint: conf.BindAddr, Topics: []string{"mirrored"}}}
+	})
+
+	consumer, err := srv.PubSub().Connect(&api.Subscription{Topic: "mirrored"})
+	if err != nil {
+		t.Fatalf("could not connect consumer: %s", err)
+	}
+
+	publish := func(attrs ...string) {
+		t.Helper()
+		event := &api.Event{Topic: "mirrored", Data: []byte("event"), Attributes: make(map[string]string)}
+		for _, attr := range attrs {
+			event.Attributes[attr] = "x"
+		}
+
+		if err := remote.PubSub().Publish(context.Background(), event); err != nil {
+			t.Fatalf("could not publish to remote: %s", err)
+		}
+	}
+
+	// The topic must exist on the remote for the mirror to discover and subscribe to it
+	publish("first")
+	waitForGroups(t, remote.PubSub(), "mirrored", 1)
+
+	publish("a", "b", "c")
+	publish("a", "b", "c", "d")
+	publish("last")
+
+	events := receive(t, consumer.Events(), 2)
+	for _, event := range events {
+		if len(event.Attributes) > 8 || event.Attributes[switchback.MirrorRemote] != "remote" {
+			t.Errorf("unexpected mirrored event attributes %v", event.Attributes)
+		}
+	}
+
+	if _, ok := events[0].Attributes["a"]; !ok {
+		t.Errorf("expected the event within the limits to be mirrored, got %v", events[0].Attributes)
+	}
+
+	if _, ok := events[1].Attributes["last"]; !ok {
+		t.Errorf("expected the event over the limits to be dropped, got %v", events[1].Attributes)
+	}
+}
@@ -0,0 +1,864 @@
+package switchback
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// mqttConnectTimeout is how long a client has to send CONNECT after connecting.
+	mqttConnectTimeout = 10 * time.Second
+
+	// mqttWriteTimeout is how long a write to a client may block before the client is
+	// disconnected so that a client that cannot keep up does not block its groups.
+	mqttWriteTimeout = 10 * time.Second
+
+	// mqttSubackFailure is the SUBACK return code for a rejected topic filter.
+	mqttSubackFailure = 0x80
+
+	// mqttPublishQueue is the number of publishes read from a client that may wait to be
+	// published before the client is disconnected for publishing faster than its events
+	// can be routed.
+	mqttPublishQueue = 1024
+)
+
+var (
+	errMQTTPacketSize = errors.New("mqtt packet exceeds the maximum message size")
+	errMQTTQueueFull  = errors.New("mqtt client is publishing faster than its events can be routed")
+)
+
+// mqttBroker accepts MQTT 3.1.1 connections and maps them onto the switchback topics.
+// MQTT topic levels are separated by slashes and switchback topic levels by dots, so
+// the MQTT topic sensors/room1 is the switchback topic sensors.room1; MQTT names that
+// contain a dot are rejected since they cannot be mapped back.
+//
+// Devices publish with QoS 0 or 1 (QoS 2 is accepted and published once) and each
+// subscription is a consumer in a group: clean sessions are given a new group, other
+// sessions join the group named after the client id, and shared subscriptions
+// ($share/{group}/{filter}) join the named group. Subscriptions are granted at most QoS
+// 1 and events are delivered with the QoS granted to the subscription; QoS 1 events are
+// acknowledged to the group when the client acknowledges them. A group is removed when
+// its last client disconnects, so a session only resumes from the group's acknowledged
+// offset when the server is clustered and the group is resumed from the replicated log;
+// otherwise events published while the client is away, or sent but not acknowledged
+// before it disconnected, are not delivered. Retained messages are not supported and
+// the retain flag is ignored.
+type mqttBroker struct {
+	sync.Mutex
+	sock     net.Listener
+	sessions map[string]*mqttSession
+	wg       sync.WaitGroup
+}
+
+// setupMQTT creates the broker; the listener is opened when the server is served.
+func (s *Server) setupMQTT() {
+	s.mqtt = &mqttBroker{sessions: make(map[string]*mqttSession)}
+	s.health["mqtt"] = s.mqttHealth
+}
+
+// serveMQTT accepts MQTT connections until the broker is stopped.
+func (s *Server) serveMQTT() {
+	sock, err := net.Listen("tcp", s.conf.MQTT.BindAddr)
+	if err != nil {
+		s.echan <- fmt.Errorf("could not listen on %q", s.conf.MQTT.BindAddr)
+		return
+	}
+
+	if s.certs != nil {
+		sock = tls.NewListener(sock, &tls.Config{MinVersion: tls.VersionTLS12, GetConfigForClient: s.certs.GetConfigForClient})
+	}
+
+	s.mqtt.Lock()
+	s.mqtt.sock = sock
+	s.mqtt.Unlock()
+	log.Info().Str("listen", s.conf.MQTT.BindAddr).Bool("tls", s.certs != nil).Msg("mqtt server started")
+
+	for {
+		var conn net.Conn
+		if conn, err = sock.Accept(); err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.echan <- err
+			}
+			return
+		}
+
+		s.mqtt.wg.Add(1)
+		go s.handleMQTT(conn)
+	}
+}
+
+// stopMQTT closes the listener and disconnects all clients.
+func (s *Server) stopMQTT() {
+	s.mqtt.Lock()
+	if s.mqtt.sock != nil {
+		s.mqtt.sock.Close()
+	}
+
+	for _, session := range s.mqtt.sessions {
+		session.conn.Close()
+	}
+	s.mqtt.Unlock()
+	s.mqtt.wg.Wait()
+}
+
+// handleMQTT reads the client's CONNECT, authenticates it, and runs its session.
+func (s *Server) handleMQTT(conn net.Conn) {
+	defer s.mqtt.wg.Done()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(mqttConnectTimeout))
+	packet, err := readPacket(r, s.valid.MaxMessageSize())
+	if err != nil {
+		log.Debug().Err(err).Str("remote", conn.RemoteAddr().String()).Msg("could not read mqtt connect")
+		return
+	}
+
+	connect, ok := packet.(*packets.ConnectPacket)
+	if !ok {
+		log.Debug().Str("remote", conn.RemoteAddr().String()).Msg("mqtt client did not send connect")
+		return
+	}
+
+	if code := connect.Validate(); code != packets.Accepted {
+		connack(conn, code)
+		return
+	}
+
+	if s.InMaintenance() {
+		connack(conn, packets.ErrRefusedServerUnavailable)
+		return
+	}
+
+	var ctx context.Context
+	if ctx, err = s.mqttContext(conn, connect); err != nil {
+		log.Debug().Err(err).Str("client", connect.ClientIdentifier).Msg("could not authenticate mqtt client")
+		connack(conn, packets.ErrRefusedNotAuthorised)
+		return
+	}
+
+	var session *mqttSession
+	if session, err = s.newSession(ctx, conn, r, connect); err != nil {
+		log.Debug().Err(err).Str("client", connect.ClientIdentifier).Msg("could not accept mqtt client")
+		connack(conn, packets.ErrRefusedIDRejected)
+		return
+	}
+
+	if err = session.write(&packets.ConnackPacket{FixedHeader: packets.FixedHeader{MessageType: packets.Connack}, ReturnCode: packets.Accepted}); err != nil {
+		session.cancel()
+		return
+	}
+	session.run()
+}
+
+// mqttContext returns a context that carries the client's credentials the way a gRPC
+// request would: the password as a bearer token and the client certificate as the
+// peer's TLS info. If the server authenticates clients, the principal is added to it.
+func (s *Server) mqttContext(conn net.Conn, connect *packets.ConnectPacket) (ctx context.Context, err error) {
+	md := metadata.MD{}
+	if connect.PasswordFlag {
+		md.Set("authorization", "Bearer "+string(connect.Password))
+	}
+	ctx = metadata.NewIncomingContext(context.Background(), md)
+
+	p := &peer.Peer{Addr: conn.RemoteAddr()}
+	if tc, ok := conn.(*tls.Conn); ok {
+		p.AuthInfo = credentials.TLSInfo{State: tc.ConnectionState()}
+	}
+	ctx = peer.NewContext(ctx, p)
+
+	if s.authn == nil {
+		return ctx, nil
+	}
+	return s.authenticate(ctx)
+}
+
+// mqttSession is the state of a connected MQTT client.
+type mqttSession struct {
+	srv     *Server
+	conn    net.Conn
+	r       *bufio.Reader
+	ctx     context.Context
+	cancel  context.CancelFunc
+	id      string
+	group   string
+	alive   time.Duration
+	will    *api.Event
+	inbound chan packets.ControlPacket
+	wmu     sync.Mutex
+	idmu    sync.Mutex
+	next    uint16
+	pending map[uint16]chan struct{}
+	submu   sync.Mutex
+	filters map[string]*mqttFilter
+	topics  map[string]context.CancelFunc
+	pubrec  map[uint16]struct{}
+	wg      sync.WaitGroup
+}
+
+func (s *Server) newSession(ctx context.Context, conn net.Conn, r *bufio.Reader, connect *packets.ConnectPacket) (m *mqttSession, err error) {
+	m = &mqttSession{
+		srv:     s,
+		conn:    conn,
+		r:       r,
+		id:      connect.ClientIdentifier,
+		alive:   time.Duration(connect.Keepalive) * time.Second * 3 / 2,
+		inbound: make(chan packets.ControlPacket, mqttPublishQueue),
+		pending: make(map[uint16]chan struct{}),
+		filters: make(map[string]*mqttFilter),
+		topics:  make(map[string]context.CancelFunc),
+		pubrec:  make(map[uint16]struct{}),
+	}
+
+	if m.id == "" {
+		m.id = uuid.New().String()
+	}
+
+	// Clean sessions never share a group with an earlier connection of the client
+	m.group = "mqtt." + m.id
+	if connect.CleanSession {
+		m.group += "." + uuid.New().String()
+	}
+
+	if connect.WillFlag {
+		var topic string
+		if topic, err = fromMQTT(connect.WillTopic); err != nil {
+			return nil, err
+		}
+		m.will = &api.Event{Topic: topic, Data: connect.WillMessage}
+	}
+
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	return m, nil
+}
+
+// run processes packets from the client until it disconnects. If the connection is
+// lost without a DISCONNECT, the client's will is published. Publishes are handed to
+// the session's publisher so that reading never waits on delivery; otherwise a client
+// that subscribes to a topic it publishes to could not acknowledge the events sent to
+// it while its publish waits for them to be acknowledged.
+func (m *mqttSession) run() {
+	// A new connection with the same client id takes over the session
+	m.srv.mqtt.Lock()
+	if prev, ok := m.srv.mqtt.sessions[m.id]; ok {
+		log.Info().Str("client", m.id).Msg("mqtt client reconnected, closing previous connection")
+		prev.conn.Close()
+	}
+	m.srv.mqtt.sessions[m.id] = m
+	m.srv.mqtt.Unlock()
+
+	log.Info().Str("client", m.id).Str("remote", m.conn.RemoteAddr().String()).Msg("mqtt client connected")
+	defer m.close()
+
+	routed := make(chan struct{})
+	defer func() {
+		// Stop the subscriptions first so that a publish blocked on them is released,
+		// then publish what the client sent before it disconnected.
+		m.stop()
+		close(m.inbound)
+		<-routed
+	}()
+
+	go m.publisher(routed)
+	go m.discover()
+	for {
+		if m.alive > 0 {
+			m.conn.SetReadDeadline(time.Now().Add(m.alive))
+		} else {
+			m.conn.SetReadDeadline(time.Time{})
+		}
+
+		packet, err := readPacket(m.r, m.srv.valid.MaxMessageSize())
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Debug().Err(err).Str("client", m.id).Msg("could not read mqtt packet")
+			}
+			return
+		}
+
+		switch p := packet.(type) {
+		case *packets.PublishPacket, *packets.PubrelPacket:
+			err = m.enqueue(packet)
+		case *packets.PubackPacket:
+			m.acked(p.MessageID)
+		case *packets.SubscribePacket:
+			err = m.subscribe(p)
+		case *packets.UnsubscribePacket:
+			err = m.unsubscribe(p)
+		case *packets.PingreqPacket:
+			err = m.write(&packets.PingrespPacket{FixedHeader: packets.FixedHeader{MessageType: packets.Pingresp}})
+		case *packets.DisconnectPacket:
+			m.will = nil
+			return
+		case *packets.PubrecPacket, *packets.PubcompPacket:
+			// Events are never sent with QoS 2 so these are unexpected but harmless
+		default:
+			log.Debug().Str("client", m.id).Str("packet", packet.String()).Msg("unexpected mqtt packet, disconnecting")
+			return
+		}
+
+		if err != nil {
+			log.Warn().Err(err).Str("client", m.id).Msg("closing mqtt connection")
+			return
+		}
+	}
+}
+
+// close stops the session's subscriptions and publishes its will if it has one.
+func (m *mqttSession) close() {
+	m.conn.Close()
+	m.cancel()
+	m.wg.Wait()
+
+	m.srv.mqtt.Lock()
+	if m.srv.mqtt.sessions[m.id] == m {
+		delete(m.srv.mqtt.sessions, m.id)
+	}
+	m.srv.mqtt.Unlock()
+
+	if m.will != nil {
+		// The session context is canceled so the will is published with a new context
+		ctx := context.Background()
+		if principal, ok := auth.FromContext(m.ctx); ok {
+			ctx = auth.NewContext(ctx, principal)
+		}
+
+		if err := m.route(ctx, m.will); err != nil {
+			log.Warn().Err(err).Str("client", m.id).Str("topic", m.will.Topic).Msg("could not publish mqtt will")
+		}
+	}
+	log.Info().Str("client", m.id).Msg("mqtt client disconnected")
+}
+
+// enqueue hands a PUBLISH or PUBREL to the publisher without waiting for it to be
+// processed; the client is disconnected if the queue is full.
+func (m *mqttSession) enqueue(packet packets.ControlPacket) error {
+	select {
+	case m.inbound <- packet:
+		return nil
+	default:
+		return errMQTTQueueFull
+	}
+}
+
+// publisher publishes the events the client sent in the order they were read and
+// closes routed when the queue is closed. If a publish fails, the connection is closed
+// and the rest of the queue is discarded.
+func (m *mqttSession) publisher(routed chan<- struct{}) {
+	defer close(routed)
+	for packet := range m.inbound {
+		var err error
+		switch p := packet.(type) {
+		case *packets.PublishPacket:
+			err = m.publish(p)
+		case *packets.PubrelPacket:
+			delete(m.pubrec, p.MessageID)
+			err = m.write(&packets.PubcompPacket{FixedHeader: packets.FixedHeader{MessageType: packets.Pubcomp}, MessageID: p.MessageID})
+		}
+
+		if err != nil {
+			log.Warn().Err(err).Str("client", m.id).Msg("closing mqtt connection")
+			m.conn.Close()
+			for range m.inbound {
+			}
+			return
+		}
+	}
+}
+
+// publish an event from the client, acknowledging it once it has been published. A
+// client cannot be told that a publish failed in MQTT 3.1.1 so it is disconnected.
+// Only called by the publisher, which owns the QoS 2 packet ids awaiting PUBREL.
+func (m *mqttSession) publish(p *packets.PublishPacket) (err error) {
+	if p.Qos == 2 {
+		if _, ok := m.pubrec[p.MessageID]; ok {
+			// The client did not receive the PUBREC; the event was already published
+			return m.write(&packets.PubrecPacket{FixedHeader: packets.FixedHeader{MessageType: packets.Pubrec}, MessageID: p.MessageID})
+		}
+	}
+
+	var topic string
+	if topic, err = fromMQTT(p.TopicName); err != nil {
+		return err
+	}
+
+	if m.srv.InMaintenance() {
+		return errors.New("the switchback server is currently in maintenance mode")
+	}
+
+	if err = m.route(m.ctx, &api.Event{Topic: topic, Data: p.Payload}); err != nil {
+		return fmt.Errorf("could not publish to %q: %w", topic, err)
+	}
+
+	switch p.Qos {
+	case 1:
+		return m.write(&packets.PubackPacket{FixedHeader: packets.FixedHeader{MessageType: packets.Puback}, MessageID: p.MessageID})
+	case 2:
+		m.pubrec[p.MessageID] = struct{}{}
+		return m.write(&packets.PubrecPacket{FixedHeader: packets.FixedHeader{MessageType: packets.Pubrec}, MessageID: p.MessageID})
+	}
+	return nil
+}
+
+// route publishes the event on this server or forwards it to the cluster leader.
+func (m *mqttSession) route(ctx context.Context, event *api.Event) (err error) {
+	if m.srv.cluster != nil && !m.srv.cluster.IsLeader() {
+		_, err = m.srv.forwardEvents(ctx, []*api.Event{event})
+		return err
+	}
+
+	if err = m.srv.publish(ctx, principalName(m.ctx), event); errors.Is(err, ErrDuplicate) {
+		return nil
+	}
+	return err
+}
+
+// subscribe adds the topic filters to the session and starts the subscriptions of the
+// topics they match. Filters that are invalid or that name a topic the client may not
+// subscribe to are rejected.
+func (m *mqttSession) subscribe(p *packets.SubscribePacket) error {
+	codes := make([]byte, len(p.Topics))
+	for i, name := range p.Topics {
+		filter, err := m.parseFilter(name, p.Qoss[i])
+		if err == nil && filter.exact() {
+			err = m.srv.authorize(m.ctx, auth.Subscribe, filter.topic(), filter.group)
+		}
+
+		if err != nil {
+			log.Debug().Err(err).Str("client", m.id).Str("filter", name).Msg("mqtt subscription rejected")
+			codes[i] = mqttSubackFailure
+			continue
+		}
+
+		m.submu.Lock()
+		m.filters[name] = filter
+		m.submu.Unlock()
+		codes[i] = filter.qos
+	}
+
+	if err := m.write(&packets.SubackPacket{FixedHeader: packets.FixedHeader{MessageType: packets.Suback}, MessageID: p.MessageID, ReturnCodes: codes}); err != nil {
+		return err
+	}
+
+	m.refresh()
+	return nil
+}
+
+// unsubscribe removes the topic filters and stops the subscriptions of topics that no
+// longer match any filter.
+func (m *mqttSession) unsubscribe(p *packets.UnsubscribePacket) error {
+	m.submu.Lock()
+	for _, name := range p.Topics {
+		delete(m.filters, name)
+	}
+
+	for topic, cancel := range m.topics {
+		if m.match(topic) == nil {
+			cancel()
+			delete(m.topics, topic)
+		}
+	}
+	m.submu.Unlock()
+
+	return m.write(&packets.UnsubackPacket{FixedHeader: packets.FixedHeader{MessageType: packets.Unsuback}, MessageID: p.MessageID})
+}
+
+// stop cancels the session's subscriptions and removes its filters so that they are
+// not restarted when the session is refreshed.
+func (m *mqttSession) stop() {
+	m.submu.Lock()
+	defer m.submu.Unlock()
+
+	for topic, cancel := range m.topics {
+		cancel()
+		delete(m.topics, topic)
+	}
+	m.filters = make(map[string]*mqttFilter)
+}
+
+// discover refreshes the session's subscriptions at the refresh interval so that
+// wildcard filters subscribe to new topics and subscriptions that were closed, e.g.
+// when the cluster leader changed, are restarted.
+func (m *mqttSession) discover() {
+	ticker := time.NewTicker(m.srv.conf.MQTT.Refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.refresh()
+		}
+	}
+}
+
+// refresh starts a subscription for each topic that matches the session's filters and
+// that the client may subscribe to, if it is not already subscribed.
+func (m *mqttSession) refresh() {
+	if m.srv.InMaintenance() || m.ctx.Err() != nil {
+		return
+	}
+
+	m.submu.Lock()
+	defer m.submu.Unlock()
+
+	topics := make(map[string]struct{})
+	wildcards := false
+	for _, filter := range m.filters {
+		if filter.exact() {
+			topics[filter.topic()] = struct{}{}
+		} else {
+			wildcards = true
+		}
+	}
+
+	if wildcards {
+		list, err := m.srv.ListTopics(m.ctx, &api.TopicQuery{})
+		if err != nil {
+			log.Warn().Err(err).Str("client", m.id).Msg("could not list topics for mqtt subscriptions")
+			return
+		}
+
+		for _, topic := range list.Topics {
+			topics[topic.Name] = struct{}{}
+		}
+	}
+
+	for topic := range topics {
+		if _, ok := m.topics[topic]; ok {
+			continue
+		}
+
+		filter := m.match(topic)
+		if filter == nil || !m.srv.allowed(m.ctx, auth.Subscribe, topic, filter.group) {
+			continue
+		}
+		m.start(topic, filter)
+	}
+}
+
+// start consumes events from the topic and sends them to the client until the
+// subscription is canceled or closed. The subscription is not started in maintenance
+// mode; it is started when the session is refreshed after maintenance ends. Must hold
+// the subscription lock.
+func (m *mqttSession) start(topic string, filter *mqttFilter) {
+	// Track the stream so that it can be drained
+	closeStream, ok := m.srv.openStream()
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.topics[topic] = cancel
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer closeStream()
+		defer cancel()
+
+		stream := &mqttStream{session: m, ctx: ctx, qos: filter.qos}
+		if err := m.srv.subscribe(&api.Subscription{Topic: topic, Group: filter.group}, stream); err != nil {
+			log.Debug().Err(err).Str("client", m.id).Str("topic", topic).Msg("mqtt subscription closed")
+		}
+
+		// Allow the subscription to be restarted the next time the session is refreshed
+		m.submu.Lock()
+		if ctx.Err() == nil {
+			delete(m.topics, topic)
+		}
+		m.submu.Unlock()
+	}()
+}
+
+// match returns the filter with the highest QoS that matches the topic, if any. Must
+// hold the subscription lock.
+func (m *mqttSession) match(topic string) (match *mqttFilter) {
+	for _, filter := range m.filters {
+		if filter.matches(topic) && (match == nil || filter.qos > match.qos) {
+			match = filter
+		}
+	}
+	return match
+}
+
+// write sends a packet to the client; the connection is closed if the write fails.
+func (m *mqttSession) write(packet packets.ControlPacket) (err error) {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+
+	m.conn.SetWriteDeadline(time.Now().Add(mqttWriteTimeout))
+	if err = packet.Write(m.conn); err != nil {
+		m.conn.Close()
+	}
+	return err
+}
+
+// reserve returns an unused packet id and a channel that is closed when the client
+// acknowledges the packet.
+func (m *mqttSession) reserve() (uint16, chan struct{}) {
+	m.idmu.Lock()
+	defer m.idmu.Unlock()
+
+	for {
+		m.next++
+		if m.next == 0 {
+			m.next = 1
+		}
+
+		if _, ok := m.pending[m.next]; !ok {
+			ack := make(chan struct{})
+			m.pending[m.next] = ack
+			return m.next, ack
+		}
+	}
+}
+
+func (m *mqttSession) acked(id uint16) {
+	m.idmu.Lock()
+	defer m.idmu.Unlock()
+
+	if ack, ok := m.pending[id]; ok {
+		close(ack)
+		delete(m.pending, id)
+	}
+}
+
+func (m *mqttSession) release(id uint16) {
+	m.idmu.Lock()
+	defer m.idmu.Unlock()
+	delete(m.pending, id)
+}
+
+// mqttStream sends the events of a subscription to an MQTT client. QoS 1 batches are
+// not acknowledged to the group until the client has acknowledged every event in them.
+type mqttStream struct {
+	session *mqttSession
+	ctx     context.Context
+	qos     byte
+}
+
+func (s *mqttStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *mqttStream) send(events []*api.Event) (err error) {
+	acks := make([]chan struct{}, 0, len(events))
+	ids := make([]uint16, 0, len(events))
+	defer func() {
+		for _, id := range ids {
+			s.session.release(id)
+		}
+	}()
+
+	for _, event := range events {
+		packet := &packets.PublishPacket{
+			FixedHeader: packets.FixedHeader{MessageType: packets.Publish, Qos: s.qos},
+			TopicName:   toMQTT(event.Topic),
+			Payload:     event.Data,
+		}
+
+		if s.qos > 0 {
+			id, ack := s.session.reserve()
+			packet.MessageID = id
+			ids = append(ids, id)
+			acks = append(acks, ack)
+		}
+
+		if err = s.session.write(packet); err != nil {
+			return io.EOF
+		}
+	}
+
+	for _, ack := range acks {
+		select {
+		case <-ack:
+		case <-s.ctx.Done():
+			return io.EOF
+		}
+	}
+	return nil
+}
+
+func (s *mqttStream) batcher(bytes int) *batcher {
+	return newBatcher(s.session.srv.conf.MQTT.MaxInflight, 0, bytes)
+}
+
+func (s *mqttStream) proxy(ctx context.Context, client api.SwitchbackClient, in *api.Subscription) (func() ([]*api.Event, error), error) {
+	return proxyEvents(ctx, client, in)
+}
+
+// mqttFilter is a topic filter that the client subscribed to, split into its levels.
+type mqttFilter struct {
+	levels []string
+	group  string
+	qos    byte
+}
+
+// parseFilter validates the filter and returns it with the group its subscriptions
+// join; QoS 2 subscriptions are granted QoS 1.
+func (m *mqttSession) parseFilter(name string, qos byte) (_ *mqttFilter, err error) {
+	filter := &mqttFilter{group: m.group, qos: qos}
+	if filter.qos > 1 {
+		filter.qos = 1
+	}
+
+	if strings.HasPrefix(name, "$share/") {
+		parts := strings.SplitN(name, "/", 3)
+		if len(parts) != 3 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid shared subscription %q", name)
+		}
+		filter.group, name = parts[1], parts[2]
+	}
+
+	if name == "" || strings.Contains(name, ".") {
+		return nil, fmt.Errorf("invalid topic filter %q", name)
+	}
+
+	filter.levels = strings.Split(name, "/")
+	for i, level := range filter.levels {
+		switch {
+		case level == "#" && i != len(filter.levels)-1:
+			return nil, fmt.Errorf("multi-level wildcard must be last in %q", name)
+		case level == "":
+			return nil, fmt.Errorf("empty topic level in %q", name)
+		case level != "#" && level != "+" && strings.ContainsAny(level, "#+"):
+			return nil, fmt.Errorf("wildcards must occupy an entire level in %q", name)
+		}
+	}
+
+	if filter.exact() {
+		if err = m.srv.valid.Topic(filter.topic()); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+// exact returns true if the filter has no wildcards.
+func (f *mqttFilter) exact() bool {
+	for _, level := range f.levels {
+		if level == "+" || level == "#" {
+			return false
+		}
+	}
+	return true
+}
+
+// topic returns the switchback topic of an exact filter.
+func (f *mqttFilter) topic() string {
+	return strings.Join(f.levels, ".")
+}
+
+func (f *mqttFilter) matches(topic string) bool {
+	levels := strings.Split(topic, ".")
+	for i, level := range f.levels {
+		if level == "#" {
+			return true
+		}
+
+		if i >= len(levels) || (level != "+" && level != levels[i]) {
+			return false
+		}
+	}
+	return len(levels) == len(f.levels)
+}
+
+// fromMQTT converts an MQTT topic name to a switchback topic.
+func fromMQTT(name string) (string, error) {
+	if strings.ContainsAny(name, ".+#") {
+		return "", fmt.Errorf("invalid mqtt topic name %q", name)
+	}
+	return strings.ReplaceAll(name, "/", "."), nil
+}
+
+// toMQTT converts a switchback topic to an MQTT topic name.
+func toMQTT(topic string) string {
+	return strings.ReplaceAll(topic, ".", "/")
+}
+
+// readPacket reads the next packet from the client, refusing packets larger than the
+// limit before reading them into memory.
+func readPacket(r *bufio.Reader, limit int) (_ packets.ControlPacket, err error) {
+	var b byte
+	if b, err = r.ReadByte(); err != nil {
+		return nil, err
+	}
+
+	header := packets.FixedHeader{MessageType: b >> 4, Dup: b&0x08 != 0, Qos: (b >> 1) & 0x03, Retain: b&0x01 != 0}
+
+	// The remaining length is encoded in up to four bytes, seven bits at a time
+	multiplier := 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return nil, errors.New("malformed mqtt remaining length")
+		}
+
+		if b, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+
+		header.RemainingLength += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+
+	if header.RemainingLength > limit {
+		return nil, errMQTTPacketSize
+	}
+
+	var packet packets.ControlPacket
+	if packet, err = packets.NewControlPacketWithHeader(header); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, header.RemainingLength)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	if err = packet.Unpack(bytes.NewBuffer(data)); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// connack refuses the connection with the return code.
+func connack(conn net.Conn, code byte) {
+	conn.SetWriteDeadline(time.Now().Add(mqttWriteTimeout))
+	packet := &packets.ConnackPacket{FixedHeader: packets.FixedHeader{MessageType: packets.Connack}, ReturnCode: code}
+	packet.Write(conn)
+}
+
+func (s *Server) mqttHealth() *api.ComponentHealth {
+	s.mqtt.Lock()
+	sessions := len(s.mqtt.sessions)
+	s.mqtt.Unlock()
+
+	return &api.ComponentHealth{
+		Name:    "mqtt",
+		Status:  HealthOK,
+		Message: fmt.Sprintf("%d clients connected", sessions),
+	}
+}
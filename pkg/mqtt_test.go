@@ -0,0 +1,181 @@
+package switchback_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/rs/zerolog"
+)
+
+// serveMQTT runs a server with the MQTT broker enabled and returns a connection to the
+// broker that is closed when the test is complete.
+func serveMQTT(t *testing.T) (*switchback.Server, net.Conn) {
+	t.Helper()
+	conf, err := config.New()
+	if err != nil {
+		t.Fatalf("could not load config: %s", err)
+	}
+
+	conf.LogLevel = config.LevelDecoder(zerolog.ErrorLevel)
+	conf.ShutdownTimeout = time.Second
+	conf.BindAddr = freeAddr(t)
+	conf.MQTT.Enabled = true
+	conf.MQTT.BindAddr = freeAddr(t)
+
+	srv, err := switchback.New(conf)
+	if err != nil {
+		t.Fatalf("could not create server: %s", err)
+	}
+
+	go srv.Serve()
+	t.Cleanup(func() {
+		if err := srv.Shutdown(); err != nil {
+			t.Errorf("could not shutdown server: %s", err)
+		}
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", conf.MQTT.BindAddr)
+		if err == nil {
+			t.Cleanup(func() { conn.Close() })
+			return srv, conn
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("could not connect to mqtt broker: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// freeAddr returns a local address with a port that is not in use.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	sock, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not find a free port: %s", err)
+	}
+	defer sock.Close()
+	return sock.Addr().String()
+}
+
+// request writes the packet to the broker and returns the next packet it sends.
+func request(t *testing.T, conn net.Conn, packet packets.ControlPacket) packets.ControlPacket {
+	t.Helper()
+	if err := packet.Write(conn); err != nil {
+		t.Fatalf("could not write %s: %s", packet, err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := packets.ReadPacket(conn)
+	if err != nil {
+		t.Fatalf("could not read reply to %s: %s", packet, err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	return reply
+}
+
+// connectMQTT sends CONNECT and fails the test unless the connection is accepted.
+func connectMQTT(t *testing.T, conn net.Conn, id string) {
+	t.Helper()
+	connect := packets.NewControlPacket(packets.Connect).(*packets.ConnectPacket)
+	connect.ProtocolName, connect.ProtocolVersion = "MQTT", 4
+	connect.ClientIdentifier, connect.CleanSession = id, true
+	if connack := request(t, conn, connect).(*packets.ConnackPacket); connack.ReturnCode != packets.Accepted {
+		t.Fatalf("connection refused with code %d", connack.ReturnCode)
+	}
+}
+
+// A QoS 1 client that subscribes to a topic it publishes to acknowledges the events sent
+// to it while its own publishes are waiting for them to be delivered.
+func TestMQTTLoopback(t *testing.T) {
+	const n = 500
+	srv, conn := serveMQTT(t)
+
+	connectMQTT(t, conn, "loopback")
+
+	subscribe := packets.NewControlPacket(packets.Subscribe).(*packets.SubscribePacket)
+	subscribe.MessageID, subscribe.Topics, subscribe.Qoss = 1, []string{"sensors/loop"}, []byte{1}
+	if suback := request(t, conn, subscribe).(*packets.SubackPacket); suback.ReturnCodes[0] != 1 {
+		t.Fatalf("expected subscription to be granted qos 1, got %d", suback.ReturnCodes[0])
+	}
+	waitForGroups(t, srv.PubSub(), "sensors.loop", 1)
+
+	// Acknowledge the events delivered to the client while it is publishing
+	var wmu sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var received, acked int
+		for received < n || acked < n {
+			packet, err := packets.ReadPacket(conn)
+			if err != nil {
+				t.Errorf("could not read packet after %d events and %d acks: %s", received, acked, err)
+				return
+			}
+
+			switch p := packet.(type) {
+			case *packets.PublishPacket:
+				received++
+				puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				puback.MessageID = p.MessageID
+				wmu.Lock()
+				puback.Write(conn)
+				wmu.Unlock()
+			case *packets.PubackPacket:
+				acked++
+			}
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for i := 1; i <= n; i++ {
+		publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		publish.Qos, publish.MessageID, publish.TopicName, publish.Payload = 1, uint16(i), "sensors/loop", []byte("reading")
+
+		wmu.Lock()
+		err := publish.Write(conn)
+		wmu.Unlock()
+		if err != nil {
+			t.Fatalf("could not publish event %d: %s", i, err)
+		}
+	}
+	<-done
+}
+
+// Events published by a client immediately before it disconnects are published.
+func TestMQTTDisconnect(t *testing.T) {
+	srv, conn := serveMQTT(t)
+	connectMQTT(t, conn, "sensor")
+
+	consumer, err := srv.PubSub().Connect(&api.Subscription{Topic: "sensors.last"})
+	if err != nil {
+		t.Fatalf("could not connect consumer: %s", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		publish.TopicName, publish.Payload = "sensors/last", []byte("reading")
+		if err = publish.Write(conn); err != nil {
+			t.Fatalf("could not publish event %d: %s", i, err)
+		}
+	}
+
+	if err = packets.NewControlPacket(packets.Disconnect).Write(conn); err != nil {
+		t.Fatalf("could not disconnect: %s", err)
+	}
+	conn.Close()
+
+	for _, event := range receive(t, consumer.Events(), 10) {
+		if string(event.Data) != "reading" {
+			t.Errorf("unexpected event data %q", event.Data)
+		}
+	}
+}
@@ -1,35 +1,144 @@
 package switchback
 
 import (
+	"context"
 	"errors"
+	"hash/fnv"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/dedup"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
+// PubSub routes published events to the consumer groups subscribed to a topic. It can
+// be used directly as a library without a gRPC server in front of it.
+//
+// Each topic is a single partition with a total order: events are assigned increasing
+// offsets and are routed to every group in offset order, so every consumer receives the
+// events of a topic in increasing offset order. Events published by a single publisher
+// (e.g. on one stream) are assigned offsets in the order they were published, and the
+// events of a transaction have contiguous offsets in each topic. Events with a key are
+// always delivered to the same consumer in a group while its membership is unchanged, so
+// they are processed in order; keyless events are distributed round robin. When a group
+// resumes from a log after a failure, events after its acknowledged offset may be
+// delivered again, in order.
 type PubSub struct {
 	sync.Mutex
-	topics map[string]map[string]*Group
+	topics     map[string]map[string]*Group
+	offsets    map[string]uint64
+	sequencers map[string]*sync.Mutex
+	delivery   rate.Limit
+	log        Log
+	dedup      *dedup.Window
+}
+
+// ErrDuplicate is returned when an idempotent producer publishes an event with a sequence
+// number it has already published to the topic. The event's offset is set to the offset
+// originally assigned to it (or zero if it has left the deduplication window).
+var ErrDuplicate = dedup.ErrDuplicate
+
+// Log is a durable or replicated record of the events published to each topic and the
+// offsets acknowledged by each group. If the router has a log, a group that reconnects
+// resumes from its acknowledged offset, e.g. after the cluster leader has changed.
+type Log interface {
+	// Offset returns the offset of the last event published to the topic.
+	Offset(topic string) uint64
+
+	// Committed returns the last offset acknowledged by the group, if any.
+	Committed(topic, group string) (uint64, bool)
+
+	// Since returns the events retained in the log after the offset.
+	Since(topic string, offset uint64) []*api.Event
 }
 
 type Group struct {
 	sync.RWMutex
 	id        string
+	anonymous bool
 	consumers []*Consumer
 	offset    uint64
+	acked     uint64
+	replayed  uint64
 	index     int
+	limiter   *rate.Limiter
 }
 
+// Consumer receives the events routed to one member of a group. Events are sent to the
+// consumer without holding the group lock so that a slow consumer does not block the
+// group's membership or stats; the send lock ensures the stream is not closed while an
+// event is being sent to it.
 type Consumer struct {
-	id     uuid.UUID
-	stream chan *api.Event
+	id      uuid.UUID
+	topic   string
+	group   string
+	parent  *Group
+	stream  chan *api.Event
+	done    chan struct{}
+	closing sync.Once
+	sendmu  sync.Mutex
+	closed  bool
+	limiter *rate.Limiter
+}
+
+// NewPubSub creates an empty pub/sub router with no topics or groups.
+func NewPubSub() *PubSub {
+	return &PubSub{
+		topics:     make(map[string]map[string]*Group),
+		offsets:    make(map[string]uint64),
+		sequencers: make(map[string]*sync.Mutex),
+		delivery:   rate.Inf,
+	}
+}
+
+// LimitDelivery caps the number of events per second delivered to the consumers of each
+// group; if the limit is zero or less then delivery is unlimited. Existing groups are
+// updated with the new limit.
+func (p *PubSub) LimitDelivery(events float64) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.delivery = rate.Inf
+	if events > 0 {
+		p.delivery = rate.Limit(events)
+	}
+
+	for _, groups := range p.topics {
+		for _, group := range groups {
+			group.limiter.SetLimit(p.delivery)
+		}
+	}
+}
+
+// UseLog sets the log that groups resume from when they connect; it should be set
+// before any consumers are connected.
+func (p *PubSub) UseLog(log Log) {
+	p.Lock()
+	defer p.Unlock()
+	p.log = log
+}
+
+// Deduplicate drops events that idempotent producers publish with sequence numbers that
+// are already in the window; it should be set before any events are published.
+func (p *PubSub) Deduplicate(window *dedup.Window) {
+	p.Lock()
+	defer p.Unlock()
+	p.dedup = window
 }
 
-func (p *PubSub) Connect(sub *api.Subscription) (<-chan *api.Event, error) {
+func (p *PubSub) Connect(sub *api.Subscription) (*Consumer, error) {
+	var anonymous bool
 	if sub.Group == "" {
 		sub.Group = uuid.New().String()
+		anonymous = true
 	}
 
 	p.Lock()
@@ -38,57 +147,462 @@ func (p *PubSub) Connect(sub *api.Subscription) (<-chan *api.Event, error) {
 		p.topics[sub.Topic] = make(map[string]*Group)
 	}
 
+	var backlog []*api.Event
 	if _, ok := p.topics[sub.Topic][sub.Group]; !ok {
-		p.topics[sub.Topic][sub.Group] = &Group{
+		group := &Group{
 			id:        sub.Group,
+			anonymous: anonymous,
 			consumers: make([]*Consumer, 0, 1),
-			offset:    0,
+			offset:    p.offsets[sub.Topic],
 			index:     0,
+			limiter:   rate.NewLimiter(p.delivery, 1),
 		}
+
+		if p.log != nil {
+			backlog = p.resume(sub.Topic, group)
+		}
+		p.topics[sub.Topic][sub.Group] = group
 	}
 
 	group := p.topics[sub.Topic][sub.Group]
-	consumer := &Consumer{id: uuid.New(), stream: make(chan *api.Event, 32)}
+	consumer := &Consumer{
+		id:      uuid.New(),
+		topic:   sub.Topic,
+		group:   sub.Group,
+		parent:  group,
+		stream:  make(chan *api.Event, 32+len(backlog)),
+		done:    make(chan struct{}),
+		limiter: group.limiter,
+	}
+
+	// The stream has enough capacity to queue the backlog without blocking
+	for _, event := range backlog {
+		consumer.stream <- event
+	}
+
+	group.Lock()
 	group.consumers = append(group.consumers, consumer)
+	group.Unlock()
+
 	log.Info().Str("topic", sub.Topic).Str("group", sub.Group).Str("id", consumer.id.String()).Msg("subscriber connected")
-	return consumer.stream, nil
+	return consumer, nil
 }
 
-func (p *PubSub) Publish(event *api.Event) (err error) {
-	// TODO: don't simply drop event, wait for consumer to connect then emit event (queuing behavior)
-	if groups, ok := p.topics[event.Topic]; ok {
-		if len(groups) == 0 {
-			return errors.New("no available groups")
+// resume positions a new group at its acknowledged offset in the log and returns the
+// events it has not acknowledged. Events routed after the group is created that are
+// also in the backlog are skipped. Must hold the lock to call this method.
+func (p *PubSub) resume(topic string, group *Group) []*api.Event {
+	if offset := p.log.Offset(topic); offset > p.offsets[topic] {
+		p.offsets[topic] = offset
+		group.offset = offset
+	}
+
+	committed, ok := p.log.Committed(topic, group.id)
+	if !ok || committed >= group.offset {
+		return nil
+	}
+
+	backlog := p.log.Since(topic, committed)
+	group.acked = committed
+	if len(backlog) > 0 {
+		group.replayed = backlog[len(backlog)-1].Meta.GetOffset()
+		if group.replayed > group.offset {
+			group.offset = group.replayed
 		}
+	}
+	log.Debug().Str("topic", topic).Str("group", group.id).Uint64("committed", committed).Int("backlog", len(backlog)).Msg("resuming group from log")
+	return backlog
+}
 
+// Disconnect removes the consumer from its group and closes its event stream. If the
+// group has no more consumers it is removed from the topic along with its metrics.
+func (p *PubSub) Disconnect(consumer *Consumer) {
+	// Signal any publisher blocked on the consumer before acquiring the group lock; the
+	// consumer may be disconnected concurrently by its stream and by Close.
+	var closing bool
+	consumer.closing.Do(func() {
+		close(consumer.done)
+		closing = true
+	})
+
+	if !closing {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	group, ok := p.topics[consumer.topic][consumer.group]
+	if !ok {
+		return
+	}
+
+	group.Lock()
+	defer group.Unlock()
+	for i, c := range group.consumers {
+		if c == consumer {
+			group.consumers = append(group.consumers[:i], group.consumers[i+1:]...)
+			break
+		}
+	}
+
+	// A publisher sending to the consumer is released by done before the stream is closed
+	consumer.sendmu.Lock()
+	consumer.closed = true
+	close(consumer.stream)
+	consumer.sendmu.Unlock()
+
+	if len(group.consumers) == 0 {
+		delete(p.topics[consumer.topic], consumer.group)
+		if len(p.topics[consumer.topic]) == 0 {
+			delete(p.topics, consumer.topic)
+		}
+
+		if !group.anonymous {
+			eventsDelivered.DeleteLabelValues(consumer.topic, consumer.group)
+		}
+	}
+	log.Info().Str("topic", consumer.topic).Str("group", consumer.group).Str("id", consumer.id.String()).Msg("subscriber disconnected")
+}
+
+// Close disconnects every consumer, closing their event channels.
+func (p *PubSub) Close() {
+	p.Lock()
+	consumers := make([]*Consumer, 0)
+	for _, groups := range p.topics {
 		for _, group := range groups {
-			// TODO: use multierror to return all group errors to the caller
-			if err = group.Publish(event); err != nil {
-				log.Error().Err(err).Str("topic", event.Topic).Str("group", group.id).Msg("could not publish event to group")
-			}
+			group.RLock()
+			consumers = append(consumers, group.consumers...)
+			group.RUnlock()
+		}
+	}
+	p.Unlock()
+
+	for _, consumer := range consumers {
+		p.Disconnect(consumer)
+	}
+}
+
+// Publish assigns the event the next offset in its topic and sends it to one consumer
+// in every group subscribed to the topic. ErrDuplicate is returned without routing the
+// event if its producer has already published its sequence number to the topic.
+func (p *PubSub) Publish(ctx context.Context, event *api.Event) (err error) {
+	unlock := p.sequence(event.Topic)
+	defer unlock()
+
+	p.Lock()
+	if event.Meta == nil {
+		event.Meta = &api.Metadata{}
+	}
+
+	now := time.Now()
+	if offset, ok := p.dedup.Check(event.Topic, event.Producer, event.Sequence, now); ok {
+		p.Unlock()
+		event.Meta.Offset = offset
+		return ErrDuplicate
+	}
+
+	p.offsets[event.Topic]++
+	event.Meta.Offset = p.offsets[event.Topic]
+	p.dedup.Record(event.Topic, event.Producer, event.Sequence, event.Meta.Offset, now)
+	p.Unlock()
+	return p.Route(ctx, event)
+}
+
+// PublishAll assigns offsets to all of the events before any of them are routed, so
+// that the events of each topic have contiguous offsets, and then sends them to the
+// consumer groups subscribed to their topics. Duplicates from idempotent producers are
+// not routed; the events that were published are returned.
+func (p *PubSub) PublishAll(ctx context.Context, events []*api.Event) []*api.Event {
+	topics := make([]string, 0, len(events))
+	for _, event := range events {
+		topics = append(topics, event.Topic)
+	}
+
+	unlock := p.sequence(topics...)
+	defer unlock()
+
+	p.Lock()
+	now := time.Now()
+	published := make([]*api.Event, 0, len(events))
+	for _, event := range events {
+		if event.Meta == nil {
+			event.Meta = &api.Metadata{}
+		}
+
+		if offset, ok := p.dedup.Check(event.Topic, event.Producer, event.Sequence, now); ok {
+			event.Meta.Offset = offset
+			continue
+		}
+
+		p.offsets[event.Topic]++
+		event.Meta.Offset = p.offsets[event.Topic]
+		p.dedup.Record(event.Topic, event.Producer, event.Sequence, event.Meta.Offset, now)
+		published = append(published, event)
+	}
+	p.Unlock()
+
+	for _, event := range published {
+		if err := p.Route(ctx, event); err != nil {
+			log.Error().Err(err).Str("topic", event.Topic).Msg("could not route event")
+		}
+	}
+	return published
+}
+
+// sequence locks the topics so that the offsets assigned to their events and the order
+// in which the events are routed are the same; it returns a function that unlocks them.
+// Events assigned offsets elsewhere, e.g. by a cluster log, must be assigned and routed
+// while holding the sequence lock to preserve the total order of the topic. Topics are
+// locked in sorted order so that concurrent callers cannot deadlock.
+func (p *PubSub) sequence(topics ...string) (unlock func()) {
+	sort.Strings(topics)
+	locks := make([]*sync.Mutex, 0, len(topics))
+
+	p.Lock()
+	for i, topic := range topics {
+		if i > 0 && topic == topics[i-1] {
+			continue
+		}
+
+		mu, ok := p.sequencers[topic]
+		if !ok {
+			mu = &sync.Mutex{}
+			p.sequencers[topic] = mu
+		}
+		locks = append(locks, mu)
+	}
+	p.Unlock()
+
+	for _, mu := range locks {
+		mu.Lock()
+	}
+
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+// Route sends an event that has already been assigned an offset, e.g. by the cluster
+// log, to one consumer in every group subscribed to its topic. To preserve the order of
+// the topic, events must be routed in the order their offsets were assigned.
+func (p *PubSub) Route(ctx context.Context, event *api.Event) (err error) {
+	// TODO: don't simply drop event, wait for consumer to connect then emit event (queuing behavior)
+	p.Lock()
+	if offset := event.Meta.GetOffset(); offset > p.offsets[event.Topic] {
+		p.offsets[event.Topic] = offset
+	}
+
+	groups := make([]*Group, 0, len(p.topics[event.Topic]))
+	for _, group := range p.topics[event.Topic] {
+		groups = append(groups, group)
+	}
+	p.Unlock()
+
+	ctx, span := tracer.Start(ctx, "route "+event.Topic, trace.WithAttributes(eventAttributes(event)...))
+	defer span.End()
+	span.SetAttributes(attribute.Int("switchback.groups", len(groups)))
+
+	eventsPublished.WithLabelValues(event.Topic).Inc()
+	bytesPublished.WithLabelValues(event.Topic).Add(float64(len(event.Data)))
+	if len(groups) == 0 {
+		eventsDropped.WithLabelValues(event.Topic, "no_groups").Inc()
+	}
+
+	for _, group := range groups {
+		// TODO: use multierror to return all group errors to the caller
+		if err = group.Publish(ctx, event); err != nil {
+			log.Error().Err(err).Str("topic", event.Topic).Str("group", group.id).Msg("could not publish event to group")
 		}
 	}
 	return nil
 }
 
-func (g *Group) Publish(event *api.Event) (err error) {
-	g.RLock()
-	defer g.RUnlock()
+// Publish sends the event to the next consumer in the group, blocking until the consumer
+// has room for it or is disconnected. The group lock is only held to select the consumer
+// and to update the group's offset; events of a topic are routed one at a time by the
+// sequence lock so they are still queued in offset order.
+func (g *Group) Publish(ctx context.Context, event *api.Event) (err error) {
+	_, span := tracer.Start(ctx, "enqueue "+event.Topic, trace.WithAttributes(semconv.MessagingConsumerIDKey.String(g.id)))
+	defer span.End()
+
+	g.Lock()
+	if event.Meta.GetOffset() <= g.replayed {
+		// The event was already queued from the log when the group resumed
+		g.Unlock()
+		return nil
+	}
 
 	if len(g.consumers) == 0 {
 		// TODO: how to close the group in this case?
+		g.Unlock()
+		eventsDropped.WithLabelValues(event.Topic, "no_consumers").Inc()
 		return errors.New("no available consumers")
 	}
+	consumer := g.next(event)
+	g.Unlock()
+
+	if !consumer.send(event) {
+		eventsDropped.WithLabelValues(event.Topic, "disconnected").Inc()
+		return nil
+	}
+
+	g.Lock()
+	if offset := event.Meta.GetOffset(); offset > g.offset {
+		g.offset = offset
+	}
+	g.Unlock()
+	return nil
+}
+
+// send queues the event for the consumer, returning false if the consumer was
+// disconnected before the event could be queued.
+func (c *Consumer) send(event *api.Event) bool {
+	c.sendmu.Lock()
+	defer c.sendmu.Unlock()
+	if c.closed {
+		return false
+	}
+
+	select {
+	case c.stream <- event:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// next returns the consumer to deliver the event to. Events with a key are sent to the
+// consumer selected by the hash of the key so that events with the same key are queued
+// in order for one consumer; other events are distributed round robin. Must hold the
+// lock and have at least one consumer to call this method.
+func (g *Group) next(event *api.Event) *Consumer {
+	if event.Key != "" {
+		hash := fnv.New32a()
+		hash.Write([]byte(event.Key))
+		return g.consumers[hash.Sum32()%uint32(len(g.consumers))]
+	}
 
 	if g.index >= len(g.consumers) {
 		g.index = 0
 	}
 
-	g.consumers[g.index].stream <- event
-
+	consumer := g.consumers[g.index]
 	g.index++
 	if g.index >= len(g.consumers) {
 		g.index = 0
 	}
-	return nil
+	return consumer
+}
+
+// GroupStats describes the state of a consumer group at a point in time. Lag is the
+// number of events published to the topic that have not been queued for the group,
+// Buffered is the number of queued events that have not been read by its consumers, and
+// Acked is the offset of the last event delivered to one of its consumers.
+type GroupStats struct {
+	Topic     string
+	Group     string
+	Consumers int
+	Buffered  int
+	Capacity  int
+	Offset    uint64
+	Acked     uint64
+	Lag       uint64
+}
+
+// Stats returns the current state of every consumer group.
+func (p *PubSub) Stats() []GroupStats {
+	p.Lock()
+	defer p.Unlock()
+
+	stats := make([]GroupStats, 0, len(p.topics))
+	for topic, groups := range p.topics {
+		for _, group := range groups {
+			group.RLock()
+			gs := GroupStats{
+				Topic:     topic,
+				Group:     group.id,
+				Consumers: len(group.consumers),
+				Offset:    group.offset,
+				Acked:     atomic.LoadUint64(&group.acked),
+				Lag:       p.offsets[topic] - group.offset,
+			}
+			for _, consumer := range group.consumers {
+				gs.Buffered += len(consumer.stream)
+				gs.Capacity += cap(consumer.stream)
+			}
+			group.RUnlock()
+			stats = append(stats, gs)
+		}
+	}
+	return stats
+}
+
+// TopicStats describes a topic that events have been published or subscribed to.
+type TopicStats struct {
+	Topic  string
+	Offset uint64
+	Groups int
+}
+
+// Topics returns the current state of every topic.
+func (p *PubSub) Topics() []TopicStats {
+	p.Lock()
+	defer p.Unlock()
+
+	stats := make([]TopicStats, 0, len(p.offsets))
+	for topic, offset := range p.offsets {
+		stats = append(stats, TopicStats{Topic: topic, Offset: offset, Groups: len(p.topics[topic])})
+	}
+
+	for topic, groups := range p.topics {
+		if _, ok := p.offsets[topic]; !ok {
+			stats = append(stats, TopicStats{Topic: topic, Groups: len(groups)})
+		}
+	}
+	return stats
+}
+
+// ID returns the unique identifier of the consumer.
+func (c *Consumer) ID() uuid.UUID {
+	return c.id
+}
+
+// label returns the group label of the consumer's metrics; groups that were not named by
+// the subscriber share a label so that every new subscription does not add a series.
+func (c *Consumer) label() string {
+	if c.parent.anonymous {
+		return anonymousGroup
+	}
+	return c.group
+}
+
+// Events returns the channel that events are delivered to the consumer on; the channel
+// is closed when the consumer is disconnected.
+func (c *Consumer) Events() <-chan *api.Event {
+	return c.stream
+}
+
+// Ack records that the event at the offset was delivered to the consumer; the group's
+// acknowledged offset is the highest offset acknowledged by any of its consumers.
+func (c *Consumer) Ack(offset uint64) {
+	for {
+		acked := atomic.LoadUint64(&c.parent.acked)
+		if offset <= acked || atomic.CompareAndSwapUint64(&c.parent.acked, acked, offset) {
+			return
+		}
+	}
+}
+
+// Acked returns the acknowledged offset of the consumer's group.
+func (c *Consumer) Acked() uint64 {
+	return atomic.LoadUint64(&c.parent.acked)
+}
+
+// Wait blocks until the group delivery limit allows another event to be sent to the
+// consumer or the context is done.
+func (c *Consumer) Wait(ctx context.Context) error {
+	return c.limiter.Wait(ctx)
 }
@@ -0,0 +1,331 @@
+package switchback_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/api/v1"
+)
+
+const (
+	publishers   = 8
+	perPublisher = 250
+	total        = publishers * perPublisher
+)
+
+// collector reads the events delivered to consumers concurrently with the publishers.
+type collector struct {
+	wg       sync.WaitGroup
+	count    uint64
+	received map[*switchback.Consumer][]*api.Event
+}
+
+func collect(consumers ...*switchback.Consumer) *collector {
+	c := &collector{received: make(map[*switchback.Consumer][]*api.Event, len(consumers))}
+	var mu sync.Mutex
+	for _, consumer := range consumers {
+		c.wg.Add(1)
+		go func(consumer *switchback.Consumer) {
+			defer c.wg.Done()
+			events := make([]*api.Event, 0)
+			for event := range consumer.Events() {
+				events = append(events, event)
+				atomic.AddUint64(&c.count, 1)
+			}
+
+			mu.Lock()
+			c.received[consumer] = events
+			mu.Unlock()
+		}(consumer)
+	}
+	return c
+}
+
+// wait waits for the number of events to be received and then closes the router so that
+// the collectors finish.
+func (c *collector) wait(t *testing.T, pubsub *switchback.PubSub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for atomic.LoadUint64(&c.count) < uint64(n) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out after %d of %d events", atomic.LoadUint64(&c.count), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	pubsub.Close()
+	c.wg.Wait()
+	if count := atomic.LoadUint64(&c.count); count != uint64(n) {
+		t.Fatalf("expected %d events, received %d", n, count)
+	}
+}
+
+// publish runs the publishers concurrently; each publishes its events in sequence with
+// data identifying the publisher and the event's position in its sequence.
+func publish(t *testing.T, pubsub *switchback.PubSub, topic string, key func(publisher, i int) string) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make(chan error, publishers)
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perPublisher; i++ {
+				event := &api.Event{Topic: topic, Data: []byte(fmt.Sprintf("%d:%d", p, i))}
+				if key != nil {
+					event.Key = key(p, i)
+				}
+
+				if err := pubsub.Publish(context.Background(), event); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(p)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("could not publish event: %s", err)
+	}
+}
+
+// position returns the publisher and sequence encoded in the event data.
+func position(t *testing.T, event *api.Event) (publisher, i int) {
+	t.Helper()
+	parts := strings.Split(string(event.Data), ":")
+	if len(parts) != 2 {
+		t.Fatalf("unexpected event data %q", event.Data)
+	}
+
+	var err error
+	if publisher, err = strconv.Atoi(parts[0]); err != nil {
+		t.Fatalf("unexpected event data %q", event.Data)
+	}
+	if i, err = strconv.Atoi(parts[1]); err != nil {
+		t.Fatalf("unexpected event data %q", event.Data)
+	}
+	return publisher, i
+}
+
+// Every consumer receives the events of a topic in increasing offset order and every
+// group sees the same event at each offset.
+func TestTopicTotalOrder(t *testing.T) {
+	pubsub := switchback.NewPubSub()
+	consumers := make([]*switchback.Consumer, 0, 6)
+	for _, group := range []string{"a", "b", "c"} {
+		for i := 0; i < 2; i++ {
+			consumer, err := pubsub.Connect(&api.Subscription{Topic: "ordered", Group: group})
+			if err != nil {
+				t.Fatalf("could not connect consumer: %s", err)
+			}
+			consumers = append(consumers, consumer)
+		}
+	}
+
+	events := collect(consumers...)
+	publish(t, pubsub, "ordered", nil)
+	events.wait(t, pubsub, 3*total)
+
+	order := make(map[uint64]string, total)
+	for _, received := range events.received {
+		var last uint64
+		for _, event := range received {
+			offset := event.Meta.GetOffset()
+			if offset <= last {
+				t.Fatalf("consumer received offset %d after %d", offset, last)
+			}
+			last = offset
+
+			if data, ok := order[offset]; ok && data != string(event.Data) {
+				t.Fatalf("groups received different events at offset %d: %q and %q", offset, data, event.Data)
+			}
+			order[offset] = string(event.Data)
+		}
+	}
+
+	if len(order) != total {
+		t.Errorf("expected %d distinct offsets, got %d", total, len(order))
+	}
+
+	for offset := uint64(1); offset <= total; offset++ {
+		if _, ok := order[offset]; !ok {
+			t.Fatalf("no event delivered at offset %d", offset)
+		}
+	}
+}
+
+// The events of each publisher are assigned offsets in the order they were published.
+func TestPublisherFIFO(t *testing.T) {
+	pubsub := switchback.NewPubSub()
+	consumer, err := pubsub.Connect(&api.Subscription{Topic: "fifo"})
+	if err != nil {
+		t.Fatalf("could not connect consumer: %s", err)
+	}
+
+	events := collect(consumer)
+	publish(t, pubsub, "fifo", nil)
+	events.wait(t, pubsub, total)
+
+	next := make([]int, publishers)
+	for _, event := range events.received[consumer] {
+		p, i := position(t, event)
+		if i != next[p] {
+			t.Fatalf("publisher %d event %d received when event %d was expected", p, i, next[p])
+		}
+		next[p]++
+	}
+}
+
+// Events with the same key are delivered to one consumer in each group in the order
+// they were published.
+func TestKeyOrder(t *testing.T) {
+	pubsub := switchback.NewPubSub()
+	consumers := make([]*switchback.Consumer, 0, 4)
+	for i := 0; i < 4; i++ {
+		consumer, err := pubsub.Connect(&api.Subscription{Topic: "keyed", Group: "workers"})
+		if err != nil {
+			t.Fatalf("could not connect consumer: %s", err)
+		}
+		consumers = append(consumers, consumer)
+	}
+
+	// Each publisher publishes to a few keys that are shared with the other publishers
+	events := collect(consumers...)
+	publish(t, pubsub, "keyed", func(p, i int) string {
+		return fmt.Sprintf("key%d", (p+i)%16)
+	})
+	events.wait(t, pubsub, total)
+
+	owners := make(map[string]*switchback.Consumer)
+	for consumer, received := range events.received {
+		var last uint64
+		next := make(map[string]int)
+		for _, event := range received {
+			if owner, ok := owners[event.Key]; ok && owner != consumer {
+				t.Fatalf("events with key %q were delivered to more than one consumer", event.Key)
+			}
+			owners[event.Key] = consumer
+
+			offset := event.Meta.GetOffset()
+			if offset <= last {
+				t.Fatalf("consumer received offset %d after %d", offset, last)
+			}
+			last = offset
+
+			// Events of a key from the same publisher are received in sequence
+			p, i := position(t, event)
+			id := fmt.Sprintf("%s/%d", event.Key, p)
+			if i < next[id] {
+				t.Fatalf("key %q event %d of publisher %d received out of order", event.Key, i, p)
+			}
+			next[id] = i + 1
+		}
+	}
+
+	if len(owners) != 16 {
+		t.Errorf("expected 16 keys to be delivered, got %d", len(owners))
+	}
+}
+
+// The events published together have contiguous offsets in each topic, even when other
+// publishers publish to the topics concurrently.
+func TestPublishAllContiguous(t *testing.T) {
+	pubsub := switchback.NewPubSub()
+	consumers := make([]*switchback.Consumer, 0, 2)
+	for _, topic := range []string{"ledger", "audit"} {
+		consumer, err := pubsub.Connect(&api.Subscription{Topic: topic})
+		if err != nil {
+			t.Fatalf("could not connect consumer: %s", err)
+		}
+		consumers = append(consumers, consumer)
+	}
+
+	events := collect(consumers...)
+	var wg sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				batch := make([]*api.Event, 0, 4)
+				for _, topic := range []string{"ledger", "audit", "ledger", "audit"} {
+					batch = append(batch, &api.Event{Topic: topic, Data: []byte(fmt.Sprintf("%d:%d", p, i))})
+				}
+				pubsub.PublishAll(context.Background(), batch)
+			}
+		}(p)
+	}
+	wg.Wait()
+	events.wait(t, pubsub, publishers*50*4)
+
+	for _, received := range events.received {
+		if len(received)%2 != 0 {
+			t.Fatalf("expected pairs of events, got %d events", len(received))
+		}
+
+		for i := 0; i < len(received); i += 2 {
+			first, second := received[i], received[i+1]
+			if string(first.Data) != string(second.Data) {
+				t.Fatalf("events of a batch are not contiguous: %q followed by %q", first.Data, second.Data)
+			}
+
+			if second.Meta.GetOffset() != first.Meta.GetOffset()+1 {
+				t.Fatalf("expected contiguous offsets, got %d and %d", first.Meta.GetOffset(), second.Meta.GetOffset())
+			}
+		}
+	}
+}
+
+// A consumer that is not reading its events does not block consumers from connecting to
+// or disconnecting from its group, or other topics from being subscribed to.
+func TestSlowConsumer(t *testing.T) {
+	pubsub := switchback.NewPubSub()
+	slow, err := pubsub.Connect(&api.Subscription{Topic: "slow", Group: "workers"})
+	if err != nil {
+		t.Fatalf("could not connect consumer: %s", err)
+	}
+
+	// Publish more events than the consumer can buffer so that the publisher blocks
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < 64; i++ {
+			pubsub.Publish(context.Background(), &api.Event{Topic: "slow", Key: "pinned", Data: []byte("event")})
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for len(slow.Events()) < cap(slow.Events()) {
+			time.Sleep(time.Millisecond)
+		}
+
+		if _, err := pubsub.Connect(&api.Subscription{Topic: "slow", Group: "workers"}); err != nil {
+			t.Errorf("could not connect consumer: %s", err)
+		}
+
+		if _, err := pubsub.Connect(&api.Subscription{Topic: "fast"}); err != nil {
+			t.Errorf("could not connect consumer: %s", err)
+		}
+		pubsub.Stats()
+		pubsub.Disconnect(slow)
+	}()
+
+	for _, ch := range []chan struct{}{done, published} {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("blocked by a slow consumer")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package switchback
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/bbengfort/switchback/pkg/schema"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RegisterSchema binds a new version of a schema to a topic; once registered all events
+// published to the topic are validated against the latest version of the schema.
+func (s *Server) RegisterSchema(ctx context.Context, in *api.Schema) (out *api.Schema, err error) {
+	if err = s.valid.Topic(in.Topic); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err = s.authorize(ctx, auth.Admin, in.Topic, ""); err != nil {
+		return nil, err
+	}
+
+	if out, err = s.schemas.Register(in); err != nil {
+		return nil, schemaError(err)
+	}
+
+	log.Info().Str("topic", out.Topic).Uint32("version", out.Version).Str("type", out.Type.String()).Msg("schema registered")
+	return out, nil
+}
+
+func (s *Server) GetSchema(ctx context.Context, in *api.SchemaQuery) (out *api.Schema, err error) {
+	if out, err = s.schemas.Get(in.Topic, in.Version); err != nil {
+		return nil, schemaError(err)
+	}
+	return out, nil
+}
+
+func (s *Server) ListSchemas(ctx context.Context, in *api.SchemaQuery) (out *api.SchemaList, err error) {
+	return &api.SchemaList{Schemas: s.schemas.List(in.Topic)}, nil
+}
+
+func schemaError(err error) error {
+	switch {
+	case errors.Is(err, schema.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, schema.ErrInvalidSchema), errors.Is(err, schema.ErrInvalidEvent):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, schema.ErrIncompatible):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
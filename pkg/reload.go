@@ -0,0 +1,104 @@
+package switchback
+
+import (
+	"reflect"
+
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Reload reads the config file and environment again and applies the settings that can
+// be changed without a restart: the log level, maintenance mode, rate limits, the ACL,
+// and the TLS certificates. Changes to any other settings are logged but are not applied
+// until the server is restarted. Existing connections and streams are not affected.
+func (s *Server) Reload() (err error) {
+	s.reloadmu.Lock()
+	defer s.reloadmu.Unlock()
+
+	log.Info().Str("path", s.loaded.Path()).Msg("reloading server")
+	var conf config.Config
+	if conf, err = s.loaded.Reload(); err != nil {
+		return err
+	}
+
+	// Load everything that can fail before applying any changes
+	var acl auth.ACL
+	if s.authn != nil {
+		if acl, err = loadACL(conf.Auth); err != nil {
+			return err
+		}
+	}
+
+	if s.certs != nil {
+		if err = s.certs.Reload(); err != nil {
+			return err
+		}
+	}
+
+	zerolog.SetGlobalLevel(conf.GetLogLevel())
+	s.limits.Update(conf.Limits)
+	s.pubsub.LimitDelivery(conf.Limits.GroupDelivery)
+
+	if s.authn != nil {
+		s.aclmu.Lock()
+		s.acl = acl
+		s.aclmu.Unlock()
+	}
+
+	// Only toggle maintenance mode if the config changed so that reloading does not undo
+	// a change made at runtime with the Maintenance RPC.
+	if conf.Maintenance != s.loaded.Maintenance {
+		s.SetMaintenance(conf.Maintenance)
+	}
+
+	if changed := restartRequired(s.loaded, conf); len(changed) > 0 {
+		log.Warn().Strs("settings", changed).Msg("changed settings require a restart to take effect")
+	}
+
+	s.loaded = conf
+	log.Info().Str("log_level", conf.GetLogLevel().String()).Bool("maintenance", s.InMaintenance()).Msg("server reloaded")
+	return nil
+}
+
+// loadACL returns the rules specified inline in the config or loads them from the ACL
+// file; nil is returned if neither is specified, which allows all principals access.
+func loadACL(conf config.AuthConfig) (auth.ACL, error) {
+	if conf.ACLFile != "" {
+		return auth.LoadACL(conf.ACLFile)
+	}
+
+	if len(conf.ACL) > 0 {
+		return auth.ACL(conf.ACL), nil
+	}
+	return nil, nil
+}
+
+// restartRequired returns the names of the top-level settings that differ between the
+// last loaded and reloaded config and that cannot be applied by Reload. Comparing with the
+// last loaded config rather than the running config means a change that requires a
+// restart is only reported by the reload that first sees it.
+func restartRequired(loaded, reloaded config.Config) []string {
+	// Clear the settings that are reloadable so they are not compared
+	for _, conf := range []*config.Config{&loaded, &reloaded} {
+		conf.LogLevel = 0
+		conf.Maintenance = false
+		conf.Limits = config.LimitsConfig{}
+		conf.Auth.ACLFile = ""
+		conf.Auth.ACL = nil
+	}
+
+	changed := make([]string, 0)
+	a, b := reflect.ValueOf(loaded), reflect.ValueOf(reloaded)
+	for i := 0; i < a.NumField(); i++ {
+		if !a.Type().Field(i).IsExported() {
+			continue
+		}
+
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, a.Type().Field(i).Name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,55 @@
+package switchback_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// A setting that requires a restart is only reported by the first reload that sees it.
+func TestReloadRestartRequired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "switchback.yaml")
+	write := func(addr string) {
+		if err := os.WriteFile(path, []byte("log_level: warn\nbind_addr: "+addr+"\n"), 0600); err != nil {
+			t.Fatalf("could not write config: %s", err)
+		}
+	}
+
+	write("127.0.0.1:7001")
+	conf, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("could not load config: %s", err)
+	}
+
+	srv, err := switchback.New(conf)
+	if err != nil {
+		t.Fatalf("could not create server: %s", err)
+	}
+
+	var logs bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&logs)
+	defer func() { log.Logger = logger }()
+
+	write("127.0.0.1:7002")
+	for i := 0; i < 3; i++ {
+		if err = srv.Reload(); err != nil {
+			t.Fatalf("could not reload server: %s", err)
+		}
+	}
+
+	if n := strings.Count(logs.String(), "require a restart"); n != 1 {
+		t.Errorf("expected the restart to be reported once, got %d times:\n%s", n, logs.String())
+	}
+
+	if !strings.Contains(logs.String(), "BindAddr") {
+		t.Errorf("expected the bind addr to be reported:\n%s", logs.String())
+	}
+}
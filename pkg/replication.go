@@ -0,0 +1,159 @@
+package switchback
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/cluster"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// setupCluster starts the cluster node and resumes consumer groups from its log.
+func (s *Server) setupCluster() (err error) {
+	if s.cluster, err = cluster.New(s.conf.Cluster, s.conf.Events.DedupWindow); err != nil {
+		return err
+	}
+
+	s.pubsub.UseLog(s.cluster)
+	s.health["cluster"] = s.clusterHealth
+	s.health["storage"] = s.storageHealth
+	return nil
+}
+
+// replicate runs until the cluster node is shutdown. While the node is the leader the
+// offsets acknowledged by its consumer groups are periodically committed to the cluster
+// and when it loses leadership its consumers are closed so that subscribers reconnect
+// to the new leader and resume from the last committed offsets.
+func (s *Server) replicate() {
+	ticker := time.NewTicker(s.conf.Cluster.CommitInterval)
+	defer ticker.Stop()
+
+	committed := make(map[cluster.Offset]struct{})
+	leadership := s.cluster.Leadership()
+	for {
+		select {
+		case leader, ok := <-leadership:
+			if !ok {
+				return
+			}
+
+			if !leader {
+				s.pubsub.Close()
+				s.txns.Abort()
+			}
+			committed = make(map[cluster.Offset]struct{})
+		case <-ticker.C:
+			if !s.cluster.IsLeader() {
+				continue
+			}
+
+			offsets := make([]cluster.Offset, 0)
+			for _, group := range s.pubsub.Stats() {
+				offset := cluster.Offset{Topic: group.Topic, Group: group.Group, Offset: group.Acked}
+				if _, ok := committed[offset]; ok || offset.Offset == 0 {
+					continue
+				}
+				offsets = append(offsets, offset)
+			}
+
+			if len(offsets) == 0 {
+				continue
+			}
+
+			if err := s.cluster.Commit(offsets); err != nil {
+				log.Warn().Err(err).Int("groups", len(offsets)).Msg("could not commit group offsets")
+				continue
+			}
+
+			for _, offset := range offsets {
+				committed[offset] = struct{}{}
+			}
+		}
+	}
+}
+
+// commitAcked commits the offset acknowledged by a disconnecting consumer's group so
+// that the group resumes from it even if the group is closed before the next commit.
+func (s *Server) commitAcked(consumer *Consumer) {
+	offset := cluster.Offset{Topic: consumer.topic, Group: consumer.group, Offset: consumer.Acked()}
+	if offset.Offset == 0 || !s.cluster.IsLeader() {
+		return
+	}
+
+	if err := s.cluster.Commit([]cluster.Offset{offset}); err != nil {
+		log.Warn().Err(err).Str("topic", offset.Topic).Str("group", offset.Group).Msg("could not commit group offset")
+	}
+}
+
+// appendLog appends the event to the replicated log, updating its metadata with the
+// offset and epoch assigned when it was committed.
+func (s *Server) appendLog(event *api.Event) (err error) {
+	var committed *api.Event
+	if committed, err = s.cluster.Publish(event); err != nil {
+		if errors.Is(err, ErrDuplicate) {
+			event.Meta = committed.Meta
+			return err
+		}
+
+		if errors.Is(err, cluster.ErrNotLeader) {
+			return s.notLeader()
+		}
+		log.Error().Err(err).Str("topic", event.Topic).Msg("could not replicate event")
+		return status.Error(codes.Unavailable, "could not replicate event to the cluster")
+	}
+
+	event.Meta = committed.Meta
+	return nil
+}
+
+// notLeader returns an Unavailable error that refers the client to the cluster leader.
+func (s *Server) notLeader() error {
+	id, endpoint := s.cluster.Leader()
+	if id == "" {
+		return status.Error(codes.Unavailable, "no cluster leader has been elected")
+	}
+	return status.Errorf(codes.Unavailable, "not the cluster leader, connect to %s at %s", id, endpoint)
+}
+
+func (s *Server) clusterHealth() *api.ComponentHealth {
+	id, _ := s.cluster.Leader()
+	if id == "" {
+		return &api.ComponentHealth{Name: "cluster", Status: HealthDegraded, Message: "no leader elected"}
+	}
+
+	role := "follower"
+	if s.cluster.IsLeader() {
+		role = "leader"
+	}
+	return &api.ComponentHealth{Name: "cluster", Status: HealthOK, Message: fmt.Sprintf("%s in term %d, leader is %s", role, s.cluster.Term(), id)}
+}
+
+// storageBytes returns the size of the raft log and snapshots on disk or, if the log is
+// kept in memory, the size of the events retained in it.
+func (s *Server) storageBytes() uint64 {
+	storage, err := s.cluster.Storage()
+	if err != nil {
+		log.Warn().Err(err).Msg("could not get cluster storage")
+	}
+
+	if storage.Durable {
+		return storage.DiskBytes
+	}
+	return storage.RetainedBytes
+}
+
+func (s *Server) storageHealth() *api.ComponentHealth {
+	storage, err := s.cluster.Storage()
+	if err != nil {
+		return &api.ComponentHealth{Name: "storage", Status: HealthUnavailable, Message: err.Error()}
+	}
+
+	if !storage.Durable {
+		return &api.ComponentHealth{Name: "storage", Status: HealthOK, Message: fmt.Sprintf("in-memory raft log, %d events retained (%d bytes)", storage.Retained, storage.RetainedBytes)}
+	}
+	return &api.ComponentHealth{Name: "storage", Status: HealthOK, Message: fmt.Sprintf("raft log in %s, %d bytes on disk, %d events retained", s.conf.Cluster.DataDir, storage.DiskBytes, storage.Retained)}
+}
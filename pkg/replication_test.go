@@ -0,0 +1,204 @@
+package switchback_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// serveCluster runs an embedded server that is the only node of a cluster and waits for
+// it to be elected leader.
+func serveCluster(t *testing.T, dataDir string) (*switchback.Server, api.SwitchbackClient) {
+	t.Helper()
+	addr := freeAddr(t)
+	srv := serveEmbedded(t, func(conf *config.Config) {
+		conf.Cluster.Enabled = true
+		conf.Cluster.NodeID = "n1"
+		conf.Cluster.BindAddr = addr
+		conf.Cluster.Peers = []string{"n1@" + addr}
+		conf.Cluster.DataDir = dataDir
+	})
+
+	client := embeddedClient(t, srv)
+	waitFor(t, func() bool {
+		state, err := client.Status(context.Background(), &api.HealthCheck{})
+		return err == nil && len(state.Members) == 1 && state.Members[0].Leader
+	})
+	return srv, client
+}
+
+func TestStorageStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		dataDir string
+		message string
+	}{
+		{"in-memory", "", "in-memory raft log, 10 events retained"},
+		{"durable", t.TempDir(), "bytes on disk, 10 events retained"},
+	}
+
+	for _, tc := range tests {
+		_, client := serveCluster(t, tc.dataDir)
+		pub, err := client.Publish(context.Background())
+		if err != nil {
+			t.Fatalf("%s: could not open publish stream: %s", tc.name, err)
+		}
+
+		for i := 0; i < 10; i++ {
+			if err = pub.Send(&api.Event{Topic: "stored", Data: []byte("event")}); err != nil {
+				t.Fatalf("%s: could not publish event: %s", tc.name, err)
+			}
+		}
+
+		if _, err = pub.CloseAndRecv(); err != nil {
+			t.Fatalf("%s: could not close publish stream: %s", tc.name, err)
+		}
+
+		state, err := client.Status(context.Background(), &api.HealthCheck{})
+		if err != nil {
+			t.Fatalf("%s: could not get status: %s", tc.name, err)
+		}
+
+		if state.StorageBytes == 0 {
+			t.Errorf("%s: expected storage bytes to be reported", tc.name)
+		}
+
+		var found bool
+		for _, component := range state.Components {
+			if component.Name == "storage" {
+				found = true
+				if component.Status != switchback.HealthOK || !strings.Contains(component.Message, tc.message) {
+					t.Errorf("%s: unexpected storage health %q: %s", tc.name, component.Status, component.Message)
+				}
+			}
+		}
+
+		if !found {
+			t.Errorf("%s: expected storage health in the service state", tc.name)
+		}
+	}
+}
+
+// serveNodes runs a cluster of n servers on local ports and returns clients connected
+// to the leader and to a follower once the leader has been elected.
+func serveNodes(t *testing.T, n int, configure func(*config.Config)) (leader, follower api.SwitchbackClient) {
+	t.Helper()
+	peers := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		peers = append(peers, fmt.Sprintf("n%d@%s", i, freeAddr(t)))
+	}
+
+	clients := make(map[string]api.SwitchbackClient, n)
+	for i, peer := range peers {
+		conf, err := config.New()
+		if err != nil {
+			t.Fatalf("could not load config: %s", err)
+		}
+
+		conf.LogLevel = config.LevelDecoder(zerolog.ErrorLevel)
+		conf.ShutdownTimeout = time.Second
+		conf.BindAddr = freeAddr(t)
+		conf.Cluster.Enabled = true
+		conf.Cluster.NodeID = fmt.Sprintf("n%d", i+1)
+		conf.Cluster.BindAddr = strings.SplitN(peer, "@", 2)[1]
+		conf.Cluster.Advertise = conf.BindAddr
+		conf.Cluster.Peers = peers
+		if configure != nil {
+			configure(&conf)
+		}
+
+		srv, err := switchback.New(conf)
+		if err != nil {
+			t.Fatalf("could not create server: %s", err)
+		}
+
+		go srv.Serve()
+		t.Cleanup(func() { srv.Shutdown() })
+
+		cc, err := grpc.Dial(conf.BindAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("could not dial server: %s", err)
+		}
+		t.Cleanup(func() { cc.Close() })
+		clients[conf.Cluster.NodeID] = api.NewSwitchbackClient(cc)
+	}
+
+	// Wait until every node knows the leader and its endpoint
+	var id string
+	for _, client := range clients {
+		client := client
+		waitFor(t, func() bool {
+			state, err := client.Status(context.Background(), &api.HealthCheck{})
+			if err != nil {
+				return false
+			}
+
+			for _, member := range state.Members {
+				if member.Leader && member.Endpoint != "" {
+					id = member.Id
+					return true
+				}
+			}
+			return false
+		})
+	}
+
+	for node, client := range clients {
+		if node == id {
+			leader = client
+		} else {
+			follower = client
+		}
+	}
+	return leader, follower
+}
+
+// publishAll publishes n events to the topic on a single stream and returns the reply.
+func publishAll(client api.SwitchbackClient, topic string, n int) (*api.ClosePublish, error) {
+	pub, err := client.Publish(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < n; i++ {
+		if err = pub.Send(&api.Event{Topic: topic, Data: []byte("event")}); err != nil {
+			break
+		}
+	}
+	return pub.CloseAndRecv()
+}
+
+// Events published to a follower are admitted by the follower and not again by the
+// leader, so each forwarded event uses exactly one token of the topic's rate limit.
+func TestForwardedAdmission(t *testing.T) {
+	const limit = 5
+	leader, follower := serveNodes(t, 2, func(conf *config.Config) {
+		conf.Limits.TopicEvents = limit
+	})
+
+	rep, err := publishAll(follower, "forwarded", limit)
+	if err != nil || rep.Events != limit {
+		t.Fatalf("could not publish to follower: %v (%d events)", err, rep.GetEvents())
+	}
+
+	// The leader has not used any of its tokens for the forwarded events
+	if rep, err = publishAll(leader, "forwarded", limit); err != nil || rep.Events != limit {
+		t.Fatalf("expected the leader to allow %d events: %v (%d events)", limit, err, rep.GetEvents())
+	}
+
+	// The follower used one token for each of the forwarded events
+	if _, err = publishAll(follower, "forwarded", limit); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected the follower to rate limit the topic, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// schemaURL identifies the in-memory schema document so that errors reference it rather
+// than a relative file path.
+const schemaURL = "mem://topic/schema.json"
+
+// jsonSchema validates events that are JSON documents.
+type jsonSchema struct {
+	schema *jsonschema.Schema
+	doc    map[string]interface{}
+}
+
+func compileJSON(definition []byte) (_ *jsonSchema, err error) {
+	s := &jsonSchema{}
+	if err = json.Unmarshal(definition, &s.doc); err != nil {
+		return nil, fmt.Errorf("%w: could not parse json schema: %s", ErrInvalidSchema, err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err = compiler.AddResource(schemaURL, bytes.NewReader(definition)); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err)
+	}
+
+	if s.schema, err = compiler.Compile(schemaURL); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err)
+	}
+	return s, nil
+}
+
+func (s *jsonSchema) Validate(data []byte) (err error) {
+	var doc interface{}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("could not parse json: %s", err)
+	}
+	return s.schema.Validate(doc)
+}
+
+// jsonReadable checks that documents valid against the writer schema are also valid
+// against the reader schema. This is a structural comparison of the type, required,
+// properties, additionalProperties, items, and enum keywords rather than a complete
+// proof of containment.
+func jsonReadable(reader, writer map[string]interface{}, path string) error {
+	if path == "" {
+		path = "$"
+	}
+
+	rtypes, wtypes := jsonTypes(reader), jsonTypes(writer)
+	if len(rtypes) > 0 {
+		if len(wtypes) == 0 {
+			return fmt.Errorf("%s is restricted to type %v", path, keys(rtypes))
+		}
+
+		for t := range wtypes {
+			if !rtypes[t] && !(t == "integer" && rtypes["number"]) {
+				return fmt.Errorf("%s changed type from %v to %v", path, keys(wtypes), keys(rtypes))
+			}
+		}
+	}
+
+	if renum, ok := reader["enum"].([]interface{}); ok {
+		wenum, ok := writer["enum"].([]interface{})
+		if !ok {
+			return fmt.Errorf("%s is restricted to an enum", path)
+		}
+
+		for _, w := range wenum {
+			if !contains(renum, w) {
+				return fmt.Errorf("%s enum does not contain %v", path, w)
+			}
+		}
+	}
+
+	rprops, _ := reader["properties"].(map[string]interface{})
+	wprops, _ := writer["properties"].(map[string]interface{})
+	wrequired := make(map[string]bool)
+	if required, ok := writer["required"].([]interface{}); ok {
+		for _, name := range required {
+			wrequired[fmt.Sprint(name)] = true
+		}
+	}
+
+	if required, ok := reader["required"].([]interface{}); ok {
+		for _, name := range required {
+			if !wrequired[fmt.Sprint(name)] {
+				return fmt.Errorf("%s.%s is required but may not be present", path, name)
+			}
+		}
+	}
+
+	if additional, ok := reader["additionalProperties"].(bool); ok && !additional {
+		for name := range wprops {
+			if _, ok := rprops[name]; !ok {
+				return fmt.Errorf("%s.%s is not allowed by additionalProperties", path, name)
+			}
+		}
+	}
+
+	for name, rprop := range rprops {
+		rp, rok := rprop.(map[string]interface{})
+		wp, wok := wprops[name].(map[string]interface{})
+		if rok && wok {
+			if err := jsonReadable(rp, wp, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	ritems, rok := reader["items"].(map[string]interface{})
+	witems, wok := writer["items"].(map[string]interface{})
+	if rok && wok {
+		if err := jsonReadable(ritems, witems, path+"[]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonTypes(schema map[string]interface{}) map[string]bool {
+	types := make(map[string]bool)
+	switch t := schema["type"].(type) {
+	case string:
+		types[t] = true
+	case []interface{}:
+		for _, v := range t {
+			types[fmt.Sprint(v)] = true
+		}
+	}
+	return types
+}
+
+func keys(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	return out
+}
+
+func contains(values []interface{}, val interface{}) bool {
+	target, _ := json.Marshal(val)
+	for _, v := range values {
+		if data, _ := json.Marshal(v); bytes.Equal(data, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protoSchema validates events that are serialized protocol buffer messages.
+type protoSchema struct {
+	desc protoreflect.MessageDescriptor
+}
+
+// compileProto resolves the message descriptor from a serialized FileDescriptorSet, e.g.
+// one created with protoc --include_imports --descriptor_set_out.
+func compileProto(definition []byte, message string) (_ *protoSchema, err error) {
+	if message == "" {
+		return nil, fmt.Errorf("%w: a protobuf message name is required", ErrInvalidSchema)
+	}
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err = proto.Unmarshal(definition, fds); err != nil {
+		return nil, fmt.Errorf("%w: could not parse file descriptor set: %s", ErrInvalidSchema, err)
+	}
+
+	var files *protoregistry.Files
+	if files, err = protodesc.NewFiles(fds); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchema, err)
+	}
+
+	var desc protoreflect.Descriptor
+	if desc, err = files.FindDescriptorByName(protoreflect.FullName(message)); err != nil {
+		return nil, fmt.Errorf("%w: could not find message %q: %s", ErrInvalidSchema, message, err)
+	}
+
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not a message", ErrInvalidSchema, message)
+	}
+	return &protoSchema{desc: md}, nil
+}
+
+func (p *protoSchema) Validate(data []byte) (err error) {
+	msg := dynamicpb.NewMessage(p.desc)
+	if err = proto.Unmarshal(data, msg); err != nil {
+		return err
+	}
+	return proto.CheckInitialized(msg)
+}
+
+// protoReadable checks that data serialized with the writer message can be parsed by
+// the reader message: fields with the same number must have the same type and
+// cardinality and any required fields of the reader must be written by the writer.
+func protoReadable(reader, writer protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) error {
+	if seen[reader.FullName()] {
+		return nil
+	}
+	seen[reader.FullName()] = true
+
+	rfields, wfields := reader.Fields(), writer.Fields()
+	for i := 0; i < rfields.Len(); i++ {
+		rf := rfields.Get(i)
+		wf := wfields.ByNumber(rf.Number())
+		if wf == nil {
+			if rf.Cardinality() == protoreflect.Required {
+				return fmt.Errorf("required field %s is not in the previous schema", rf.FullName())
+			}
+			continue
+		}
+
+		if rf.Cardinality() != wf.Cardinality() && (rf.Cardinality() == protoreflect.Repeated || wf.Cardinality() == protoreflect.Repeated) {
+			return fmt.Errorf("field %s changed cardinality from %s to %s", rf.FullName(), wf.Cardinality(), rf.Cardinality())
+		}
+
+		if rf.IsMap() != wf.IsMap() || !sameKind(rf.Kind(), wf.Kind()) {
+			return fmt.Errorf("field %s changed type from %s to %s", rf.FullName(), wf.Kind(), rf.Kind())
+		}
+
+		if rf.Kind() == protoreflect.MessageKind || rf.Kind() == protoreflect.GroupKind {
+			if err := protoReadable(rf.Message(), wf.Message(), seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sameKind returns true if the kinds are identical or are wire compatible varints.
+func sameKind(a, b protoreflect.Kind) bool {
+	if a == b {
+		return true
+	}
+
+	varints := map[protoreflect.Kind]bool{
+		protoreflect.Int32Kind: true, protoreflect.Int64Kind: true,
+		protoreflect.Uint32Kind: true, protoreflect.Uint64Kind: true,
+		protoreflect.BoolKind: true, protoreflect.EnumKind: true,
+	}
+	return varints[a] && varints[b]
+}
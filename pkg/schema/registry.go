@@ -0,0 +1,191 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	ErrNotFound      = errors.New("schema not found")
+	ErrInvalidSchema = errors.New("invalid schema")
+	ErrIncompatible  = errors.New("schema is incompatible with the previous version")
+	ErrInvalidEvent  = errors.New("event does not match the topic schema")
+)
+
+// Registry binds topics to versioned schemas. Each time a schema is registered for a
+// topic it is checked against the latest version using the compatibility level of the
+// latest version, so that the contract of a topic cannot be broken by a producer.
+type Registry struct {
+	sync.RWMutex
+	topics map[string][]*entry
+}
+
+// Validator checks that an event payload conforms to a schema.
+type Validator interface {
+	Validate(data []byte) error
+}
+
+type entry struct {
+	schema    *api.Schema
+	validator Validator
+}
+
+func NewRegistry() *Registry {
+	return &Registry{topics: make(map[string][]*entry)}
+}
+
+// Register compiles the schema, checks its compatibility with the latest version of the
+// schema for the topic and stores it as the new latest version.
+func (r *Registry) Register(in *api.Schema) (_ *api.Schema, err error) {
+	if in.Topic == "" {
+		return nil, fmt.Errorf("%w: a topic is required", ErrInvalidSchema)
+	}
+
+	e := &entry{
+		schema: &api.Schema{
+			Topic:         in.Topic,
+			Type:          in.Type,
+			Definition:    in.Definition,
+			Message:       in.Message,
+			Compatibility: in.Compatibility,
+		},
+	}
+
+	switch in.Type {
+	case api.SchemaType_PROTOBUF:
+		var p *protoSchema
+		if p, err = compileProto(in.Definition, in.Message); err != nil {
+			return nil, err
+		}
+		e.validator = p
+	case api.SchemaType_JSON_SCHEMA:
+		var j *jsonSchema
+		if j, err = compileJSON(in.Definition); err != nil {
+			return nil, err
+		}
+		e.validator = j
+	default:
+		return nil, fmt.Errorf("%w: unknown schema type %q", ErrInvalidSchema, in.Type)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	versions := r.topics[in.Topic]
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		if err = compatible(latest, e); err != nil {
+			return nil, err
+		}
+	}
+
+	e.schema.Version = uint32(len(versions) + 1)
+	e.schema.Created = time.Now().Format(time.RFC3339)
+	r.topics[in.Topic] = append(versions, e)
+	return e.schema, nil
+}
+
+// Get returns the specified version of the schema for the topic or the latest version
+// if version is zero.
+func (r *Registry) Get(topic string, version uint32) (*api.Schema, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	versions := r.topics[topic]
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	if version == 0 {
+		return versions[len(versions)-1].schema, nil
+	}
+
+	if int(version) > len(versions) {
+		return nil, ErrNotFound
+	}
+	return versions[version-1].schema, nil
+}
+
+// List returns all versions of the schema for the topic, or the latest version of the
+// schema for every topic if topic is empty.
+func (r *Registry) List(topic string) []*api.Schema {
+	r.RLock()
+	defer r.RUnlock()
+
+	schemas := make([]*api.Schema, 0)
+	if topic != "" {
+		for _, e := range r.topics[topic] {
+			schemas = append(schemas, e.schema)
+		}
+		return schemas
+	}
+
+	for _, versions := range r.topics {
+		schemas = append(schemas, versions[len(versions)-1].schema)
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Topic < schemas[j].Topic })
+	return schemas
+}
+
+// Validate checks the event data against the latest schema of the topic. Topics that do
+// not have a schema accept any data.
+func (r *Registry) Validate(topic string, data []byte) (err error) {
+	r.RLock()
+	versions := r.topics[topic]
+	r.RUnlock()
+
+	if len(versions) == 0 {
+		return nil
+	}
+
+	latest := versions[len(versions)-1]
+	if err = latest.validator.Validate(data); err != nil {
+		return fmt.Errorf("%w (version %d): %s", ErrInvalidEvent, latest.schema.Version, err)
+	}
+	return nil
+}
+
+// compatible checks that the next schema can be registered after the previous schema.
+// Backward compatibility means the next schema can read data written with the previous
+// schema; forward compatibility means the previous schema can read the next schema's data.
+func compatible(prev, next *entry) (err error) {
+	mode := prev.schema.Compatibility
+	if mode == api.Compatibility_NONE {
+		return nil
+	}
+
+	if prev.schema.Type != next.schema.Type {
+		return fmt.Errorf("%w: cannot change schema type from %s to %s", ErrIncompatible, prev.schema.Type, next.schema.Type)
+	}
+
+	var readable func(reader, writer Validator) error
+	switch prev.schema.Type {
+	case api.SchemaType_PROTOBUF:
+		readable = func(reader, writer Validator) error {
+			return protoReadable(reader.(*protoSchema).desc, writer.(*protoSchema).desc, make(map[protoreflect.FullName]bool))
+		}
+	case api.SchemaType_JSON_SCHEMA:
+		readable = func(reader, writer Validator) error {
+			return jsonReadable(reader.(*jsonSchema).doc, writer.(*jsonSchema).doc, "")
+		}
+	}
+
+	if mode == api.Compatibility_BACKWARD || mode == api.Compatibility_FULL {
+		if err = readable(next.validator, prev.validator); err != nil {
+			return fmt.Errorf("%w (backward): %s", ErrIncompatible, err)
+		}
+	}
+
+	if mode == api.Compatibility_FORWARD || mode == api.Compatibility_FULL {
+		if err = readable(prev.validator, next.validator); err != nil {
+			return fmt.Errorf("%w (forward): %s", ErrIncompatible, err)
+		}
+	}
+	return nil
+}
@@ -2,21 +2,38 @@ package switchback
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/bbengfort/switchback/pkg/cluster"
 	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/bbengfort/switchback/pkg/dedup"
+	"github.com/bbengfort/switchback/pkg/schema"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func init() {
@@ -27,13 +44,51 @@ func init() {
 	log.Logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
 }
 
+// shutdownGrace is the additional time given to streams and servers to stop after the
+// shutdown timeout has expired and they have been told to close.
+const shutdownGrace = 5 * time.Second
+
 type Server struct {
 	api.UnimplementedSwitchbackServer
-	conf    config.Config
-	srv     *grpc.Server
-	pubsub  *PubSub
-	echan   chan error
-	started time.Time
+	conf      config.Config
+	srv       *grpc.Server
+	pubsub    *PubSub
+	certs     *CertReloader
+	authn     auth.Authenticator
+	aclmu     sync.RWMutex
+	acl       auth.ACL
+	limits    *RateLimiter
+	valid     *Validator
+	schemas   *schema.Registry
+	metrics   *http.Server
+	gateway   *http.Server
+	tracing   *sdktrace.TracerProvider
+	cluster   *cluster.Node
+	peermu    sync.Mutex
+	peers     map[string]*grpc.ClientConn
+	mirrors   *mirrors
+	webhooks  *webhooks
+	mqtt      *mqttBroker
+	txns      *transactions
+	health    map[string]HealthCheck
+	healthsrv *health.Server
+	pubrate   *meter
+	subrate   *meter
+	pubs      int32
+	subs      int32
+	maint     int32
+	running   int32
+	drainmu   sync.Mutex
+	drain     *drainer
+	open      int
+	closed    *sync.Cond
+	reloadmu  sync.Mutex
+	loaded    config.Config
+	echan     chan error
+	started   time.Time
+	bufnet    *bufconn.Listener
+	connmu    sync.Mutex
+	conns     []*grpc.ClientConn
 }
 
 func New(conf config.Config) (s *Server, err error) {
@@ -52,27 +107,116 @@ func New(conf config.Config) (s *Server, err error) {
 	}
 
 	// Create the server and prepare to serve
-	s = &Server{conf: conf, echan: make(chan error, 1)}
-	s.pubsub = &PubSub{
-		topics: make(map[string]map[string]*Group),
+	s = &Server{conf: conf, loaded: conf, echan: make(chan error, 1), drain: newDrainer()}
+	s.closed = sync.NewCond(&s.drainmu)
+	if conf.Maintenance {
+		s.maint = 1
+	}
+	s.pubsub = NewPubSub()
+	s.pubrate, s.subrate = newMeter(), newMeter()
+	s.health = map[string]HealthCheck{
+		"pubsub":    func() *api.ComponentHealth { return &api.ComponentHealth{Name: "pubsub", Status: HealthOK} },
+		"storage":   disabled("storage"),
+		"cluster":   disabled("cluster"),
+		"scheduler": disabled("scheduler"),
+	}
+	s.pubsub.LimitDelivery(conf.Limits.GroupDelivery)
+	s.pubsub.Deduplicate(dedup.New(conf.Events.DedupWindow))
+	s.txns = newTransactions()
+	s.limits = NewRateLimiter(conf.Limits)
+	s.schemas = schema.NewRegistry()
+	if s.valid, err = NewValidator(conf.Events); err != nil {
+		return nil, err
+	}
+
+	if conf.Metrics.Enabled {
+		if err = s.setupMetrics(); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Tracing.Enabled {
+		if err = s.setupTracing(); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Auth.Enabled {
+		if err = s.setupAuth(); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Cluster.Enabled {
+		if err = s.setupCluster(); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.Mirror.Enabled {
+		if err = s.setupMirrors(); err != nil {
+			return nil, err
+		}
 	}
 
-	s.srv = grpc.NewServer(s.StreamInterceptors(), s.UnaryInterceptors())
+	if conf.Webhooks.Enabled {
+		if err = s.setupWebhooks(); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := []grpc.ServerOption{s.StreamInterceptors(), s.UnaryInterceptors(), grpc.MaxRecvMsgSize(s.valid.MaxBatchMessageSize())}
+	if conf.TLS.Enabled() {
+		if s.certs, err = NewCertReloader(conf.TLS); err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(s.certs.Credentials()))
+	}
+
+	if conf.Gateway.Enabled {
+		s.setupGateway()
+	}
+
+	if conf.MQTT.Enabled {
+		s.setupMQTT()
+	}
+
+	s.srv = grpc.NewServer(opts...)
 	api.RegisterSwitchbackServer(s.srv, s)
+
+	if conf.Health {
+		s.healthsrv = newHealthServer()
+		healthpb.RegisterHealthServer(s.srv, s.healthsrv)
+	}
+
+	if conf.Reflection {
+		reflection.Register(s.srv)
+	}
 	return s, nil
 }
 
 func (s *Server) Serve() (err error) {
 	// Catch OS signals for graceful shutdowns
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-quit
 		s.echan <- s.Shutdown()
 	}()
 
+	// Reload the server when a SIGHUP is received
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := s.Reload(); err != nil {
+				log.Error().Err(err).Msg("could not reload server")
+			}
+		}
+	}()
+
 	// Run management routines only if we're not in maintenance mode
-	if s.conf.Maintenance {
+	if s.InMaintenance() {
 		log.Warn().Msg("starting server in maintenance mode")
 	}
 
@@ -82,10 +226,35 @@ func (s *Server) Serve() (err error) {
 		return fmt.Errorf("could not listen on %q", s.conf.BindAddr)
 	}
 
-	// Run the server
-	go s.Run(sock)
+	// Run the server; the start time is set first since Status may be called as soon as
+	// the listeners accept requests
 	s.started = time.Now()
-	log.Info().Str("listen", s.conf.BindAddr).Str("version", Version()).Msg("switchback server started")
+	go s.Run(sock)
+	s.setServing(true)
+	if s.metrics != nil {
+		go s.serveMetrics()
+	}
+
+	if s.gateway != nil {
+		go s.serveGateway()
+	}
+
+	if s.mqtt != nil {
+		go s.serveMQTT()
+	}
+
+	if s.cluster != nil {
+		go s.replicate()
+	}
+
+	if s.mirrors != nil {
+		s.startMirrors()
+	}
+
+	if s.webhooks != nil {
+		s.startWebhooks()
+	}
+	log.Info().Str("listen", s.conf.BindAddr).Str("version", Version()).Bool("tls", s.conf.TLS.Enabled()).Msg("switchback server started")
 
 	// Listen for any errors that might have occurred and wait for all go routines to finish
 	if err = <-s.echan; err != nil {
@@ -101,57 +270,508 @@ func (s *Server) Run(sock net.Listener) {
 	}
 }
 
+// setupAuth creates the authenticators enabled by the config and loads the ACL.
+func (s *Server) setupAuth() (err error) {
+	chain := make(auth.Chain, 0, 3)
+	if len(s.conf.Auth.APIKeys) > 0 {
+		chain = append(chain, auth.APIKeys(s.conf.Auth.APIKeys))
+	}
+
+	if s.conf.Auth.JWKSFile != "" {
+		var jwt *auth.JWT
+		if jwt, err = auth.NewJWT(s.conf.Auth.JWKSFile, s.conf.Auth.Issuer, s.conf.Auth.Audience); err != nil {
+			return err
+		}
+		chain = append(chain, jwt)
+	}
+
+	if s.conf.Auth.MTLS {
+		chain = append(chain, auth.MTLS{})
+	}
+	s.authn = chain
+
+	if s.acl, err = loadACL(s.conf.Auth); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Shutdown gracefully stops the server: new requests are refused, publishers are sent a
+// final ClosePublish, subscribers are sent the events already buffered for them, and
+// then all consumers are closed. Streams that have not finished draining when the
+// shutdown timeout expires are closed, and if the server has still not stopped after a
+// short grace period, it is forcibly stopped.
 func (s *Server) Shutdown() (err error) {
-	log.Info().Msg("gracefully shutting down")
-	s.srv.GracefulStop()
+	log.Info().Dur("timeout", s.conf.ShutdownTimeout).Msg("gracefully shutting down")
+	s.setServing(false)
+	s.SetMaintenance(true)
+	if s.healthsrv != nil {
+		// Report not serving to health checks and watchers before draining connections
+		s.healthsrv.Shutdown()
+	}
+
+	if s.mirrors != nil {
+		if err = s.stopMirrors(); err != nil {
+			log.Warn().Err(err).Msg("could not save mirror checkpoints")
+		}
+	}
+
+	if s.webhooks != nil {
+		s.stopWebhooks()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.conf.ShutdownTimeout+shutdownGrace)
+	defer cancel()
+
+	if err = s.Drain(ctx, s.conf.ShutdownTimeout); err != nil {
+		log.Warn().Err(err).Msg("could not drain all streams")
+	}
+
+	if s.mqtt != nil {
+		s.stopMQTT()
+	}
+	s.pubsub.Close()
+
+	if s.cluster != nil {
+		if err = s.cluster.Shutdown(); err != nil {
+			log.Warn().Err(err).Msg("could not shutdown cluster node")
+		}
+	}
+
+	s.connmu.Lock()
+	for _, cc := range s.conns {
+		cc.Close()
+	}
+	s.connmu.Unlock()
+
+	s.peermu.Lock()
+	for _, cc := range s.peers {
+		cc.Close()
+	}
+	s.peermu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn().Msg("graceful stop timed out, forcing shutdown")
+		s.srv.Stop()
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+
+	if s.metrics != nil {
+		if err = s.metrics.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	if s.gateway != nil {
+		if err = s.gateway.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	if s.tracing != nil {
+		if err = s.tracing.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (s *Server) Publish(stream api.Switchback_PublishServer) (err error) {
+func (s *Server) Publish(stream api.Switchback_PublishServer) error {
+	return s.publishStream(stream, func() ([]*api.Event, error) {
+		event, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return []*api.Event{event}, nil
+	})
+}
+
+// publishServer is the server side of a Publish or PublishBatch stream.
+type publishServer interface {
+	Context() context.Context
+	SendAndClose(*api.ClosePublish) error
+}
+
+// publishStream publishes the events read from the stream by next until the publisher
+// closes the stream or the server drains.
+func (s *Server) publishStream(stream publishServer, next func() ([]*api.Event, error)) (err error) {
 	log.Info().Str("id", uuid.New().String()).Msg("publisher connected")
+	atomic.AddInt32(&s.pubs, 1)
+	defer atomic.AddInt32(&s.pubs, -1)
+
+	events, errc := recv(stream.Context(), next)
+	if s.cluster != nil && !s.cluster.IsLeader() {
+		return s.forwardPublish(stream, events, errc)
+	}
+
+	ctx := s.forwardedContext(stream.Context())
+	principal := principalName(ctx)
+	drain := s.drainer()
+
+	// Report the number of events published and the offset of the last event when the
+	// publisher closes the stream or the server is draining. Duplicates from idempotent
+	// producers are not published but report the offset originally assigned to them.
+	reply := &api.ClosePublish{}
 	for {
-		var event *api.Event
-		if event, err = stream.Recv(); err != nil {
+		select {
+		case <-drain.start:
+			log.Debug().Uint64("events", reply.Events).Msg("closing publisher to drain server")
+			return stream.SendAndClose(reply)
+		case err = <-errc:
 			if err != io.EOF {
 				log.Error().Err(err).Msg("could not recv event from stream")
 				return err
 			}
-			return nil
+			return stream.SendAndClose(reply)
+		case event := <-events:
+			if err = s.publishReply(ctx, principal, event, reply); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// publishReply publishes the event and records it in the reply to the publisher.
+func (s *Server) publishReply(ctx context.Context, principal string, event *api.Event, reply *api.ClosePublish) (err error) {
+	if err = s.publish(ctx, principal, event); err != nil {
+		if !errors.Is(err, ErrDuplicate) {
+			return err
+		}
+		reply.Duplicates++
+		reply.TopicOffset = event.Meta.GetOffset()
+		return nil
+	}
+
+	reply.Events++
+	if event.Transaction == "" {
+		reply.TopicOffset = event.Meta.GetOffset()
+	}
+	return nil
+}
+
+// recv reads events from the publish stream in a separate go routine so that the
+// publish handler can also respond to the server draining while waiting for events.
+// Batches are flattened so that their events are published in order.
+func recv(ctx context.Context, next func() ([]*api.Event, error)) (<-chan *api.Event, <-chan error) {
+	events := make(chan *api.Event)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			batch, err := next()
+			if err != nil {
+				errc <- err
+				return
+			}
+
+			for _, event := range batch {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, errc
+}
+
+// publish validates, authorizes, and rate limits a single event received from the
+// principal before routing it to the topic's consumer groups. Errors returned are gRPC
+// status errors that close the publisher's stream.
+func (s *Server) publish(ctx context.Context, principal string, event *api.Event) (err error) {
+	var span trace.Span
+	ctx, span = tracer.Start(ExtractTrace(ctx, event), "publish "+event.Topic,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(eventAttributes(event)...),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	// Replace the producer's trace context so consumers link to the server span; this is
+	// done before validation so the attributes it adds count toward the limits.
+	InjectTrace(ctx, event)
+	if err = s.valid.Event(event); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err = s.admit(ctx, principal, event); err != nil {
+		return err
+	}
+
+	if err = s.schemas.Validate(event.Topic, event.Data); err != nil {
+		return schemaError(err)
+	}
+
+	if event.Meta == nil {
+		event.Meta = &api.Metadata{}
+	}
+	event.Meta.Source = s.conf.Name
+
+	// Events published in a transaction are held until the transaction is committed
+	if event.Transaction != "" {
+		return s.txns.add(ctx, event, s.conf.Events.MaxTransactionEvents)
+	}
+	return s.route(ctx, event)
+}
+
+// check validates and authorizes an event without publishing it so that the events of a
+// request can be checked before any of them are published.
+func (s *Server) check(ctx context.Context, event *api.Event) (err error) {
+	if err = s.valid.Event(event); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err = s.authorize(ctx, auth.Publish, event.Topic, ""); err != nil {
+		return err
+	}
+
+	if err = s.schemas.Validate(event.Topic, event.Data); err != nil {
+		return schemaError(err)
+	}
+	return nil
+}
+
+// admit checks that the principal may publish the event and takes the event from the
+// principal's and topic's rate limits. Followers admit events before they are forwarded
+// to the leader since the leader may not see the client's identity, e.g. with mutual TLS
+// the forwarded stream is authenticated with the follower's certificate; the leader does
+// not admit them again so that each event is only counted once.
+func (s *Server) admit(ctx context.Context, principal string, event *api.Event) (err error) {
+	if forwarded(ctx) {
+		return nil
+	}
+
+	if err = s.authorize(ctx, auth.Publish, event.Topic, ""); err != nil {
+		return err
+	}
+
+	if err = s.limits.Publish(ctx, principal, event.Topic, len(event.Data)); err != nil {
+		if errors.Is(err, ErrRateLimited) {
+			log.Warn().Str("principal", principal).Str("topic", event.Topic).Msg("publisher rate limited")
+			return status.Errorf(codes.ResourceExhausted, "rate limit exceeded for topic %q", event.Topic)
+		}
+
+		if errors.Is(err, ErrExceedsRate) {
+			return status.Errorf(codes.InvalidArgument, "event of %d bytes can never be published to topic %q: %s", len(event.Data), event.Topic, err)
 		}
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
 
-		if err = s.pubsub.Publish(event); err != nil {
-			log.Error().Err(err).Msg("could not publish event")
+// route assigns the event its offset and sends it to the topic's consumer groups. If
+// the server is part of a cluster, the event is first appended to the replicated log.
+// ErrDuplicate is returned if the event was already published by its producer.
+func (s *Server) route(ctx context.Context, event *api.Event) (err error) {
+	if s.cluster != nil {
+		// The replicated log assigns the offset and epoch of the event; the topic is
+		// sequenced so that concurrent publishers route events in offset order.
+		unlock := s.pubsub.sequence(event.Topic)
+		defer unlock()
+		if err = s.appendLog(event); err != nil {
+			return s.duplicate(event, err)
 		}
+
+		if err := s.pubsub.Route(ctx, event); err != nil {
+			log.Error().Err(err).Msg("could not route event")
+		}
+	} else if err = s.pubsub.Publish(ctx, event); err != nil {
+		if errors.Is(err, ErrDuplicate) {
+			return s.duplicate(event, err)
+		}
+		log.Error().Err(err).Msg("could not publish event")
+	}
+	s.pubrate.Mark(1)
+	return nil
+}
+
+// duplicate records events dropped by deduplication and passes other errors through.
+func (s *Server) duplicate(event *api.Event, err error) error {
+	if errors.Is(err, ErrDuplicate) {
+		eventsDropped.WithLabelValues(event.Topic, "duplicate").Inc()
+		log.Debug().Str("topic", event.Topic).Str("producer", event.Producer).Uint64("sequence", event.Sequence).Uint64("offset", event.Meta.GetOffset()).Msg("duplicate event dropped")
 	}
+	return err
 }
 
-func (s *Server) Subscribe(in *api.Subscription, stream api.Switchback_SubscribeServer) (err error) {
-	var events <-chan *api.Event
-	if events, err = s.pubsub.Connect(in); err != nil {
+func (s *Server) Subscribe(in *api.Subscription, stream api.Switchback_SubscribeServer) error {
+	return s.subscribe(in, eventStream{stream})
+}
+
+// subscribe connects a consumer to the subscription's group and sends the events queued
+// for it to the subscriber, batching them if the subscriber receives batches.
+func (s *Server) subscribe(in *api.Subscription, stream subscriber) (err error) {
+	if err = s.valid.Topic(in.Topic); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err = s.authorize(stream.Context(), auth.Subscribe, in.Topic, in.Group); err != nil {
+		return err
+	}
+
+	if s.cluster != nil && !s.cluster.IsLeader() {
+		return s.forwardSubscribe(in, stream)
+	}
+
+	var consumer *Consumer
+	if consumer, err = s.pubsub.Connect(in); err != nil {
 		return status.Error(codes.FailedPrecondition, err.Error())
 	}
+	defer s.pubsub.Disconnect(consumer)
+	if s.cluster != nil {
+		defer s.commitAcked(consumer)
+	}
+
+	atomic.AddInt32(&s.subs, 1)
+	defer atomic.AddInt32(&s.subs, -1)
 
-	for event := range events {
-		if err = stream.Send(event); err != nil {
-			// TODO: close the stream on error
+	batch := stream.batcher(s.valid.MaxMessageSize())
+	defer batch.stop()
+
+	flush := func() error {
+		if err := s.deliver(stream, consumer, batch.take()); err != nil {
 			if err != io.EOF {
 				log.Error().Err(err).Msg("could not send event to stream")
-				return err
 			}
+			return err
+		}
+		return nil
+	}
+
+	// When the server drains, stop routing events to the consumer but continue to send
+	// the events already queued for it until the events channel is closed.
+	drain := s.drainer()
+	start, halt := drain.start, drain.halt
+	events := consumer.Events()
+	for {
+		select {
+		case <-stream.Context().Done():
 			return nil
+		case <-halt:
+			return status.Error(codes.Unavailable, "the switchback server is draining")
+		case <-start:
+			s.pubsub.Disconnect(consumer)
+			start = nil
+		case <-batch.lingering():
+			if err = flush(); err != nil {
+				return ignoreEOF(err)
+			}
+		case event, ok := <-events:
+			if !ok {
+				if err = flush(); err != nil {
+					return ignoreEOF(err)
+				}
+
+				if s.cluster != nil && !s.cluster.IsLeader() {
+					// Consumers are closed when leadership is lost; clients reconnect to the leader
+					return s.notLeader()
+				}
+				return nil
+			}
+
+			if batch.overflows(event) {
+				if err = flush(); err != nil {
+					return ignoreEOF(err)
+				}
+			}
+
+			if batch.add(event, len(events)) {
+				if err = flush(); err != nil {
+					return ignoreEOF(err)
+				}
+			}
+		}
+	}
+}
+
+// deliver sends a batch of events to a subscriber, waiting for the group delivery limit
+// for each event, and acknowledges the last event in the batch once it has been sent.
+func (s *Server) deliver(stream subscriber, consumer *Consumer, events []*api.Event) (err error) {
+	if len(events) == 0 {
+		return nil
+	}
+
+	for range events {
+		if err = consumer.Wait(stream.Context()); err != nil {
+			return io.EOF
 		}
 	}
+
+	spans := make([]trace.Span, 0, len(events))
+	for _, event := range events {
+		_, span := startDelivery(stream.Context(), event, consumer.group)
+		spans = append(spans, span)
+	}
+
+	err = stream.send(events)
+	for _, span := range spans {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	consumer.Ack(events[len(events)-1].Meta.GetOffset())
+	s.subrate.Mark(uint64(len(events)))
+	eventsDelivered.WithLabelValues(consumer.topic, consumer.label()).Add(float64(len(events)))
 	return nil
 }
 
 func (s *Server) Status(ctx context.Context, in *api.HealthCheck) (out *api.ServiceState, err error) {
+	topics, groups, _ := s.pubsub.Counts()
 	out = &api.ServiceState{
-		Status:  "ok",
-		Uptime:  time.Since(s.started).String(),
-		Version: Version(),
+		Status:          "ok",
+		Uptime:          time.Since(s.started).String(),
+		Version:         Version(),
+		Topics:          uint32(topics),
+		Groups:          uint32(groups),
+		Publishers:      uint32(atomic.LoadInt32(&s.pubs)),
+		Subscribers:     uint32(atomic.LoadInt32(&s.subs)),
+		EventsPublished: s.pubrate.Count(),
+		EventsDelivered: s.subrate.Count(),
+		PublishRate:     s.pubrate.Rate(),
+		DeliveryRate:    s.subrate.Rate(),
+		Components:      make([]*api.ComponentHealth, 0, len(s.health)),
+	}
+
+	for _, check := range s.health {
+		component := check()
+		if component.Status == HealthDegraded || component.Status == HealthUnavailable {
+			out.Status = "degraded"
+		}
+		out.Components = append(out.Components, component)
+	}
+	sort.Slice(out.Components, func(i, j int) bool { return out.Components[i].Name < out.Components[j].Name })
+
+	if s.cluster != nil {
+		out.Term = s.cluster.Term()
+		out.StorageBytes = s.storageBytes()
+		if out.Members, err = s.members(); err != nil {
+			log.Warn().Err(err).Msg("could not get cluster members")
+			err = nil
+		}
 	}
 
-	if s.conf.Maintenance {
+	if s.InMaintenance() {
 		out.Status = "maintenance"
 	}
 	return out, nil
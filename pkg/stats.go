@@ -0,0 +1,102 @@
+package switchback
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+)
+
+const (
+	meterInterval = 5 * time.Second
+	meterWindow   = time.Minute
+)
+
+// Component health statuses reported by the Status RPC.
+const (
+	HealthOK          = "ok"
+	HealthDegraded    = "degraded"
+	HealthUnavailable = "unavailable"
+	HealthDisabled    = "disabled"
+)
+
+// HealthCheck reports the health of a server component in the Status RPC.
+type HealthCheck func() *api.ComponentHealth
+
+// meter counts events and computes an exponentially weighted moving average of the
+// rate of events per second over a one minute window, similar to a unix load average.
+// The average is updated lazily in five second intervals when the rate is read.
+type meter struct {
+	count uint64
+	sync.Mutex
+	last    uint64
+	updated time.Time
+	rate    float64
+}
+
+func newMeter() *meter {
+	return &meter{updated: time.Now()}
+}
+
+func (m *meter) Mark(n uint64) {
+	atomic.AddUint64(&m.count, n)
+}
+
+func (m *meter) Count() uint64 {
+	return atomic.LoadUint64(&m.count)
+}
+
+func (m *meter) Rate() float64 {
+	m.Lock()
+	defer m.Unlock()
+
+	elapsed := time.Since(m.updated)
+	if elapsed < meterInterval {
+		return m.rate
+	}
+
+	// Attribute all events since the last update evenly across the elapsed intervals
+	count := m.Count()
+	ticks := int(elapsed / meterInterval)
+	instant := float64(count-m.last) / elapsed.Seconds()
+	alpha := 1 - math.Exp(-meterInterval.Seconds()/meterWindow.Seconds())
+	for i := 0; i < ticks; i++ {
+		m.rate += alpha * (instant - m.rate)
+	}
+
+	m.last = count
+	m.updated = m.updated.Add(time.Duration(ticks) * meterInterval)
+	return m.rate
+}
+
+// Counts returns the number of topics that have been published or subscribed to, the
+// number of consumer groups, and the number of connected consumers.
+func (p *PubSub) Counts() (topics, groups, consumers int) {
+	p.Lock()
+	defer p.Unlock()
+
+	seen := make(map[string]struct{}, len(p.offsets))
+	for topic := range p.offsets {
+		seen[topic] = struct{}{}
+	}
+
+	for topic, tgroups := range p.topics {
+		seen[topic] = struct{}{}
+		groups += len(tgroups)
+		for _, group := range tgroups {
+			group.RLock()
+			consumers += len(group.consumers)
+			group.RUnlock()
+		}
+	}
+	return len(seen), groups, consumers
+}
+
+// disabled returns a health check for a component that is not configured.
+func disabled(name string) HealthCheck {
+	return func() *api.ComponentHealth {
+		return &api.ComponentHealth{Name: name, Status: HealthDisabled, Message: "not configured"}
+	}
+}
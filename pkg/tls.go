@@ -0,0 +1,124 @@
+package switchback
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/credentials"
+)
+
+// CertReloader holds the server certificate and client CA pool loaded from disk and
+// serves them to new TLS handshakes. Calling Reload replaces the certificates for new
+// connections without affecting connections (and streams) that are already open.
+type CertReloader struct {
+	sync.RWMutex
+	conf config.TLSConfig
+	cert *tls.Certificate
+	pool *x509.CertPool
+}
+
+// NewCertReloader loads the certificates specified by the TLS configuration.
+func NewCertReloader(conf config.TLSConfig) (r *CertReloader, err error) {
+	r = &CertReloader{conf: conf}
+	if err = r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate, key, and client CA from disk. If any of the files
+// cannot be loaded then the previous certificates are kept and an error is returned.
+func (r *CertReloader) Reload() (err error) {
+	var cert tls.Certificate
+	if cert, err = tls.LoadX509KeyPair(r.conf.CertFile, r.conf.KeyFile); err != nil {
+		return fmt.Errorf("could not load server certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.conf.Mutual() {
+		if pool, err = LoadCertPool(r.conf.ClientCA); err != nil {
+			return err
+		}
+	}
+
+	r.Lock()
+	r.cert = &cert
+	r.pool = pool
+	r.Unlock()
+
+	log.Debug().Str("cert", r.conf.CertFile).Bool("mtls", r.conf.Mutual()).Msg("tls certificates loaded")
+	return nil
+}
+
+// Credentials returns gRPC transport credentials that always use the most recently
+// loaded certificates.
+func (r *CertReloader) Credentials() credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.GetConfigForClient,
+	})
+}
+
+// ClientCredentials returns gRPC transport credentials for connecting to other nodes
+// in the cluster. The node presents its server certificate as its client certificate
+// and verifies peers with the client CA pool (or the system roots if not mutual).
+func (r *CertReloader) ClientCredentials() credentials.TransportCredentials {
+	r.RLock()
+	defer r.RUnlock()
+
+	return credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    r.pool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			r.RLock()
+			defer r.RUnlock()
+			return r.cert, nil
+		},
+	})
+}
+
+// GetCertificate implements the tls.Config callback to return the current certificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
+
+// GetConfigForClient implements the tls.Config callback to build a config for each
+// handshake from the currently loaded certificates.
+func (r *CertReloader) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	conf := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*r.cert},
+		NextProtos:   []string{"h2"},
+	}
+
+	if r.pool != nil {
+		conf.ClientCAs = r.pool
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return conf, nil
+}
+
+// LoadCertPool reads PEM encoded certificates from the specified path into a pool.
+func LoadCertPool(path string) (pool *x509.CertPool, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return nil, fmt.Errorf("could not read ca certificates: %w", err)
+	}
+
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("could not parse ca certificates")
+	}
+	return pool, nil
+}
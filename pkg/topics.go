@@ -0,0 +1,54 @@
+package switchback
+
+import (
+	"context"
+	"path"
+	"sort"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListTopics returns the topics matching the query pattern that the caller has access
+// to, e.g. so that mirrors can discover the topics to copy.
+func (s *Server) ListTopics(ctx context.Context, in *api.TopicQuery) (out *api.TopicList, err error) {
+	if in.Pattern != "" {
+		if _, err = path.Match(in.Pattern, ""); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid topic pattern %q", in.Pattern)
+		}
+	}
+
+	topics := make(map[string]*api.Topic)
+	for _, topic := range s.pubsub.Topics() {
+		topics[topic.Topic] = &api.Topic{Name: topic.Topic, Offset: topic.Offset, Groups: uint32(topic.Groups)}
+	}
+
+	// Followers do not route events so the cluster log has the current offsets
+	if s.cluster != nil {
+		for name, offset := range s.cluster.Topics() {
+			if topic, ok := topics[name]; ok {
+				topic.Offset = offset
+			} else {
+				topics[name] = &api.Topic{Name: name, Offset: offset}
+			}
+		}
+	}
+
+	out = &api.TopicList{Topics: make([]*api.Topic, 0, len(topics))}
+	for name, topic := range topics {
+		if in.Pattern != "" {
+			if ok, _ := path.Match(in.Pattern, name); !ok {
+				continue
+			}
+		}
+
+		if !s.visible(ctx, name) {
+			continue
+		}
+		out.Topics = append(out.Topics, topic)
+	}
+
+	sort.Slice(out.Topics, func(i, j int) bool { return out.Topics[i].Name < out.Topics[j].Name })
+	return out, nil
+}
@@ -0,0 +1,103 @@
+package switchback
+
+import (
+	"context"
+	"os"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The tracer uses the global tracer provider, so spans are no-ops unless tracing is
+// enabled by the server (or by an application embedding the pub/sub library).
+var tracer globalTracer
+
+// globalTracer looks up the tracer from the global provider when each span is started
+// rather than once, so that a server created after another has replaced the provider
+// (e.g. embedded servers in tests) exports its spans to its own provider.
+type globalTracer struct{}
+
+func (globalTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer("github.com/bbengfort/switchback").Start(ctx, name, opts...)
+}
+
+// Trace context is propagated between producers and consumers in the event attributes.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// setupTracing creates the exporter and tracer provider specified by the configuration
+// and registers the provider globally.
+func (s *Server) setupTracing() (err error) {
+	var exporter sdktrace.SpanExporter
+	switch s.conf.Tracing.Exporter {
+	case "stdout":
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout)); err != nil {
+			return err
+		}
+	default:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(s.conf.Tracing.Endpoint)}
+		if s.conf.Tracing.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		if exporter, err = otlptracegrpc.New(context.Background(), opts...); err != nil {
+			return err
+		}
+	}
+
+	s.tracing = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(s.conf.Tracing.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String("switchback"),
+			semconv.ServiceVersionKey.String(Version()),
+		)),
+	)
+
+	otel.SetTracerProvider(s.tracing)
+	otel.SetTextMapPropagator(propagator)
+	return nil
+}
+
+// InjectTrace writes the trace context of ctx into the event attributes so that the
+// spans of consumers of the event are linked to the span that produced it.
+func InjectTrace(ctx context.Context, event *api.Event) {
+	if event.Attributes == nil {
+		event.Attributes = make(map[string]string)
+	}
+	propagator.Inject(ctx, propagation.MapCarrier(event.Attributes))
+}
+
+// ExtractTrace returns a context with the trace context stored in the event attributes.
+func ExtractTrace(ctx context.Context, event *api.Event) context.Context {
+	return propagator.Extract(ctx, propagation.MapCarrier(event.Attributes))
+}
+
+func eventAttributes(event *api.Event) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String("switchback"),
+		semconv.MessagingDestinationKey.String(event.Topic),
+		semconv.MessagingMessageIDKey.Int64(int64(event.Meta.GetOffset())),
+		semconv.MessagingMessagePayloadSizeBytesKey.Int(len(event.Data)),
+	}
+}
+
+// startDelivery starts a span for sending an event to a consumer that is linked to the
+// span that published the event.
+func startDelivery(ctx context.Context, event *api.Event, group string) (context.Context, trace.Span) {
+	link := trace.LinkFromContext(ExtractTrace(context.Background(), event))
+	return tracer.Start(ctx, "deliver "+event.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(link),
+		trace.WithAttributes(eventAttributes(event)...),
+		trace.WithAttributes(semconv.MessagingConsumerIDKey.String(group)),
+	)
+}
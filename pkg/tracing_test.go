@@ -0,0 +1,162 @@
+package switchback_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	producerTrace  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	producerParent = "00-" + producerTrace + "-00f067aa0ba902b7-01"
+)
+
+// exportedSpan is the part of a span written by the stdout exporter the test checks.
+type exportedSpan struct {
+	Name        string
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		TraceID string
+		SpanID  string
+	}
+}
+
+func TestTracing(t *testing.T) {
+	// The stdout exporter writes to the stdout of the process when the server is created
+	out, err := os.CreateTemp(t.TempDir(), "spans")
+	if err != nil {
+		t.Fatalf("could not create span output: %s", err)
+	}
+	defer out.Close()
+
+	conf, err := config.New()
+	if err != nil {
+		t.Fatalf("could not load config: %s", err)
+	}
+	conf.LogLevel = config.LevelDecoder(zerolog.ErrorLevel)
+	conf.ShutdownTimeout = time.Second
+	conf.Tracing.Enabled = true
+	conf.Tracing.Exporter = "stdout"
+	conf.Tracing.SampleRatio = 1.0
+	conf.Events.MaxAttributes = 4
+
+	stdout := os.Stdout
+	os.Stdout = out
+	srv, err := switchback.New(conf)
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatalf("could not create server: %s", err)
+	}
+
+	if err = srv.ServeEmbedded(); err != nil {
+		t.Fatalf("could not serve embedded server: %s", err)
+	}
+
+	client := embeddedClient(t, srv)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub, err := client.Subscribe(ctx, &api.Subscription{Topic: "traced"})
+	if err != nil {
+		t.Fatalf("could not subscribe: %s", err)
+	}
+	waitForGroups(t, srv.PubSub(), "traced", 1)
+
+	pub, err := client.Publish(ctx)
+	if err != nil {
+		t.Fatalf("could not open publish stream: %s", err)
+	}
+
+	if err = pub.Send(&api.Event{Topic: "traced", Data: []byte("hello"), Attributes: map[string]string{"traceparent": producerParent}}); err != nil {
+		t.Fatalf("could not publish event: %s", err)
+	}
+
+	if _, err = pub.CloseAndRecv(); err != nil {
+		t.Fatalf("could not close publish stream: %s", err)
+	}
+
+	event, err := sub.Recv()
+	if err != nil {
+		t.Fatalf("could not receive event: %s", err)
+	}
+
+	// The subscriber receives the context of the server's publish span in the producer's trace
+	parts := strings.Split(event.Attributes["traceparent"], "-")
+	if len(parts) != 4 {
+		t.Fatalf("expected a traceparent attribute, got %q", event.Attributes["traceparent"])
+	}
+
+	if parts[1] != producerTrace {
+		t.Errorf("expected the event to be in trace %s, got %s", producerTrace, parts[1])
+	}
+
+	// The trace context added by the server must fit within the attribute limit
+	if pub, err = client.Publish(ctx); err != nil {
+		t.Fatalf("could not open publish stream: %s", err)
+	}
+
+	attributes := make(map[string]string, conf.Events.MaxAttributes)
+	for i := 0; i < conf.Events.MaxAttributes; i++ {
+		attributes[fmt.Sprintf("key%d", i)] = "value"
+	}
+
+	if err = pub.Send(&api.Event{Topic: "traced", Attributes: attributes}); err != nil {
+		t.Fatalf("could not publish event: %s", err)
+	}
+
+	if _, err = pub.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected too many attributes with the trace context to be invalid, got %v", err)
+	}
+
+	cancel()
+	if err = srv.Shutdown(); err != nil {
+		t.Fatalf("could not shutdown server: %s", err)
+	}
+
+	if _, err = out.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("could not read span output: %s", err)
+	}
+
+	var published *exportedSpan
+	decoder := json.NewDecoder(out)
+	for {
+		span := &exportedSpan{}
+		if err = decoder.Decode(span); err != nil {
+			if err != io.EOF {
+				t.Fatalf("could not decode exported span: %s", err)
+			}
+			break
+		}
+
+		if span.Name == "publish traced" && span.SpanContext.TraceID == producerTrace {
+			published = span
+		}
+	}
+
+	if published == nil {
+		t.Fatal("the publish span was not exported")
+	}
+
+	if published.SpanContext.SpanID != parts[2] {
+		t.Errorf("expected the event to carry the publish span %s, got %s", published.SpanContext.SpanID, parts[2])
+	}
+
+	if published.Parent.TraceID != producerTrace {
+		t.Errorf("expected the publish span to be a child of the producer trace, got %s", published.Parent.TraceID)
+	}
+}
@@ -0,0 +1,254 @@
+package switchback
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/bbengfort/switchback/pkg/cluster"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// transactions holds the events published in open transactions until they are committed
+// or aborted, so consumers never observe the events of uncommitted transactions. Open
+// transactions are held in memory by the server (the leader in a cluster) that began
+// them and are aborted when they expire or the server loses leadership.
+type transactions struct {
+	sync.Mutex
+	open map[string]*transaction
+}
+
+type transaction struct {
+	id      string
+	owner   string
+	events  []*api.Event
+	expires time.Time
+	timer   *time.Timer
+}
+
+func newTransactions() *transactions {
+	return &transactions{open: make(map[string]*transaction)}
+}
+
+// BeginTransaction opens a transaction that events can be published in by setting their
+// transaction id; the events are held until the transaction is committed or aborted.
+func (s *Server) BeginTransaction(ctx context.Context, in *api.TransactionRequest) (_ *api.Transaction, err error) {
+	if s.cluster != nil && !s.cluster.IsLeader() {
+		var client api.SwitchbackClient
+		if client, err = s.leaderClient(ctx); err != nil {
+			return nil, err
+		}
+		return client.BeginTransaction(s.forwardContext(ctx), in)
+	}
+
+	timeout := s.conf.Events.TransactionTimeout
+	if in.Timeout != "" {
+		var requested time.Duration
+		if requested, err = time.ParseDuration(in.Timeout); err != nil || requested <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid transaction timeout %q", in.Timeout)
+		}
+
+		if requested < timeout {
+			timeout = requested
+		}
+	}
+
+	txn := &transaction{
+		id:      uuid.New().String(),
+		owner:   owner(ctx),
+		expires: time.Now().Add(timeout),
+	}
+
+	s.txns.Lock()
+	s.txns.open[txn.id] = txn
+	txn.timer = time.AfterFunc(timeout, func() { s.txns.expire(txn.id) })
+	s.txns.Unlock()
+
+	log.Debug().Str("transaction", txn.id).Dur("timeout", timeout).Msg("transaction started")
+	return txn.state(api.TransactionState_OPEN), nil
+}
+
+// CommitTransaction publishes all of the events held in the transaction atomically. In
+// a cluster the events are appended to the replicated log as a single entry.
+func (s *Server) CommitTransaction(ctx context.Context, in *api.Transaction) (out *api.Transaction, err error) {
+	if s.cluster != nil && !s.cluster.IsLeader() {
+		var client api.SwitchbackClient
+		if client, err = s.leaderClient(ctx); err != nil {
+			return nil, err
+		}
+		return client.CommitTransaction(s.forwardContext(ctx), in)
+	}
+
+	var txn *transaction
+	if txn, err = s.txns.remove(ctx, in.Id); err != nil {
+		return nil, err
+	}
+
+	var published []*api.Event
+	if s.cluster != nil {
+		topics := make([]string, 0, len(txn.events))
+		for _, event := range txn.events {
+			topics = append(topics, event.Topic)
+		}
+
+		unlock := s.pubsub.sequence(topics...)
+		defer unlock()
+		if published, err = s.cluster.PublishAll(txn.events); err != nil {
+			s.txns.dropped(txn)
+			if errors.Is(err, cluster.ErrNotLeader) {
+				return nil, s.notLeader()
+			}
+			log.Error().Err(err).Str("transaction", txn.id).Msg("could not replicate transaction")
+			return nil, status.Error(codes.Unavailable, "could not replicate transaction to the cluster, it has been aborted")
+		}
+
+		for _, event := range published {
+			if err := s.pubsub.Route(ctx, event); err != nil {
+				log.Error().Err(err).Msg("could not route event")
+			}
+		}
+	} else {
+		published = s.pubsub.PublishAll(ctx, txn.events)
+	}
+
+	s.pubrate.Mark(uint64(len(published)))
+
+	out = txn.state(api.TransactionState_COMMITTED)
+	out.Events = uint64(len(published))
+	out.Offsets = make(map[string]uint64)
+	for _, event := range published {
+		out.Offsets[event.Topic] = event.Meta.GetOffset()
+	}
+
+	log.Debug().Str("transaction", txn.id).Int("events", len(published)).Msg("transaction committed")
+	return out, nil
+}
+
+// AbortTransaction discards the events held in the transaction.
+func (s *Server) AbortTransaction(ctx context.Context, in *api.Transaction) (_ *api.Transaction, err error) {
+	if s.cluster != nil && !s.cluster.IsLeader() {
+		var client api.SwitchbackClient
+		if client, err = s.leaderClient(ctx); err != nil {
+			return nil, err
+		}
+		return client.AbortTransaction(s.forwardContext(ctx), in)
+	}
+
+	var txn *transaction
+	if txn, err = s.txns.remove(ctx, in.Id); err != nil {
+		return nil, err
+	}
+
+	s.txns.dropped(txn)
+	log.Debug().Str("transaction", txn.id).Int("events", len(txn.events)).Msg("transaction aborted")
+	return txn.state(api.TransactionState_ABORTED), nil
+}
+
+// add holds a published event in its transaction. The event must be published by the
+// principal that began the transaction.
+func (t *transactions) add(ctx context.Context, event *api.Event, limit int) error {
+	t.Lock()
+	defer t.Unlock()
+
+	txn, err := t.get(ctx, event.Transaction)
+	if err != nil {
+		return err
+	}
+
+	if len(txn.events) >= limit {
+		return status.Errorf(codes.ResourceExhausted, "transaction exceeds the maximum of %d events", limit)
+	}
+	txn.events = append(txn.events, event)
+	return nil
+}
+
+// remove closes the transaction so that no more events can be published in it.
+func (t *transactions) remove(ctx context.Context, id string) (txn *transaction, err error) {
+	t.Lock()
+	defer t.Unlock()
+
+	if txn, err = t.get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	txn.timer.Stop()
+	delete(t.open, id)
+	return txn, nil
+}
+
+// Must hold the lock to call this method.
+func (t *transactions) get(ctx context.Context, id string) (*transaction, error) {
+	if id == "" {
+		return nil, status.Error(codes.InvalidArgument, "transaction id is required")
+	}
+
+	txn, ok := t.open[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "transaction %q is not open, it may have expired", id)
+	}
+
+	if txn.owner != "" && txn.owner != owner(ctx) {
+		return nil, status.Error(codes.PermissionDenied, "transaction was started by another principal")
+	}
+	return txn, nil
+}
+
+// expire aborts the transaction if it is still open when its timeout elapses.
+func (t *transactions) expire(id string) {
+	t.Lock()
+	txn, ok := t.open[id]
+	delete(t.open, id)
+	t.Unlock()
+
+	if ok {
+		t.dropped(txn)
+		log.Warn().Str("transaction", id).Int("events", len(txn.events)).Msg("transaction expired and was aborted")
+	}
+}
+
+// Abort all open transactions, e.g. when the server loses cluster leadership.
+func (t *transactions) Abort() {
+	t.Lock()
+	open := t.open
+	t.open = make(map[string]*transaction)
+	t.Unlock()
+
+	for _, txn := range open {
+		txn.timer.Stop()
+		t.dropped(txn)
+	}
+
+	if len(open) > 0 {
+		log.Warn().Int("transactions", len(open)).Msg("open transactions aborted")
+	}
+}
+
+func (t *transactions) dropped(txn *transaction) {
+	for _, event := range txn.events {
+		eventsDropped.WithLabelValues(event.Topic, "transaction_aborted").Inc()
+	}
+}
+
+func (t *transaction) state(state api.TransactionState) *api.Transaction {
+	return &api.Transaction{
+		Id:      t.id,
+		State:   state,
+		Events:  uint64(len(t.events)),
+		Expires: t.expires.Format(time.RFC3339),
+	}
+}
+
+// owner returns the name of the authenticated principal or an empty string if the
+// server does not authenticate clients, in which case any client may use a transaction.
+func owner(ctx context.Context) string {
+	if principal, ok := auth.FromContext(ctx); ok && principal != nil {
+		return principal.Name
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package switchback
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+)
+
+// grpcOverhead is added to the max event size to determine the maximum gRPC message
+// size so that the topic, attributes, and metadata fit alongside a maximal payload.
+const grpcOverhead = 64 * 1024
+
+// Validator checks that published events and subscriptions satisfy the configured topic
+// syntax and payload limits.
+type Validator struct {
+	conf  config.EventsConfig
+	topic *regexp.Regexp
+}
+
+func NewValidator(conf config.EventsConfig) (v *Validator, err error) {
+	v = &Validator{conf: conf}
+	if v.topic, err = regexp.Compile(conf.TopicPattern); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// MaxMessageSize returns the size of the largest message containing a single event.
+func (v *Validator) MaxMessageSize() int {
+	return v.conf.MaxSize + v.conf.MaxAttributes*v.conf.MaxAttributeSize + v.conf.MaxProducerLength + v.conf.MaxKeySize + grpcOverhead
+}
+
+// MaxBatchMessageSize returns the largest message the server should receive, which is
+// either a batch of events limited by the max batch bytes or a single maximal event.
+func (v *Validator) MaxBatchMessageSize() int {
+	if size := v.MaxMessageSize(); size > v.conf.MaxBatchBytes {
+		return size
+	}
+	return v.conf.MaxBatchBytes
+}
+
+// Event returns an error describing the first constraint the event violates.
+func (v *Validator) Event(event *api.Event) (err error) {
+	if err = v.Topic(event.Topic); err != nil {
+		return err
+	}
+
+	if len(event.Data) > v.conf.MaxSize {
+		return fmt.Errorf("event data is %d bytes, exceeding the maximum of %d bytes", len(event.Data), v.conf.MaxSize)
+	}
+
+	if len(event.Attributes) > v.conf.MaxAttributes {
+		return fmt.Errorf("event has %d attributes, exceeding the maximum of %d", len(event.Attributes), v.conf.MaxAttributes)
+	}
+
+	if event.Sequence > 0 && event.Producer == "" {
+		return errors.New("event sequence numbers require a producer id")
+	}
+
+	if len(event.Producer) > v.conf.MaxProducerLength {
+		return fmt.Errorf("producer id exceeds the maximum length of %d characters", v.conf.MaxProducerLength)
+	}
+
+	if len(event.Key) > v.conf.MaxKeySize {
+		return fmt.Errorf("event key exceeds the maximum size of %d bytes", v.conf.MaxKeySize)
+	}
+
+	for key, val := range event.Attributes {
+		if key == "" {
+			return errors.New("event attribute keys cannot be empty")
+		}
+
+		if len(key)+len(val) > v.conf.MaxAttributeSize {
+			return fmt.Errorf("event attribute %q exceeds the maximum size of %d bytes", key, v.conf.MaxAttributeSize)
+		}
+	}
+	return nil
+}
+
+// Topic returns an error if the topic name is empty, too long, or has invalid syntax.
+func (v *Validator) Topic(topic string) error {
+	if topic == "" {
+		return errors.New("topic is required")
+	}
+
+	if len(topic) > v.conf.MaxTopicLength {
+		return fmt.Errorf("topic exceeds the maximum length of %d characters", v.conf.MaxTopicLength)
+	}
+
+	if !v.topic.MatchString(topic) {
+		return fmt.Errorf("topic %q does not match the pattern %q", topic, v.conf.TopicPattern)
+	}
+	return nil
+}
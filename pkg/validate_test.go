@@ -0,0 +1,51 @@
+package switchback_test
+
+import (
+	"strings"
+	"testing"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+)
+
+func TestValidateEvent(t *testing.T) {
+	conf, err := config.New()
+	if err != nil {
+		t.Fatalf("could not load config: %s", err)
+	}
+
+	// The producer and key limits are independent of the topic and attribute limits
+	conf.Events.MaxTopicLength = 64
+	conf.Events.MaxAttributeSize = 16
+	conf.Events.MaxProducerLength = 8
+	conf.Events.MaxKeySize = 32
+
+	valid, err := switchback.NewValidator(conf.Events)
+	if err != nil {
+		t.Fatalf("could not create validator: %s", err)
+	}
+
+	tests := []struct {
+		event *api.Event
+		err   string
+	}{
+		{&api.Event{Topic: "orders", Producer: "p1", Sequence: 1, Key: strings.Repeat("k", 32)}, ""},
+		{&api.Event{Topic: "orders", Producer: strings.Repeat("p", 9)}, "producer id exceeds the maximum length of 8"},
+		{&api.Event{Topic: "orders", Key: strings.Repeat("k", 33)}, "event key exceeds the maximum size of 32"},
+		{&api.Event{Topic: "orders", Sequence: 1}, "sequence numbers require a producer id"},
+		{&api.Event{Topic: "orders", Attributes: map[string]string{"region": "us-east-1-a"}}, "exceeds the maximum size of 16"},
+		{&api.Event{Topic: strings.Repeat("t", 65)}, "topic exceeds the maximum length of 64"},
+		{&api.Event{Topic: "orders..created"}, "does not match the pattern"},
+	}
+
+	for i, tc := range tests {
+		err := valid.Event(tc.event)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("test %d: expected event to be valid, got %s", i, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("test %d: expected error %q, got %v", i, tc.err, err)
+		}
+	}
+}
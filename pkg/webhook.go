@@ -0,0 +1,375 @@
+package switchback
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/rs/zerolog/log"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Headers sent with each webhook request. The signature is the hex encoded HMAC-SHA256
+// of the timestamp, a period, and the body, keyed with the hook's secret, so that
+// receivers can verify the payload and reject requests replayed later.
+const (
+	WebhookTopic     = "X-Switchback-Topic"
+	WebhookOffset    = "X-Switchback-Offset"
+	WebhookAttempt   = "X-Switchback-Attempt"
+	WebhookTimestamp = "X-Switchback-Timestamp"
+	WebhookSignature = "X-Switchback-Signature"
+)
+
+// Attributes added to events published to a hook's dead letter topic.
+const (
+	DeadLetterHook     = "switchback.webhook.hook"
+	DeadLetterTopic    = "switchback.webhook.topic"
+	DeadLetterOffset   = "switchback.webhook.offset"
+	DeadLetterAttempts = "switchback.webhook.attempts"
+	DeadLetterError    = "switchback.webhook.error"
+)
+
+// webhookReconnect is how long a hook waits to reconnect its consumer after it is closed,
+// e.g. while another server in the cluster is the leader.
+const webhookReconnect = 5 * time.Second
+
+// webhooks push the events of topics to URLs on behalf of consumers that cannot hold a
+// subscription open.
+type webhooks struct {
+	hooks  []*webhook
+	client *http.Client
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// webhook is a consumer in its group that POSTs each event it receives to the hook URL.
+// Events are acknowledged once they are delivered or dead-lettered.
+type webhook struct {
+	srv          *Server
+	conf         config.HookConfig
+	delivered    uint64
+	deadLettered uint64
+	failing      int32
+}
+
+// webhookError is returned when the hook URL responds with an error status.
+type webhookError struct {
+	status int
+}
+
+func (e *webhookError) Error() string {
+	return fmt.Sprintf("webhook responded with status %d", e.status)
+}
+
+// retry returns true unless the receiver rejected the event itself; client errors other
+// than timeouts and rate limiting will fail again if the event is redelivered.
+func (e *webhookError) retry() bool {
+	return e.status >= 500 || e.status == http.StatusRequestTimeout || e.status == http.StatusTooManyRequests || e.status < 400
+}
+
+// setupWebhooks checks the topics of the hooks and creates the http client that
+// delivers their events.
+func (s *Server) setupWebhooks() (err error) {
+	s.webhooks = &webhooks{
+		hooks:  make([]*webhook, 0, len(s.conf.Webhooks.Hooks)),
+		client: &http.Client{Timeout: s.conf.Webhooks.Timeout},
+	}
+
+	for _, conf := range s.conf.Webhooks.Hooks {
+		if err = s.valid.Topic(conf.Topic); err != nil {
+			return fmt.Errorf("invalid webhook %q: %w", conf.Name, err)
+		}
+
+		if conf.DeadLetter != "" {
+			if err = s.valid.Topic(conf.DeadLetter); err != nil {
+				return fmt.Errorf("invalid webhook %q dead letter topic: %w", conf.Name, err)
+			}
+		}
+
+		if conf.Group == "" {
+			conf.Group = "webhook." + conf.Name
+		}
+		s.webhooks.hooks = append(s.webhooks.hooks, &webhook{srv: s, conf: conf})
+	}
+
+	s.health["webhooks"] = s.webhookHealth
+	return nil
+}
+
+// startWebhooks runs the hooks until stopWebhooks is called.
+func (s *Server) startWebhooks() {
+	var ctx context.Context
+	ctx, s.webhooks.cancel = context.WithCancel(context.Background())
+
+	for _, hook := range s.webhooks.hooks {
+		s.webhooks.wg.Add(1)
+		go hook.run(ctx)
+	}
+}
+
+// stopWebhooks stops delivering events; an event that is being delivered is not
+// acknowledged. In a cluster it is redelivered from the replicated log when the hook is
+// next started, otherwise it is lost along with the other events queued for the hook.
+func (s *Server) stopWebhooks() {
+	if s.webhooks.cancel != nil {
+		s.webhooks.cancel()
+		s.webhooks.wg.Wait()
+	}
+}
+
+// run connects the hook's consumer and delivers its events until the context is
+// canceled, reconnecting if the consumer is closed. In a cluster only the leader routes
+// events, so only the leader delivers them.
+func (h *webhook) run(ctx context.Context) {
+	defer h.srv.webhooks.wg.Done()
+	ticker := time.NewTicker(webhookReconnect)
+	defer ticker.Stop()
+
+	for {
+		if h.srv.cluster == nil || h.srv.cluster.IsLeader() {
+			h.consume(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *webhook) consume(ctx context.Context) {
+	consumer, err := h.srv.pubsub.Connect(&api.Subscription{Topic: h.conf.Topic, Group: h.conf.Group})
+	if err != nil {
+		log.Warn().Err(err).Str("webhook", h.conf.Name).Msg("could not connect webhook consumer")
+		return
+	}
+	defer h.srv.pubsub.Disconnect(consumer)
+	if h.srv.cluster != nil {
+		defer h.srv.commitAcked(consumer)
+	}
+
+	log.Info().Str("webhook", h.conf.Name).Str("topic", h.conf.Topic).Str("group", h.conf.Group).Msg("webhook started")
+	events := consumer.Events()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if err = consumer.Wait(ctx); err != nil {
+				return
+			}
+
+			if err = h.deliver(ctx, event); err != nil {
+				// The context was canceled, leave the event unacknowledged
+				return
+			}
+
+			consumer.Ack(event.Meta.GetOffset())
+			h.srv.subrate.Mark(1)
+			eventsDelivered.WithLabelValues(consumer.topic, consumer.label()).Inc()
+		}
+	}
+}
+
+// deliver posts the event to the hook URL, retrying with exponential backoff until it
+// is accepted or the max attempts are exhausted, in which case it is dead-lettered. An
+// error is only returned if the context is canceled.
+func (h *webhook) deliver(ctx context.Context, event *api.Event) (err error) {
+	var span trace.Span
+	ctx, span = startDelivery(ctx, event, h.conf.Group)
+	defer span.End()
+
+	var body []byte
+	if body, err = gatewayMarshal.Marshal(event); err != nil {
+		return h.deadLetter(ctx, event, err, 0)
+	}
+
+	conf := h.srv.conf.Webhooks
+	backoff := conf.Backoff
+	for attempt := 1; ; attempt++ {
+		if err = h.post(ctx, event, body, attempt); err == nil {
+			atomic.AddUint64(&h.delivered, 1)
+			atomic.StoreInt32(&h.failing, 0)
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		span.RecordError(err)
+		rejected, ok := err.(*webhookError)
+		if attempt >= conf.MaxAttempts || (ok && !rejected.retry()) {
+			span.SetStatus(otelcodes.Error, err.Error())
+			atomic.StoreInt32(&h.failing, 1)
+			return h.deadLetter(ctx, event, err, attempt)
+		}
+
+		// Wait between half and all of the backoff so that retries from many hooks to the
+		// same receiver are spread out
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Debug().Err(err).Str("webhook", h.conf.Name).Int("attempt", attempt).Dur("wait", wait).Msg("webhook delivery failed, retrying")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > conf.MaxBackoff {
+			backoff = conf.MaxBackoff
+		}
+	}
+}
+
+// post sends a single delivery attempt; any 2xx response accepts the event.
+func (h *webhook) post(ctx context.Context, event *api.Event, body []byte, attempt int) (err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodPost, h.conf.URL, bytes.NewReader(body)); err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "switchback/"+Version())
+	req.Header.Set(WebhookTopic, event.Topic)
+	req.Header.Set(WebhookOffset, strconv.FormatUint(event.Meta.GetOffset(), 10))
+	req.Header.Set(WebhookAttempt, strconv.Itoa(attempt))
+	req.Header.Set(WebhookTimestamp, timestamp)
+	if h.conf.Secret != "" {
+		req.Header.Set(WebhookSignature, "sha256="+Sign(h.conf.Secret, timestamp, body))
+	}
+
+	var rep *http.Response
+	if rep, err = h.srv.webhooks.client.Do(req); err != nil {
+		return err
+	}
+
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(rep.Body, 64*1024))
+	rep.Body.Close()
+
+	if rep.StatusCode < 200 || rep.StatusCode >= 300 {
+		return &webhookError{status: rep.StatusCode}
+	}
+	return nil
+}
+
+// deadLetter publishes the event that could not be delivered to the hook's dead letter
+// topic with the reason it failed, or drops it if the hook has no dead letter topic. The
+// reason is truncated to fit the attribute size limit; if the dead letter event is still
+// not valid (e.g. the event already had the maximum attributes) it is dropped.
+func (h *webhook) deadLetter(ctx context.Context, event *api.Event, cause error, attempts int) error {
+	atomic.AddUint64(&h.deadLettered, 1)
+	eventsDropped.WithLabelValues(event.Topic, "webhook_failed").Inc()
+	log.Warn().Err(cause).Str("webhook", h.conf.Name).Uint64("offset", event.Meta.GetOffset()).Int("attempts", attempts).Str("dead_letter", h.conf.DeadLetter).Msg("could not deliver event to webhook")
+
+	if h.conf.DeadLetter == "" {
+		return nil
+	}
+
+	failed := &api.Event{
+		Topic:      h.conf.DeadLetter,
+		Data:       event.Data,
+		Key:        event.Key,
+		Attributes: make(map[string]string, len(event.Attributes)+5),
+	}
+	for key, val := range event.Attributes {
+		failed.Attributes[key] = val
+	}
+	failed.Attributes[DeadLetterHook] = h.conf.Name
+	failed.Attributes[DeadLetterTopic] = event.Topic
+	failed.Attributes[DeadLetterOffset] = strconv.FormatUint(event.Meta.GetOffset(), 10)
+	failed.Attributes[DeadLetterAttempts] = strconv.Itoa(attempts)
+	failed.Attributes[DeadLetterError] = truncate(cause.Error(), h.srv.conf.Events.MaxAttributeSize-len(DeadLetterError))
+	failed.Meta = &api.Metadata{Source: h.srv.conf.Name}
+
+	if err := h.srv.valid.Event(failed); err != nil {
+		eventsDropped.WithLabelValues(h.conf.DeadLetter, "invalid_dead_letter").Inc()
+		log.Error().Err(err).Str("webhook", h.conf.Name).Str("topic", h.conf.DeadLetter).Msg("could not dead letter invalid event")
+		return nil
+	}
+
+	if err := h.srv.schemas.Validate(failed.Topic, failed.Data); err != nil {
+		eventsDropped.WithLabelValues(h.conf.DeadLetter, "invalid_dead_letter").Inc()
+		log.Error().Err(err).Str("webhook", h.conf.Name).Str("topic", h.conf.DeadLetter).Msg("could not dead letter event that does not match the topic schema")
+		return nil
+	}
+
+	if err := h.srv.route(ctx, failed); err != nil {
+		log.Error().Err(err).Str("webhook", h.conf.Name).Str("topic", h.conf.DeadLetter).Msg("could not publish event to dead letter topic")
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 character.
+func truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of a webhook payload, which
+// receivers can compare to the signature header with hmac.Equal.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) webhookHealth() *api.ComponentHealth {
+	var delivered, deadLettered uint64
+	failing := make([]string, 0)
+	for _, hook := range s.webhooks.hooks {
+		delivered += atomic.LoadUint64(&hook.delivered)
+		deadLettered += atomic.LoadUint64(&hook.deadLettered)
+		if atomic.LoadInt32(&hook.failing) == 1 {
+			failing = append(failing, hook.conf.Name)
+		}
+	}
+
+	health := &api.ComponentHealth{
+		Name:    "webhooks",
+		Status:  HealthOK,
+		Message: fmt.Sprintf("%d hooks, %d events delivered, %d dead-lettered", len(s.webhooks.hooks), delivered, deadLettered),
+	}
+
+	if len(failing) > 0 {
+		health.Status = HealthDegraded
+		health.Message = fmt.Sprintf("%s; failing %s", health.Message, strings.Join(failing, ", "))
+	}
+	return health
+}
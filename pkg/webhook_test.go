@@ -0,0 +1,221 @@
+package switchback_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/config"
+)
+
+const (
+	webhookSecret    = "supersecret"
+	maxAttributeSize = 48
+)
+
+// receiver is a stand-in for a webhook endpoint that verifies signatures and fails the
+// first attempts to deliver each event.
+type receiver struct {
+	sync.Mutex
+	t        *testing.T
+	failures int
+	status   int
+	attempts map[string][]int
+	accepted []string
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	signature := "sha256=" + switchback.Sign(webhookSecret, req.Header.Get(switchback.WebhookTimestamp), body)
+	if !hmac.Equal([]byte(signature), []byte(req.Header.Get(switchback.WebhookSignature))) {
+		r.t.Errorf("invalid signature %q", req.Header.Get(switchback.WebhookSignature))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	attempt, err := strconv.Atoi(req.Header.Get(switchback.WebhookAttempt))
+	if err != nil {
+		r.t.Errorf("invalid attempt header %q", req.Header.Get(switchback.WebhookAttempt))
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	offset := req.Header.Get(switchback.WebhookOffset)
+	r.attempts[offset] = append(r.attempts[offset], attempt)
+	if len(r.attempts[offset]) <= r.failures {
+		w.WriteHeader(r.status)
+		return
+	}
+
+	r.accepted = append(r.accepted, offset)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newReceiver(t *testing.T, failures, status int) (*receiver, *httptest.Server) {
+	r := &receiver{t: t, failures: failures, status: status, attempts: make(map[string][]int)}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+func serveWebhooks(t *testing.T, hooks ...config.HookConfig) *switchback.Server {
+	return serveEmbedded(t, func(conf *config.Config) {
+		conf.Events.MaxAttributeSize = maxAttributeSize
+		conf.Webhooks.Enabled = true
+		conf.Webhooks.MaxAttempts = 3
+		conf.Webhooks.Backoff = time.Millisecond
+		conf.Webhooks.MaxBackoff = 4 * time.Millisecond
+		conf.Webhooks.Timeout = time.Second
+		conf.Webhooks.Hooks = hooks
+	})
+}
+
+func publishEvents(t *testing.T, pubsub *switchback.PubSub, topic string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := pubsub.Publish(context.Background(), &api.Event{Topic: topic, Data: []byte("order"), Attributes: map[string]string{"id": strconv.Itoa(i)}}); err != nil {
+			t.Fatalf("could not publish event: %s", err)
+		}
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	recv, endpoint := newReceiver(t, 2, http.StatusServiceUnavailable)
+	srv := serveWebhooks(t, config.HookConfig{Name: "orders", Topic: "orders", URL: endpoint.URL, Secret: webhookSecret, DeadLetter: "orders.dlq"})
+	waitForGroups(t, srv.PubSub(), "orders", 1)
+
+	dlq, err := srv.PubSub().Connect(&api.Subscription{Topic: "orders.dlq"})
+	if err != nil {
+		t.Fatalf("could not connect to dead letter topic: %s", err)
+	}
+
+	publishEvents(t, srv.PubSub(), "orders", 3)
+	waitFor(t, func() bool {
+		recv.Lock()
+		defer recv.Unlock()
+		return len(recv.accepted) == 3
+	})
+
+	// Each event is accepted on its third attempt, in order
+	recv.Lock()
+	defer recv.Unlock()
+	for i, offset := range recv.accepted {
+		if offset != strconv.Itoa(i+1) {
+			t.Errorf("expected offset %d to be accepted, got %s", i+1, offset)
+		}
+
+		attempts := recv.attempts[offset]
+		if len(attempts) != 3 || attempts[0] != 1 || attempts[1] != 2 || attempts[2] != 3 {
+			t.Errorf("expected attempts 1, 2, 3 for offset %s, got %v", offset, attempts)
+		}
+	}
+
+	select {
+	case event := <-dlq.Events():
+		t.Errorf("expected no events to be dead-lettered, got %v", event)
+	default:
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	// Server errors are retried until the max attempts, client errors are not retried
+	failing, failingURL := newReceiver(t, 100, http.StatusInternalServerError)
+	rejecting, rejectingURL := newReceiver(t, 100, http.StatusBadRequest)
+	srv := serveWebhooks(t,
+		config.HookConfig{Name: "failing", Topic: "payments", URL: failingURL.URL, Secret: webhookSecret, DeadLetter: "payments.dlq"},
+		config.HookConfig{Name: "rejecting", Topic: "refunds", URL: rejectingURL.URL, Secret: webhookSecret, DeadLetter: "refunds.dlq"},
+	)
+	waitForGroups(t, srv.PubSub(), "payments", 1)
+	waitForGroups(t, srv.PubSub(), "refunds", 1)
+
+	tests := []struct {
+		hook     string
+		topic    string
+		recv     *receiver
+		attempts int
+	}{
+		{"failing", "payments", failing, 3},
+		{"rejecting", "refunds", rejecting, 1},
+	}
+
+	for _, tc := range tests {
+		dlq, err := srv.PubSub().Connect(&api.Subscription{Topic: tc.topic + ".dlq"})
+		if err != nil {
+			t.Fatalf("could not connect to dead letter topic: %s", err)
+		}
+
+		publishEvents(t, srv.PubSub(), tc.topic, 2)
+		for i, event := range receive(t, dlq.Events(), 2) {
+			if string(event.Data) != "order" || event.Attributes["id"] != strconv.Itoa(i) {
+				t.Errorf("expected the dead letter to have the original event, got %v", event)
+			}
+
+			expected := map[string]string{
+				switchback.DeadLetterHook:     tc.hook,
+				switchback.DeadLetterTopic:    tc.topic,
+				switchback.DeadLetterOffset:   strconv.Itoa(i + 1),
+				switchback.DeadLetterAttempts: strconv.Itoa(tc.attempts),
+			}
+			for key, val := range expected {
+				if event.Attributes[key] != val {
+					t.Errorf("expected dead letter attribute %s=%q, got %q", key, val, event.Attributes[key])
+				}
+			}
+
+			// The error is truncated to fit the attribute size limit
+			reason := event.Attributes[switchback.DeadLetterError]
+			if !strings.HasPrefix("webhook responded with status", reason) || len(switchback.DeadLetterError)+len(reason) != maxAttributeSize {
+				t.Errorf("expected dead letter error to fit the attribute limit, got %q", reason)
+			}
+		}
+
+		tc.recv.Lock()
+		for offset, attempts := range tc.recv.attempts {
+			if len(attempts) != tc.attempts {
+				t.Errorf("expected %d attempts to deliver offset %s to %s, got %d", tc.attempts, offset, tc.hook, len(attempts))
+			}
+		}
+		tc.recv.Unlock()
+	}
+
+	state, err := embeddedClient(t, srv).Status(context.Background(), &api.HealthCheck{})
+	if err != nil {
+		t.Fatalf("could not get status: %s", err)
+	}
+
+	for _, component := range state.Components {
+		if component.Name == "webhooks" {
+			if component.Status != switchback.HealthDegraded || !strings.Contains(component.Message, "4 dead-lettered") {
+				t.Errorf("expected webhooks to be degraded with 4 dead letters, got %q: %s", component.Status, component.Message)
+			}
+			return
+		}
+	}
+	t.Error("expected webhooks health in the service state")
+}
+
+// waitFor polls the condition until it is true or fails the test after a timeout.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,258 @@
+package switchback
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gatewayWebSocket is the gateway endpoint that browsers subscribe to events on.
+const gatewayWebSocket = "/v1/ws"
+
+// maxSubscriptionSize limits the messages a WebSocket client can send, since the only
+// message it sends is its subscription.
+const maxSubscriptionSize = 4096
+
+// wsSubscription is the first message a WebSocket client sends after connecting. Only
+// the events whose attributes have all of the filter values are sent to the client;
+// events that do not match are still consumed by the client's group.
+type wsSubscription struct {
+	Topic   string            `json:"topic"`
+	Group   string            `json:"group"`
+	Filters map[string]string `json:"filters"`
+}
+
+// gatewayWebSocket upgrades the request to a WebSocket, reads the client's subscription,
+// and sends the events delivered to it as JSON text messages until either side closes
+// the connection. Browsers cannot set an authorization header on a WebSocket so the
+// access_token query parameter is accepted as a bearer token instead.
+func (s *Server) gatewayWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	ctx, err := s.gatewayContext(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Track the stream so that it can be drained
+	closeStream, ok := s.openStream()
+	if !ok {
+		writeError(w, status.Error(codes.Unavailable, "the switchback server is currently in maintenance mode"))
+		return
+	}
+	defer closeStream()
+
+	upgrader := websocket.Upgrader{CheckOrigin: checkOrigin(s.conf.Gateway.Origins)}
+	var conn *websocket.Conn
+	if conn, err = upgrader.Upgrade(w, r, nil); err != nil {
+		// The upgrader has already responded to the client
+		log.Debug().Err(err).Msg("could not upgrade websocket")
+		return
+	}
+	defer conn.Close()
+
+	activeStreams.WithLabelValues(gatewayWebSocket).Inc()
+	defer activeStreams.WithLabelValues(gatewayWebSocket).Dec()
+
+	var in *wsSubscription
+	if in, err = s.readSubscription(ctx, conn); err != nil {
+		closeWebSocket(conn, err, s.conf.Gateway.WriteTimeout)
+		return
+	}
+
+	// The context is canceled when the client closes the connection or stops responding
+	// to pings, which disconnects the consumer.
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	stream := &wsStream{ctx: ctx, conn: conn, filters: in.Filters, size: s.conf.Events.MaxBatchSize, timeout: s.conf.Gateway.WriteTimeout}
+	go stream.keepalive(cancel, s.conf.Gateway.PingInterval)
+
+	err = s.subscribe(&api.Subscription{Topic: in.Topic, Group: in.Group}, stream)
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err == nil {
+		// The consumer was closed by the server, e.g. while draining
+		err = status.Error(codes.Unavailable, "the switchback server closed the subscription")
+	}
+	log.Debug().Err(err).Str("topic", in.Topic).Msg("websocket subscription closed")
+	closeWebSocket(conn, err, s.conf.Gateway.WriteTimeout)
+}
+
+// readSubscription waits for the client's subscription and checks that the client may
+// subscribe to the topic and group.
+func (s *Server) readSubscription(ctx context.Context, conn *websocket.Conn) (in *wsSubscription, err error) {
+	conn.SetReadLimit(maxSubscriptionSize)
+	conn.SetReadDeadline(time.Now().Add(s.conf.Gateway.PingInterval))
+
+	in = &wsSubscription{}
+	if err = conn.ReadJSON(in); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "could not read subscription: %s", err)
+	}
+
+	if err = s.valid.Topic(in.Topic); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err = s.authorize(ctx, auth.Subscribe, in.Topic, in.Group); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// wsStream sends events to a WebSocket subscriber. Writes that take longer than the
+// timeout close the connection so that a client that cannot keep up does not block
+// delivery to its group indefinitely. Its unacknowledged events are only redelivered to
+// the group when it reconnects if the server is clustered, since the group resumes from
+// its last committed offset.
+type wsStream struct {
+	ctx     context.Context
+	conn    *websocket.Conn
+	filters map[string]string
+	size    int
+	timeout time.Duration
+}
+
+func (s *wsStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *wsStream) send(events []*api.Event) (err error) {
+	for _, event := range events {
+		if !s.matches(event) {
+			eventsDropped.WithLabelValues(event.Topic, "filtered").Inc()
+			continue
+		}
+
+		var data []byte
+		if data, err = gatewayMarshal.Marshal(event); err != nil {
+			return err
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		if err = s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			log.Warn().Err(err).Str("topic", event.Topic).Msg("could not write to websocket subscriber, closing connection")
+			return io.EOF
+		}
+	}
+	return nil
+}
+
+func (s *wsStream) batcher(bytes int) *batcher {
+	return newBatcher(s.size, 0, bytes)
+}
+
+func (s *wsStream) proxy(ctx context.Context, client api.SwitchbackClient, in *api.Subscription) (func() ([]*api.Event, error), error) {
+	return proxyEvents(ctx, client, in)
+}
+
+// matches returns true if the event has all of the filter attributes.
+func (s *wsStream) matches(event *api.Event) bool {
+	for key, val := range s.filters {
+		if event.Attributes[key] != val {
+			return false
+		}
+	}
+	return true
+}
+
+// keepalive pings the client at the interval and reads from the connection to process
+// its pongs and close frames. The context is canceled if the client closes the
+// connection or does not respond to a ping before the next one is due.
+func (s *wsStream) keepalive(cancel context.CancelFunc, interval time.Duration) {
+	defer cancel()
+	s.conn.SetReadDeadline(time.Now().Add(2 * interval))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(2 * interval))
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.timeout)); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	// Messages sent by the client after its subscription are discarded
+	for {
+		if _, _, err := s.conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+// closeWebSocket sends a close frame with the close code that corresponds to the error.
+func closeWebSocket(conn *websocket.Conn, err error, timeout time.Duration) {
+	st := status.Convert(err)
+	var code int
+	switch st.Code() {
+	case codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied:
+		code = websocket.ClosePolicyViolation
+	case codes.Unavailable:
+		code = websocket.CloseTryAgainLater
+	default:
+		code = websocket.CloseInternalServerErr
+	}
+
+	// Close reasons are limited to 123 bytes by the protocol
+	reason := st.Message()
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+
+	data, _ := json.Marshal(map[string]string{"error": st.Message()})
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	conn.WriteMessage(websocket.TextMessage, data)
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(timeout))
+}
+
+// checkOrigin returns the origin check for WebSocket upgrades; nil uses the default
+// check that only allows same-origin requests.
+func checkOrigin(origins []string) func(*http.Request) bool {
+	if len(origins) == 0 {
+		return nil
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+
+		for _, allowed := range origins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,222 @@
+package switchback_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	switchback "github.com/bbengfort/switchback/pkg"
+	"github.com/bbengfort/switchback/pkg/api/v1"
+	"github.com/bbengfort/switchback/pkg/auth"
+	"github.com/bbengfort/switchback/pkg/config"
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// dialWebSocket connects to the gateway WebSocket endpoint; the response is returned
+// so that rejected upgrades can be checked.
+func dialWebSocket(t *testing.T, addr, query string, header http.Header) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	conn, rep, err := websocket.DefaultDialer.Dial("ws://"+addr+"/v1/ws"+query, header)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, rep, err
+}
+
+// groupsOf returns the number of groups subscribed to the topic.
+func groupsOf(pubsub *switchback.PubSub, topic string) int {
+	for _, stats := range pubsub.Topics() {
+		if stats.Topic == topic {
+			return stats.Groups
+		}
+	}
+	return 0
+}
+
+// readEvent reads the next event sent to the WebSocket client.
+func readEvent(t *testing.T, conn *websocket.Conn) *api.Event {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("could not read event: %s", err)
+	}
+
+	event := &api.Event{}
+	if err = protojson.Unmarshal(data, event); err != nil {
+		t.Fatalf("could not parse event %s: %s", data, err)
+	}
+	return event
+}
+
+func TestWebSocketSubscribe(t *testing.T) {
+	srv, addr := serveGateway(t, nil)
+	conn, _, err := dialWebSocket(t, addr, "", nil)
+	if err != nil {
+		t.Fatalf("could not upgrade to websocket: %s", err)
+	}
+
+	if err = conn.WriteJSON(map[string]interface{}{"topic": "orders", "filters": map[string]string{"region": "eu"}}); err != nil {
+		t.Fatalf("could not send subscription: %s", err)
+	}
+	waitForGroups(t, srv.PubSub(), "orders", 1)
+
+	// Only the events that match the filters are sent to the client
+	for i, region := range []string{"eu", "us", "eu"} {
+		event := &api.Event{Topic: "orders", Data: []byte("order"), Attributes: map[string]string{"id": strconv.Itoa(i), "region": region}}
+		if err = srv.PubSub().Publish(context.Background(), event); err != nil {
+			t.Fatalf("could not publish event: %s", err)
+		}
+	}
+
+	for _, id := range []string{"0", "2"} {
+		if event := readEvent(t, conn); string(event.Data) != "order" || event.Attributes["id"] != id {
+			t.Errorf("expected event %s, got %v", id, event)
+		}
+	}
+
+	// Only GET requests can be upgraded
+	rep, err := http.Post("http://"+addr+"/v1/ws", "application/json", nil)
+	if err != nil {
+		t.Fatalf("could not send request: %s", err)
+	}
+	rep.Body.Close()
+
+	if rep.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected post to be refused, got %d", rep.StatusCode)
+	}
+}
+
+func TestWebSocketOrigins(t *testing.T) {
+	_, addr := serveGateway(t, func(conf *config.Config) {
+		conf.Gateway.Origins = []string{"https://app.example.com"}
+	})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"HTTPS://APP.EXAMPLE.COM", true},
+		{"https://evil.example.com", false},
+		{"", true},
+	}
+
+	for _, tc := range tests {
+		header := http.Header{}
+		if tc.origin != "" {
+			header.Set("Origin", tc.origin)
+		}
+
+		_, rep, err := dialWebSocket(t, addr, "", header)
+		if tc.allowed && err != nil {
+			t.Errorf("expected origin %q to be allowed: %s", tc.origin, err)
+		}
+
+		if !tc.allowed && (err == nil || rep == nil || rep.StatusCode != http.StatusForbidden) {
+			t.Errorf("expected origin %q to be forbidden, got %v", tc.origin, err)
+		}
+	}
+}
+
+func TestWebSocketACL(t *testing.T) {
+	srv, addr := serveGateway(t, func(conf *config.Config) {
+		conf.Auth.Enabled = true
+		conf.Auth.APIKeys = map[string]string{"s3cr3t": "worker"}
+		conf.Auth.ACL = []auth.Rule{{Principal: "worker", Permissions: []auth.Permission{auth.Subscribe}, Topics: []string{"orders"}, Groups: []string{"workers"}}}
+	})
+
+	// The upgrade is refused without credentials
+	if _, rep, err := dialWebSocket(t, addr, "", nil); err == nil || rep == nil || rep.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the upgrade to be unauthorized, got %v", err)
+	}
+
+	// A subscription that is not allowed by the ACL is closed with a policy violation
+	conn, _, err := dialWebSocket(t, addr, "?access_token=s3cr3t", nil)
+	if err != nil {
+		t.Fatalf("could not upgrade with access token: %s", err)
+	}
+
+	if err = conn.WriteJSON(map[string]string{"topic": "orders", "group": "others"}); err != nil {
+		t.Fatalf("could not send subscription: %s", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("expected the subscription to be closed with a policy violation, got %v", err)
+	}
+
+	// The token in the authorization header is used to authorize the subscription
+	if conn, _, err = dialWebSocket(t, addr, "", http.Header{"Authorization": []string{"Bearer s3cr3t"}}); err != nil {
+		t.Fatalf("could not upgrade with authorization header: %s", err)
+	}
+
+	if err = conn.WriteJSON(map[string]string{"topic": "orders", "group": "workers"}); err != nil {
+		t.Fatalf("could not send subscription: %s", err)
+	}
+	waitForGroups(t, srv.PubSub(), "orders", 1)
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	srv, addr := serveGateway(t, func(conf *config.Config) {
+		conf.Gateway.PingInterval = interval
+	})
+
+	subscribe := func(topic string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := dialWebSocket(t, addr, "", nil)
+		if err != nil {
+			t.Fatalf("could not upgrade to websocket: %s", err)
+		}
+
+		if err = conn.WriteJSON(map[string]string{"topic": topic}); err != nil {
+			t.Fatalf("could not send subscription: %s", err)
+		}
+		waitForGroups(t, srv.PubSub(), topic, 1)
+		return conn
+	}
+
+	// A client that reads from the connection answers the pings and stays subscribed
+	active := subscribe("active")
+	go func() {
+		for {
+			if _, _, err := active.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// A client that does not read from the connection does not answer the pings
+	idle := subscribe("idle")
+
+	time.Sleep(6 * interval)
+	if groupsOf(srv.PubSub(), "active") != 1 {
+		t.Error("expected the client that answers pings to stay subscribed")
+	}
+
+	waitFor(t, func() bool { return groupsOf(srv.PubSub(), "idle") == 0 })
+
+	// The server closes the connection of the client that stopped responding
+	idle.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var err error
+	for err == nil {
+		_, _, err = idle.ReadMessage()
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		t.Errorf("expected the server to close the idle connection, got %v", err)
+	}
+}